@@ -10,6 +10,7 @@ import (
 	awsconfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"io/ioutil"
 	"sync"
 )
 
@@ -68,24 +69,34 @@ func GetAWSParameter(name string, encrypted bool) (string, error) {
 
 var s3mutex *sync.Mutex = &sync.Mutex{}
 var s3client *s3.Client //singleton
-func PutS3Object(bucketName string, key string, body []byte) (string, error) {
-	s3mutex.Lock()
-	defer s3mutex.Unlock()
-
+//call with s3mutex held
+func loadS3Client() error {
 	if s3client == nil {
 		cfg, err := LoadAWSConfig()
 		if err != nil {
-			return "", err
+			return err
 		}
 
 		// Create an Amazon S3 service client
 		s3client = s3.NewFromConfig(*cfg)
 	}
 
+	return nil
+}
+
+func PutS3Object(bucketName string, key string, body []byte, metadata map[string]string) (string, error) {
+	s3mutex.Lock()
+	defer s3mutex.Unlock()
+
+	if err := loadS3Client(); err != nil {
+		return "", err
+	}
+
 	_, err := s3client.PutObject(context.TODO(), &s3.PutObjectInput{
 		Bucket: &bucketName,
-		Key:    &key,
-		Body:   bytes.NewReader(body)})
+		Key:      &key,
+		Body:     bytes.NewReader(body),
+		Metadata: metadata})
 
 	if err != nil {
 		return "", err
@@ -95,3 +106,23 @@ func PutS3Object(bucketName string, key string, body []byte) (string, error) {
 
 	return url, nil
 }
+
+func GetS3Object(bucketName string, key string) ([]byte, error) {
+	s3mutex.Lock()
+	defer s3mutex.Unlock()
+
+	if err := loadS3Client(); err != nil {
+		return nil, err
+	}
+
+	output, err := s3client.GetObject(context.TODO(), &s3.GetObjectInput{
+		Bucket: &bucketName,
+		Key:    &key})
+
+	if err != nil {
+		return nil, err
+	}
+	defer output.Body.Close()
+
+	return ioutil.ReadAll(output.Body)
+}
@@ -1,21 +1,79 @@
 package csg
 
 import (
-	"math"
+	"fmt"
 	"sync"
 	"time"
 )
 
-//simple im memory cache with TTL and limited use count
+//cache with TTL and limited use count, storage is delegated to a CacheBackend (memory or disk)
+
+const CacheBackendMemory = "memory"
+const CacheBackendDisk = "disk"
+const DefaultCacheSweepInterval = 60 //seconds
 
 var cacheInstance *CacheInstance
 var cacheSingletonLock = new(sync.Mutex)
 
 var Cache = initCache()
 
+// storage for cached values.  Backends that store values outside of the process are responsible for
+// serializing them (see cache_codec.go) and may keep values they can't serialize in memory.
+type CacheBackend interface {
+	Name() string
+	// returns the value and counts one use
+	Get(key string) (interface{}, bool)
+	// ttl in seconds, zero or negative ttl/useLimit means no limit
+	Put(key string, value interface{}, ttl int64, useLimit int)
+	// removes the entry and returns its value, if any
+	Clear(key string) interface{}
+	UsesLeft(key string) int
+	// removes expired and used up entries
+	Sweep()
+	Stats() CacheStats
+	// removes all entries held by this process, entries shared with other processes are left to expire
+	Destroy()
+	// like Destroy, but also removes the shared entries this process read or wrote, so they are fetched again
+	Invalidate()
+}
+
 type CacheInstance struct {
-	entries    map[string]*CacheEntry
+	backend    CacheBackend
+	inflight   map[string]*cacheCall
 	globalLock *sync.Mutex
+	stopSweep  chan struct{}
+}
+
+type CacheStats struct {
+	Hits        int64
+	Misses      int64
+	Evictions   int64
+	Expirations int64
+	Entries     int
+	Bytes       int64
+}
+
+func (s CacheStats) HitRate() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+func (s CacheStats) Add(s2 CacheStats) CacheStats {
+	s.Hits += s2.Hits
+	s.Misses += s2.Misses
+	s.Evictions += s2.Evictions
+	s.Expirations += s2.Expirations
+	s.Entries += s2.Entries
+	s.Bytes += s2.Bytes
+
+	return s
+}
+
+// in-progress computation of a value, other callers for the same key wait on done
+type cacheCall struct {
+	done chan struct{}
 }
 
 func initCache() *CacheInstance {
@@ -23,112 +81,170 @@ func initCache() *CacheInstance {
 	defer cacheSingletonLock.Unlock()
 
 	if cacheInstance == nil {
-		cacheInstance = new(CacheInstance)
-		cacheInstance.entries = make(map[string]*CacheEntry)
-		cacheInstance.globalLock = new(sync.Mutex)
+		cacheInstance = newCacheInstance(NewMemoryCacheBackend(0, 0))
 	}
 
 	return cacheInstance
 }
 
-type CacheEntry struct {
-	Value    interface{}
-	Expiry   int64
-	UsesLeft int
-	Lock     *sync.Mutex
-}
+func newCacheInstance(backend CacheBackend) *CacheInstance {
+	c := new(CacheInstance)
+	c.backend = backend
+	c.inflight = make(map[string]*cacheCall)
+	c.globalLock = new(sync.Mutex)
 
-func newCacheEntry() *CacheEntry {
-	newEntry := new(CacheEntry)
-	newEntry.Value = nil
-	newEntry.Expiry = math.MaxInt64
-	newEntry.UsesLeft = math.MaxInt32
-	newEntry.Lock = new(sync.Mutex)
+	return c
+}
 
-	return newEntry
+// creates the backend configured with cache_backend, cache_dir, cache_max_entries and cache_max_bytes
+func NewCacheBackend(config *Config) (CacheBackend, error) {
+	memory := NewMemoryCacheBackend(config.CacheMaxEntries, config.CacheMaxBytes)
+
+	switch config.CacheBackend {
+	case "", CacheBackendMemory:
+		return memory, nil
+	case CacheBackendDisk:
+		return NewDiskCacheBackend(config.CacheDir, memory)
+	default:
+		return nil, fmt.Errorf("Unknown cache backend: %s", config.CacheBackend)
+	}
 }
 
-func (c *CacheInstance) getOrCreate(key string) *CacheEntry {
+func (c *CacheInstance) SetBackend(backend CacheBackend) {
 	c.globalLock.Lock()
 	defer c.globalLock.Unlock()
 
-	var entry *CacheEntry
-
-	if _, exists := c.entries[key]; !exists {
-		c.entries[key] = newCacheEntry()
-	}
-	entry = c.entries[key]
-
-	return entry
+	c.backend = backend
 }
 
-func (c *CacheInstance) GetOrLock(key string) interface{} {
-	entry := c.getOrCreate(key)
-
-	entry.Lock.Lock()
+func (c *CacheInstance) Backend() CacheBackend {
+	c.globalLock.Lock()
+	defer c.globalLock.Unlock()
 
-	if entry.Value == nil || entry.UsesLeft <= 0 || entry.Expiry < time.Now().Unix() {
-		entry.Value = nil
-		return nil //return with lock held, presumably caller will put and unlock
-	} else {
-		defer entry.Lock.Unlock()
-		entry.UsesLeft--
-		return entry.Value
-	}
+	return c.backend
 }
 
-func (c *CacheInstance) UsesLeft(key string) int {
-	entry := c.getOrCreate(key)
-
-	entry.Lock.Lock()
-	defer entry.Lock.Unlock()
+// returns the cached value, counting as one use
+func (c *CacheInstance) Get(key string) (interface{}, bool) {
+	return c.Backend().Get(key)
+}
 
-	if entry.UsesLeft <= 0 {
-		return 0
-	} else {
-		return entry.UsesLeft
+// returns the cached value if present, otherwise calls compute and caches its result.
+// concurrent callers in this process for the same key wait for a single computation, if it
+// fails (or panics) the next waiter computes the value itself.  Values are not cached if
+// compute returns an error or a nil value.
+func (c *CacheInstance) GetOrCompute(key string, ttl int64, useLimit int, compute func() (interface{}, error)) (value interface{}, cached bool, err error) {
+	for {
+		c.globalLock.Lock()
+		if value, ok := c.backend.Get(key); ok {
+			c.globalLock.Unlock()
+			return value, true, nil
+		}
+
+		call, inflight := c.inflight[key]
+		if !inflight {
+			call = &cacheCall{done: make(chan struct{})}
+			c.inflight[key] = call
+			c.globalLock.Unlock()
+			break
+		}
+		c.globalLock.Unlock()
+
+		<-call.done
 	}
-}
 
-func (c *CacheInstance) Clear(key string) interface{} {
-	entry := c.getOrCreate(key)
+	defer func() {
+		c.globalLock.Lock()
+		defer c.globalLock.Unlock()
 
-	entry.Lock.Lock()
-	defer entry.Lock.Unlock()
+		close(c.inflight[key].done)
+		delete(c.inflight, key)
+	}()
 
-	value := entry.Value
-	entry.Value = nil
+	value, err = compute()
+	if err == nil && value != nil {
+		c.Put(key, value, ttl, useLimit)
+	}
 
-	return value
+	return value, false, err
 }
 
-func (c *CacheInstance) Unlock(key string) {
-	entry := c.getOrCreate(key)
+func (c *CacheInstance) UsesLeft(key string) int {
+	return c.Backend().UsesLeft(key)
+}
 
-	entry.Lock.Unlock()
+// removes the entry and returns its value, if any
+func (c *CacheInstance) Clear(key string) interface{} {
+	return c.Backend().Clear(key)
 }
 
 func (c *CacheInstance) Put(key string, value interface{}, ttl int64, useLimit int) {
-	entry := c.getOrCreate(key)
+	c.Backend().Put(key, value, ttl, useLimit)
+}
+
+// removes expired and used up entries
+func (c *CacheInstance) Sweep() {
+	c.Backend().Sweep()
+}
 
-	entry.Value = value
+// starts a background goroutine that sweeps expired entries every interval seconds
+func (c *CacheInstance) StartSweeper(interval int64) {
+	c.globalLock.Lock()
+	defer c.globalLock.Unlock()
 
-	if ttl > 0 {
-		entry.Expiry = time.Now().Unix() + ttl
-	} else {
-		entry.Expiry = math.MaxInt64
+	if c.stopSweep != nil {
+		return
 	}
 
-	if useLimit > 0 {
-		entry.UsesLeft = useLimit
-	} else {
-		entry.UsesLeft = math.MaxInt32
+	if interval <= 0 {
+		interval = DefaultCacheSweepInterval
 	}
+
+	stop := make(chan struct{})
+	c.stopSweep = stop
+
+	go func() {
+		ticker := time.NewTicker(time.Duration(interval) * time.Second)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				c.Sweep()
+			case <-stop:
+				return
+			}
+		}
+	}()
 }
 
-func (c *CacheInstance) Destroy() {
+func (c *CacheInstance) StopSweeper() {
 	c.globalLock.Lock()
 	defer c.globalLock.Unlock()
 
-	c.entries = make(map[string]*CacheEntry)
+	if c.stopSweep != nil {
+		close(c.stopSweep)
+		c.stopSweep = nil
+	}
+}
+
+func (c *CacheInstance) Stats() CacheStats {
+	return c.Backend().Stats()
+}
+
+func logCacheStats() {
+	stats := Cache.Stats()
+	Log.Infof("Cache (%s): %d entries, %d bytes, %d hits, %d misses (%.0f%% hit rate), %d evictions, %d expirations",
+		Cache.Backend().Name(), stats.Entries, stats.Bytes, stats.Hits, stats.Misses, stats.HitRate()*100, stats.Evictions, stats.Expirations)
+}
+
+// removes all entries held by this process, in-progress computations are unaffected
+func (c *CacheInstance) Destroy() {
+	c.Backend().Destroy()
+}
+
+// removes all entries this process used, shared ones included, e.g. before retrying scrapers that may have
+// failed on a bad cached response
+func (c *CacheInstance) Invalidate() {
+	c.Backend().Invalidate()
 }
@@ -0,0 +1,125 @@
+package csg
+
+import (
+	"encoding/json"
+	"reflect"
+	"sync"
+)
+
+//serialization of cached values for backends that store them outside of the process.
+//Only values with a registered codec are serialized, everything else stays in memory.
+
+const CacheCodecBytes = "bytes"
+const CacheCodecString = "string"
+
+type CacheCodec struct {
+	Name   string
+	Encode func(value interface{}) ([]byte, error)
+	Decode func(data []byte) (interface{}, error)
+}
+
+type cacheCodecRegistry struct {
+	byName map[string]*CacheCodec
+	byType map[reflect.Type]*CacheCodec
+	lock   *sync.RWMutex
+}
+
+var cacheCodecs = initCacheCodecs()
+
+func initCacheCodecs() *cacheCodecRegistry {
+	registry := new(cacheCodecRegistry)
+	registry.byName = make(map[string]*CacheCodec)
+	registry.byType = make(map[reflect.Type]*CacheCodec)
+	registry.lock = new(sync.RWMutex)
+
+	registry.register([]byte(nil), &CacheCodec{
+		Name: CacheCodecBytes,
+		Encode: func(value interface{}) ([]byte, error) {
+			return value.([]byte), nil
+		},
+		Decode: func(data []byte) (interface{}, error) {
+			return data, nil
+		},
+	})
+
+	registry.register("", &CacheCodec{
+		Name: CacheCodecString,
+		Encode: func(value interface{}) ([]byte, error) {
+			return []byte(value.(string)), nil
+		},
+		Decode: func(data []byte) (interface{}, error) {
+			return string(data), nil
+		},
+	})
+
+	//values cached by the built in scrapers
+	registry.register([]string(nil), newJsonCacheCodec("strings", []string(nil)))
+	registry.register(map[string]string(nil), newJsonCacheCodec("string_map", map[string]string(nil)))
+	registry.register(StatusAndTagSet{}, newJsonCacheCodec("status_and_tags", StatusAndTagSet{}))
+	registry.register(CountAndTagSet{}, newJsonCacheCodec("count_and_tags", CountAndTagSet{}))
+	registry.register(new(CvsGetStoresApiResp), newJsonCacheCodec("cvs_stores", new(CvsGetStoresApiResp)))
+	registry.register(new(VSAPIResp), newJsonCacheCodec("vaccinespotter", new(VSAPIResp)))
+	registry.register(new(WalgreensAPIResp), newJsonCacheCodec("walgreens", new(WalgreensAPIResp)))
+
+	return registry
+}
+
+func (r *cacheCodecRegistry) register(sample interface{}, codec *CacheCodec) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.byName[codec.Name] = codec
+	r.byType[reflect.TypeOf(sample)] = codec
+}
+
+// registers a codec for values of the same type as sample
+func RegisterCacheCodec(sample interface{}, codec *CacheCodec) {
+	cacheCodecs.register(sample, codec)
+}
+
+// registers a json codec for values of the same type as sample (a struct, or pointer to a struct)
+func RegisterCacheJsonType(name string, sample interface{}) {
+	cacheCodecs.register(sample, newJsonCacheCodec(name, sample))
+}
+
+func newJsonCacheCodec(name string, sample interface{}) *CacheCodec {
+	sampleType := reflect.TypeOf(sample)
+
+	return &CacheCodec{
+		Name: name,
+		Encode: func(value interface{}) ([]byte, error) {
+			return json.Marshal(value)
+		},
+		Decode: func(data []byte) (interface{}, error) {
+			if sampleType.Kind() == reflect.Ptr {
+				value := reflect.New(sampleType.Elem())
+				if err := json.Unmarshal(data, value.Interface()); err != nil {
+					return nil, err
+				}
+				return value.Interface(), nil
+			}
+
+			value := reflect.New(sampleType)
+			if err := json.Unmarshal(data, value.Interface()); err != nil {
+				return nil, err
+			}
+			return value.Elem().Interface(), nil
+		},
+	}
+}
+
+func cacheCodecForValue(value interface{}) (*CacheCodec, bool) {
+	cacheCodecs.lock.RLock()
+	defer cacheCodecs.lock.RUnlock()
+
+	codec, exists := cacheCodecs.byType[reflect.TypeOf(value)]
+	return codec, exists
+}
+
+func cacheCodecByName(name string) (*CacheCodec, bool) {
+	cacheCodecs.lock.RLock()
+	defer cacheCodecs.lock.RUnlock()
+
+	codec, exists := cacheCodecs.byName[name]
+	return codec, exists
+}
@@ -0,0 +1,332 @@
+package csg
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+//disk cache backend: a directory of files with a json header line (key, expiry, codec) followed by the
+//encoded value.  Files are written to a temp file and renamed so concurrent processes sharing the
+//directory never see partial writes.  Values without a codec, or with a use limit, are kept in memory.
+
+const DefaultCacheDir = "cache"
+const DiskCacheFileExtension = ".cache"
+const DiskCacheTempPrefix = ".tmp-"
+const DiskCacheTempMaxAge = 3600 //seconds before abandoned temp files are removed
+
+type DiskCacheBackend struct {
+	dir    string
+	memory *MemoryCacheBackend
+	lock   *sync.Mutex
+	stats  CacheStats
+	used   map[string]bool //keys this process read from or wrote to disk, removed by Invalidate
+}
+
+type diskCacheHeader struct {
+	Key    string `json:"key"`
+	Expiry int64  `json:"expiry"`
+	Codec  string `json:"codec"`
+}
+
+func NewDiskCacheBackend(dir string, memory *MemoryCacheBackend) (*DiskCacheBackend, error) {
+	if len(dir) == 0 {
+		dir = DefaultCacheDir
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("Can't create cache dir %s: %v", dir, err)
+	}
+
+	if memory == nil {
+		memory = NewMemoryCacheBackend(0, 0)
+	}
+
+	d := new(DiskCacheBackend)
+	d.dir = dir
+	d.memory = memory
+	d.lock = new(sync.Mutex)
+	d.used = make(map[string]bool)
+
+	return d, nil
+}
+
+func (d *DiskCacheBackend) Name() string {
+	return CacheBackendDisk
+}
+
+func (d *DiskCacheBackend) path(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return filepath.Join(d.dir, hex.EncodeToString(hash[:])+DiskCacheFileExtension)
+}
+
+// reads the header and encoded value, returns exists=false for missing, foreign or unreadable files
+func (d *DiskCacheBackend) read(key string) (header diskCacheHeader, data []byte, exists bool) {
+	contents, err := ioutil.ReadFile(d.path(key))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			Log.Warnf("Cache: could not read %s: %v", d.path(key), err)
+		}
+		return header, nil, false
+	}
+
+	idx := bytes.IndexByte(contents, '\n')
+	if idx < 0 {
+		return header, nil, false
+	}
+
+	if err = json.Unmarshal(contents[:idx], &header); err != nil || header.Key != key {
+		return header, nil, false
+	}
+
+	return header, contents[idx+1:], true
+}
+
+func (d *DiskCacheBackend) remove(path string) {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		Log.Warnf("Cache: could not remove %s: %v", path, err)
+	}
+}
+
+func (d *DiskCacheBackend) Get(key string) (interface{}, bool) {
+	if value, ok := d.memory.Get(key); ok {
+		return value, true
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	header, data, exists := d.read(key)
+	if !exists {
+		d.stats.Misses++
+		return nil, false
+	}
+
+	if header.Expiry < time.Now().Unix() {
+		d.remove(d.path(key))
+		d.stats.Expirations++
+		d.stats.Misses++
+		return nil, false
+	}
+
+	codec, exists := cacheCodecByName(header.Codec)
+	if !exists {
+		Log.Warnf("Cache: unknown codec %s for key %s", header.Codec, key)
+		d.stats.Misses++
+		return nil, false
+	}
+
+	value, err := codec.Decode(data)
+	if err != nil {
+		Log.Warnf("Cache: could not decode %s: %v", key, err)
+		d.remove(d.path(key))
+		d.stats.Misses++
+		return nil, false
+	}
+
+	d.stats.Hits++
+	d.used[key] = true
+	return value, true
+}
+
+func (d *DiskCacheBackend) Put(key string, value interface{}, ttl int64, useLimit int) {
+	codec, exists := cacheCodecForValue(value)
+	if !exists || useLimit > 0 {
+		//use counts can't be shared between processes
+		d.memory.Put(key, value, ttl, useLimit)
+		return
+	}
+
+	if err := d.write(key, value, ttl, codec); err != nil {
+		Log.Warnf("Cache: could not write %s, keeping it in memory: %v", key, err)
+		d.memory.Put(key, value, ttl, useLimit)
+		return
+	}
+
+	d.memory.Clear(key)
+}
+
+func (d *DiskCacheBackend) write(key string, value interface{}, ttl int64, codec *CacheCodec) error {
+	data, err := codec.Encode(value)
+	if err != nil {
+		return err
+	}
+
+	header := diskCacheHeader{Key: key, Expiry: math.MaxInt64, Codec: codec.Name}
+	if ttl > 0 {
+		header.Expiry = time.Now().Unix() + ttl
+	}
+
+	headerBytes, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+
+	tmpFile, err := ioutil.TempFile(d.dir, DiskCacheTempPrefix)
+	if err != nil {
+		return err
+	}
+
+	_, err = tmpFile.Write(append(append(headerBytes, '\n'), data...))
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		d.remove(tmpFile.Name())
+		return err
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	path := d.path(key)
+	if err = os.Rename(tmpFile.Name(), path); err != nil {
+		d.remove(tmpFile.Name())
+		return err
+	}
+	d.used[key] = true
+	return nil
+}
+
+func (d *DiskCacheBackend) Clear(key string) interface{} {
+	if value := d.memory.Clear(key); value != nil {
+		return value
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	delete(d.used, key)
+	header, data, exists := d.read(key)
+	if !exists {
+		return nil
+	}
+
+	d.remove(d.path(key))
+
+	if codec, exists := cacheCodecByName(header.Codec); exists {
+		if value, err := codec.Decode(data); err == nil {
+			return value
+		}
+	}
+
+	return nil
+}
+
+func (d *DiskCacheBackend) UsesLeft(key string) int {
+	if usesLeft := d.memory.UsesLeft(key); usesLeft > 0 {
+		return usesLeft
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if header, _, exists := d.read(key); exists && header.Expiry >= time.Now().Unix() {
+		return math.MaxInt32
+	}
+
+	return 0
+}
+
+// visits every cache file in the directory, removing abandoned temp files along the way
+func (d *DiskCacheBackend) walk(visit func(path string, header diskCacheHeader, size int64)) {
+	files, err := ioutil.ReadDir(d.dir)
+	if err != nil {
+		Log.Warnf("Cache: could not read cache dir %s: %v", d.dir, err)
+		return
+	}
+
+	for _, file := range files {
+		path := filepath.Join(d.dir, file.Name())
+
+		if strings.HasPrefix(file.Name(), DiskCacheTempPrefix) {
+			if time.Since(file.ModTime()) > DiskCacheTempMaxAge*time.Second {
+				d.remove(path)
+			}
+			continue
+		}
+
+		if file.IsDir() || !strings.HasSuffix(file.Name(), DiskCacheFileExtension) {
+			continue
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			continue
+		}
+
+		var header diskCacheHeader
+		line, err := bufio.NewReader(f).ReadBytes('\n')
+		f.Close()
+		if err != nil || json.Unmarshal(line, &header) != nil {
+			continue
+		}
+
+		visit(path, header, file.Size())
+	}
+}
+
+func (d *DiskCacheBackend) Sweep() {
+	d.memory.Sweep()
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	now := time.Now().Unix()
+	d.walk(func(path string, header diskCacheHeader, _ int64) {
+		if header.Expiry < now {
+			d.remove(path)
+			d.stats.Expirations++
+		}
+	})
+}
+
+func (d *DiskCacheBackend) Stats() CacheStats {
+	memoryStats := d.memory.Stats()
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	stats := d.stats
+	stats.Hits += memoryStats.Hits
+	stats.Evictions += memoryStats.Evictions
+	stats.Expirations += memoryStats.Expirations
+	stats.Entries = memoryStats.Entries
+	stats.Bytes = memoryStats.Bytes
+
+	d.walk(func(_ string, _ diskCacheHeader, size int64) {
+		stats.Entries++
+		stats.Bytes += size
+	})
+
+	return stats
+}
+
+// removes in memory entries only.  files may still be read by other processes sharing the directory,
+// they are removed once they expire, by Get or Sweep
+func (d *DiskCacheBackend) Destroy() {
+	d.memory.Destroy()
+}
+
+// removes in memory entries and the files this process read or wrote, other files are left to the processes using them
+func (d *DiskCacheBackend) Invalidate() {
+	d.memory.Destroy()
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	for key := range d.used {
+		d.remove(d.path(key))
+	}
+	d.used = make(map[string]bool)
+}
@@ -0,0 +1,231 @@
+package csg
+
+import (
+	"container/list"
+	"math"
+	"sync"
+	"time"
+)
+
+//in memory LRU cache backend with TTL and limited use count, bounded by entry count and approximate size
+
+const DefaultCacheMaxEntries = 10000
+const DefaultCacheMaxBytes = 256 * 1024 * 1024
+const CacheDefaultEntrySize = 1024 //assumed size of values we can't measure
+
+type MemoryCacheBackend struct {
+	entries    map[string]*list.Element
+	lru        *list.List //front is most recently used
+	lock       *sync.Mutex
+	maxEntries int
+	maxBytes   int64
+	bytes      int64
+	stats      CacheStats
+}
+
+type CacheEntry struct {
+	Key      string
+	Value    interface{}
+	Expiry   int64
+	UsesLeft int
+	Size     int64
+}
+
+// zero or negative limits use the defaults
+func NewMemoryCacheBackend(maxEntries int, maxBytes int64) *MemoryCacheBackend {
+	m := new(MemoryCacheBackend)
+	m.entries = make(map[string]*list.Element)
+	m.lru = list.New()
+	m.lock = new(sync.Mutex)
+	m.SetLimits(maxEntries, maxBytes)
+
+	return m
+}
+
+func (m *MemoryCacheBackend) Name() string {
+	return CacheBackendMemory
+}
+
+// sets the size bounds, zero or negative values use the defaults
+func (m *MemoryCacheBackend) SetLimits(maxEntries int, maxBytes int64) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if maxEntries <= 0 {
+		maxEntries = DefaultCacheMaxEntries
+	}
+	if maxBytes <= 0 {
+		maxBytes = DefaultCacheMaxBytes
+	}
+
+	m.maxEntries = maxEntries
+	m.maxBytes = maxBytes
+	m.evict()
+}
+
+func cacheValueSize(value interface{}) int64 {
+	switch typed := value.(type) {
+	case []byte:
+		return int64(len(typed))
+	case string:
+		return int64(len(typed))
+	case []string:
+		size := int64(0)
+		for _, str := range typed {
+			size += int64(len(str))
+		}
+		return size
+	case map[string]string:
+		size := int64(0)
+		for k, v := range typed {
+			size += int64(len(k) + len(v))
+		}
+		return size
+	default:
+		return CacheDefaultEntrySize
+	}
+}
+
+func (entry *CacheEntry) expired(now int64) bool {
+	return entry.UsesLeft <= 0 || entry.Expiry < now
+}
+
+// must be called with lock held
+func (m *MemoryCacheBackend) removeElement(elem *list.Element) {
+	entry := elem.Value.(*CacheEntry)
+	m.lru.Remove(elem)
+	delete(m.entries, entry.Key)
+	m.bytes -= entry.Size
+}
+
+// must be called with lock held
+func (m *MemoryCacheBackend) evict() {
+	for m.lru.Len() > 0 && (m.lru.Len() > m.maxEntries || m.bytes > m.maxBytes) {
+		m.removeElement(m.lru.Back())
+		m.stats.Evictions++
+	}
+}
+
+func (m *MemoryCacheBackend) Get(key string) (interface{}, bool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	elem, exists := m.entries[key]
+	if !exists {
+		m.stats.Misses++
+		return nil, false
+	}
+
+	entry := elem.Value.(*CacheEntry)
+	if entry.expired(time.Now().Unix()) {
+		m.removeElement(elem)
+		m.stats.Expirations++
+		m.stats.Misses++
+		return nil, false
+	}
+
+	entry.UsesLeft--
+	m.lru.MoveToFront(elem)
+	m.stats.Hits++
+
+	return entry.Value, true
+}
+
+func (m *MemoryCacheBackend) UsesLeft(key string) int {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	elem, exists := m.entries[key]
+	if !exists {
+		return 0
+	}
+
+	entry := elem.Value.(*CacheEntry)
+	if entry.UsesLeft <= 0 {
+		return 0
+	} else {
+		return entry.UsesLeft
+	}
+}
+
+func (m *MemoryCacheBackend) Clear(key string) interface{} {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	elem, exists := m.entries[key]
+	if !exists {
+		return nil
+	}
+
+	m.removeElement(elem)
+	return elem.Value.(*CacheEntry).Value
+}
+
+func (m *MemoryCacheBackend) Put(key string, value interface{}, ttl int64, useLimit int) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if elem, exists := m.entries[key]; exists {
+		m.removeElement(elem)
+	}
+
+	entry := new(CacheEntry)
+	entry.Key = key
+	entry.Value = value
+	entry.Size = cacheValueSize(value) + int64(len(key))
+
+	if ttl > 0 {
+		entry.Expiry = time.Now().Unix() + ttl
+	} else {
+		entry.Expiry = math.MaxInt64
+	}
+
+	if useLimit > 0 {
+		entry.UsesLeft = useLimit
+	} else {
+		entry.UsesLeft = math.MaxInt32
+	}
+
+	m.entries[key] = m.lru.PushFront(entry)
+	m.bytes += entry.Size
+	m.evict()
+}
+
+func (m *MemoryCacheBackend) Sweep() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	now := time.Now().Unix()
+	for elem := m.lru.Back(); elem != nil; {
+		prev := elem.Prev()
+		if elem.Value.(*CacheEntry).expired(now) {
+			m.removeElement(elem)
+			m.stats.Expirations++
+		}
+		elem = prev
+	}
+}
+
+func (m *MemoryCacheBackend) Stats() CacheStats {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	stats := m.stats
+	stats.Entries = m.lru.Len()
+	stats.Bytes = m.bytes
+
+	return stats
+}
+
+func (m *MemoryCacheBackend) Destroy() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.entries = make(map[string]*list.Element)
+	m.lru = list.New()
+	m.bytes = 0
+}
+
+func (m *MemoryCacheBackend) Invalidate() {
+	m.Destroy()
+}
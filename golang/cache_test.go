@@ -0,0 +1,200 @@
+package csg
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCacheEviction(t *testing.T) {
+	c := NewMemoryCacheBackend(2, 1024)
+
+	c.Put("a", []byte("1"), 0, 0)
+	c.Put("b", []byte("2"), 0, 0)
+	c.Get("a")
+	c.Put("c", []byte("3"), 0, 0)
+
+	if _, ok := c.Get("b"); ok {
+		t.Errorf("Expected least recently used entry to be evicted")
+	}
+	if _, ok := c.Get("a"); !ok {
+		t.Errorf("Expected recently used entry to be kept")
+	}
+
+	small := NewMemoryCacheBackend(10, 1024)
+	small.Put("big", make([]byte, 2048), 0, 0)
+	if _, ok := small.Get("big"); ok {
+		t.Errorf("Expected entry over the byte limit to be evicted")
+	}
+
+	c.SetLimits(10, 1024)
+	c.Put("limited", "x", 0, 2)
+	c.Get("limited")
+	c.Get("limited")
+	if _, ok := c.Get("limited"); ok {
+		t.Errorf("Expected entry to be used up after 2 uses")
+	}
+
+	c.Put("expired", "x", 1, 0)
+	c.entries["expired"].Value.(*CacheEntry).Expiry = time.Now().Unix() - 1
+	c.Sweep()
+	if len(c.entries) != 2 {
+		t.Errorf("Expected sweep to leave 2 entries, got %d", len(c.entries))
+	}
+
+	stats := c.Stats()
+	if stats.Evictions != 1 || stats.Expirations != 2 || stats.Entries != 2 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestCacheGetOrCompute(t *testing.T) {
+	c := newCacheInstance(NewMemoryCacheBackend(0, 0))
+
+	var computeCount int32
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value, _, err := c.GetOrCompute("key", 60, 0, func() (interface{}, error) {
+				atomic.AddInt32(&computeCount, 1)
+				time.Sleep(50 * time.Millisecond)
+				return "value", nil
+			})
+			if value != "value" || err != nil {
+				t.Errorf("Unexpected result: %v, %v", value, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if computeCount != 1 {
+		t.Errorf("Expected a single computation, got %d", computeCount)
+	}
+
+	//a panic must not leave the key locked
+	func() {
+		defer func() { _ = recover() }()
+		_, _, _ = c.GetOrCompute("panic", 60, 0, func() (interface{}, error) {
+			panic("boom")
+		})
+	}()
+
+	done := make(chan bool)
+	go func() {
+		_, cached, err := c.GetOrCompute("panic", 60, 0, func() (interface{}, error) {
+			return nil, fmt.Errorf("failed")
+		})
+		done <- !cached && err != nil
+	}()
+
+	select {
+	case ok := <-done:
+		if !ok {
+			t.Errorf("Expected error from second computation")
+		}
+	case <-time.After(time.Second):
+		t.Errorf("GetOrCompute blocked after a panic")
+	}
+}
+
+func TestDiskCacheBackend(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	d1, err := NewDiskCacheBackend(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d2, _ := NewDiskCacheBackend(dir, nil)
+
+	tags := TagSet{}.Add(TagPfizer)
+	d1.Put("body", []byte("hello"), 60, 0)
+	d1.Put("status", StatusAndTagSet{Status: StatusYes, TagSet: tags}, 60, 0)
+	d1.Put("limited", []byte("once"), 60, 1)
+	d1.Put("unregistered", new(Endpoint), 60, 0)
+
+	//a second process sharing the directory sees serializable values only
+	if value, ok := d2.Get("body"); !ok || string(value.([]byte)) != "hello" {
+		t.Errorf("Expected shared []byte value, got %v", value)
+	}
+	if value, ok := d2.Get("status"); !ok || value.(StatusAndTagSet).Status != StatusYes || !value.(StatusAndTagSet).TagSet.Contains(TagPfizer) {
+		t.Errorf("Expected shared StatusAndTagSet value, got %v", value)
+	}
+	if _, ok := d2.Get("limited"); ok {
+		t.Errorf("Expected use limited value to stay in memory")
+	}
+	if _, ok := d2.Get("unregistered"); ok {
+		t.Errorf("Expected value without a codec to stay in memory")
+	}
+	if _, ok := d1.Get("unregistered"); !ok {
+		t.Errorf("Expected value without a codec to be cached in memory")
+	}
+
+	d1.Put("expired", []byte("old"), 1, 0)
+	time.Sleep(2100 * time.Millisecond)
+	d2.Sweep()
+	if _, ok := d1.Get("expired"); ok {
+		t.Errorf("Expected expired value to be swept")
+	}
+
+	//other processes may still be reading the files, Destroy only clears memory
+	d1.Destroy()
+	if _, ok := d1.Get("unregistered"); ok {
+		t.Errorf("Expected Destroy to clear values held in memory")
+	}
+	if value, ok := d2.Get("body"); !ok || string(value.([]byte)) != "hello" {
+		t.Errorf("Expected Destroy to leave shared files, got %v", value)
+	}
+}
+
+func TestDiskCacheRetry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	d1, err := NewDiskCacheBackend(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d2, _ := NewDiskCacheBackend(dir, nil)
+	c := newCacheInstance(d1)
+
+	fetches := 0
+	fetch := func() (interface{}, error) {
+		fetches++
+		return []byte(fmt.Sprintf("response %d", fetches)), nil
+	}
+
+	c.GetOrCompute("bad", 60, -1, fetch)
+	d2.Put("shared", []byte("other process"), 60, 0)
+	d2.Put("unused", []byte("other process"), 60, 0)
+	d1.Get("shared")
+
+	//a retry after Destroy would parse the same response again
+	c.Destroy()
+	if value, _, _ := c.GetOrCompute("bad", 60, -1, fetch); string(value.([]byte)) != "response 1" || fetches != 1 {
+		t.Errorf("Expected Destroy to leave the file, got %s", value)
+	}
+
+	c.Invalidate()
+	if value, cached, _ := c.GetOrCompute("bad", 60, -1, fetch); cached || string(value.([]byte)) != "response 2" {
+		t.Errorf("Expected a retry after Invalidate to fetch again, got %s", value)
+	}
+	if _, ok := d2.Get("shared"); ok {
+		t.Errorf("Expected Invalidate to remove files this process read")
+	}
+	if _, ok := d2.Get("unused"); !ok {
+		t.Errorf("Expected Invalidate to leave files this process didn't use")
+	}
+}
@@ -9,19 +9,36 @@ import (
 type ChangeTracker struct {
 	locker         map[string]bool
 	errorCount     map[string]int
+	lastError      map[string]error
 	apiLastTime    map[string]int64
 	apiLastStatus  map[string]Status
 	lastScrapeTime map[string]int64
+	lastChangeTime map[string]int64
+	lastSuccess    map[string]int64 //last scrape that returned a status
 	mutex          *sync.Mutex
 }
 
+// point in time copy of what the tracker knows about a scraper
+type ChangeTrackerState struct {
+	Status         Status
+	ErrorCount     int
+	LastError      error
+	LastScrapeTime int64
+	LastChangeTime int64
+	LastApiTime    int64
+	LastSuccess    int64
+}
+
 func NewChangeTracker(names []string) *ChangeTracker {
 	changeTracker := new(ChangeTracker)
 	changeTracker.lastScrapeTime = make(map[string]int64)
+	changeTracker.lastChangeTime = make(map[string]int64)
+	changeTracker.lastSuccess = make(map[string]int64)
 	changeTracker.apiLastTime = make(map[string]int64)
 	changeTracker.apiLastStatus = make(map[string]Status)
 	changeTracker.locker = make(map[string]bool)
 	changeTracker.errorCount = make(map[string]int)
+	changeTracker.lastError = make(map[string]error)
 	changeTracker.mutex = &sync.Mutex{}
 
 	for _, name := range names {
@@ -46,10 +63,19 @@ func (t *ChangeTracker) Error(name string, err error) int {
 	}
 
 	t.errorCount[name] = prevErrorCount + 1
+	t.lastError[name] = err
 
 	return t.errorCount[name]
 }
 
+// returns the error from the most recent failed scrape, if the scraper has failed since its last success
+func (t *ChangeTracker) LastError(name string) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	return t.lastError[name]
+}
+
 func (t *ChangeTracker) UpdateAndUnlock(name string, status Status) (bool, bool) {
 	t.mutex.Lock()
 	defer t.mutex.Unlock()
@@ -66,7 +92,10 @@ func (t *ChangeTracker) UpdateAndUnlock(name string, status Status) (bool, bool)
 		panic(fmt.Errorf("Name not found in change tracker object: %s", name))
 	}
 
-	t.errorCount[name] = 0
+	if status != StatusUnknown {
+		t.errorCount[name] = 0
+		delete(t.lastError, name)
+	}
 
 	currentTimestamp := time.Now().Unix()
 	t.lastScrapeTime[name] = currentTimestamp
@@ -74,10 +103,12 @@ func (t *ChangeTracker) UpdateAndUnlock(name string, status Status) (bool, bool)
 	if status == StatusUnknown {
 		return false, false
 	}
+	t.lastSuccess[name] = currentTimestamp
 
 	if prevStatus != status {
 		t.apiLastStatus[name] = status
 		t.apiLastTime[name] = currentTimestamp
+		t.lastChangeTime[name] = currentTimestamp
 
 		if prevStatus == StatusUnknown {
 			return true, false
@@ -119,3 +150,24 @@ func (t *ChangeTracker) LastScrape(name string) int64 {
 
 	return 0
 }
+
+func (t *ChangeTracker) State(name string) (ChangeTrackerState, bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	var state ChangeTrackerState
+	status, ok := t.apiLastStatus[name]
+	if !ok {
+		return state, false
+	}
+
+	state.Status = status
+	state.ErrorCount = t.errorCount[name]
+	state.LastError = t.lastError[name]
+	state.LastScrapeTime = t.lastScrapeTime[name]
+	state.LastChangeTime = t.lastChangeTime[name]
+	state.LastApiTime = t.apiLastTime[name]
+	state.LastSuccess = t.lastSuccess[name]
+
+	return state, true
+}
@@ -0,0 +1,38 @@
+package csg
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestChangeTrackerErrorCount(t *testing.T) {
+	prevConfig := config
+	config = &Config{ApiInterval: 180}
+	defer func() { config = prevConfig }()
+
+	name := "TestChangeTrackerErrorCount"
+	tracker := NewChangeTracker([]string{name})
+
+	//failed scrapes keep counting, so error_warning_threshold can be reached
+	for expected := 1; expected <= 3; expected++ {
+		tracker.Lock(name)
+		if count := tracker.Error(name, fmt.Errorf("failure %d", expected)); count != expected {
+			t.Errorf("Expected error count %d, got %d", expected, count)
+		}
+		tracker.UpdateAndUnlock(name, StatusUnknown)
+	}
+	if err := tracker.LastError(name); err == nil || err.Error() != "failure 3" {
+		t.Errorf("Expected the last error to be kept, got %v", err)
+	}
+
+	tracker.Lock(name)
+	tracker.UpdateAndUnlock(name, StatusNo)
+	if state, _ := tracker.State(name); state.ErrorCount != 0 || tracker.LastError(name) != nil {
+		t.Errorf("Expected a success to reset the error count, got %d (%v)", state.ErrorCount, tracker.LastError(name))
+	}
+
+	tracker.Lock(name)
+	if count := tracker.Error(name, fmt.Errorf("failure")); count != 1 {
+		t.Errorf("Expected counting to start over after a success, got %d", count)
+	}
+}
@@ -0,0 +1,143 @@
+package csg
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+)
+
+//conditional GET support: remember ETag/Last-Modified per url and send
+//If-None-Match/If-Modified-Since, a 304 response reuses the previous body
+
+type conditionalValidator struct {
+	ETag         string
+	LastModified string
+	Body         []byte
+	Headers      map[string][]string
+}
+
+type ConditionalGetStats struct {
+	Requests    int64
+	NotModified int64
+}
+
+func (s ConditionalGetStats) HitRate() float64 {
+	if s.Requests == 0 {
+		return 0
+	}
+	return float64(s.NotModified) / float64(s.Requests)
+}
+
+var conditionalGetStats = make(map[string]*ConditionalGetStats)
+var conditionalGetStatsLock = new(sync.Mutex)
+
+func (endpoint *Endpoint) conditionalGetEnabled() bool {
+	return endpoint.ConditionalGet && endpoint.Method == "GET"
+}
+
+func (endpoint *Endpoint) applyConditionalHeaders(url string, req *http.Request) {
+	if !endpoint.conditionalGetEnabled() || endpoint.validators == nil {
+		return
+	}
+
+	validator, exists := endpoint.validators[url]
+	if !exists {
+		return
+	}
+
+	if len(validator.ETag) > 0 {
+		req.Header.Set("If-None-Match", validator.ETag)
+	}
+	if len(validator.LastModified) > 0 {
+		req.Header.Set("If-Modified-Since", validator.LastModified)
+	}
+}
+
+// returns the previously fetched body and headers if the server responded with 304 Not Modified
+func (endpoint *Endpoint) handleConditionalResponse(name string, url string, resp *http.Response) (body []byte, headers map[string][]string, notModified bool) {
+	if !endpoint.conditionalGetEnabled() {
+		return nil, nil, false
+	}
+
+	validator, exists := endpoint.validators[url]
+	notModified = exists && resp.StatusCode == http.StatusNotModified
+	recordConditionalGet(name, notModified)
+
+	if notModified {
+		LogFor(name).Debugf("not modified since last fetch: %s", url)
+		return validator.Body, validator.Headers, true
+	}
+
+	return nil, nil, false
+}
+
+func (endpoint *Endpoint) storeConditionalValidator(url string, resp *http.Response, body []byte, headers map[string][]string) {
+	if !endpoint.conditionalGetEnabled() || resp.StatusCode != http.StatusOK {
+		return
+	}
+
+	etag := resp.Header.Get("ETag")
+	lastModified := resp.Header.Get("Last-Modified")
+
+	if endpoint.validators == nil {
+		endpoint.validators = make(map[string]*conditionalValidator)
+	}
+
+	if len(etag) == 0 && len(lastModified) == 0 {
+		delete(endpoint.validators, url)
+		return
+	}
+
+	endpoint.validators[url] = &conditionalValidator{
+		ETag:         etag,
+		LastModified: lastModified,
+		Body:         body,
+		Headers:      headers,
+	}
+}
+
+func recordConditionalGet(name string, notModified bool) {
+	conditionalGetStatsLock.Lock()
+	defer conditionalGetStatsLock.Unlock()
+
+	stats, exists := conditionalGetStats[name]
+	if !exists {
+		stats = new(ConditionalGetStats)
+		conditionalGetStats[name] = stats
+	}
+
+	stats.Requests++
+	if notModified {
+		stats.NotModified++
+	}
+}
+
+// returns a copy of the conditional GET counters, keyed by scraper name
+func GetConditionalGetStats() map[string]ConditionalGetStats {
+	conditionalGetStatsLock.Lock()
+	defer conditionalGetStatsLock.Unlock()
+
+	statsCopy := make(map[string]ConditionalGetStats)
+	for name, stats := range conditionalGetStats {
+		statsCopy[name] = *stats
+	}
+
+	return statsCopy
+}
+
+func logConditionalGetStats() {
+	stats := GetConditionalGetStats()
+	if len(stats) == 0 {
+		return
+	}
+
+	names := make([]string, 0, len(stats))
+	for name := range stats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		LogFor(name).Infof("conditional GET: %d/%d not modified (%.0f%%)", stats[name].NotModified, stats[name].Requests, stats[name].HitRate()*100)
+	}
+}
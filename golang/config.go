@@ -37,6 +37,31 @@ type Config struct {
 	NotifyOnError         bool                     `yaml:"notify_on_error"`
 	DumpOutput            bool                     `yaml:"dump_output"`
 	DumpOutputS3          bool                     `yaml:"dump_output_s3"`
+	Timezone              string                   `yaml:"timezone"`
+	CacheMaxEntries       int                      `yaml:"cache_max_entries"`
+	CacheMaxBytes         int64                    `yaml:"cache_max_bytes"`
+	CacheBackend          string                   `yaml:"cache_backend"`
+	CacheDir              string                   `yaml:"cache_dir"`
+	MetricsAddr           string                   `yaml:"metrics_addr"`
+	StatusAddr            string                   `yaml:"status_addr"`
+	LogFormat             string                   `yaml:"log_format"`
+	TraceHistorySize      int                      `yaml:"trace_history_size"`
+	HistoryDir            string                   `yaml:"history_dir"`
+	DigestTime            string                   `yaml:"digest_time"`
+	StaleFactor           float64                  `yaml:"stale_factor"`
+	Notifiers             []NotifierConfig         `yaml:"notifiers"`
+	NotifyWindow          int64                    `yaml:"notify_window"`
+	FlapThreshold         int                      `yaml:"flap_threshold"`
+	ConfigUrl             string                   `yaml:"config_url"`
+	NotificationRoutes    []NotificationRoute      `yaml:"notification_routes"`
+	StatusSinks           []StatusSinkConfig       `yaml:"status_sinks"`
+	ApiVersion            int                      `yaml:"api_version"`
+	OutboxDir             string                   `yaml:"outbox_dir"`
+	ApiBatchUrl           string                   `yaml:"api_batch_url"`
+	ApiBatchWindow        int64                    `yaml:"api_batch_window"`
+	FeedDir               string                   `yaml:"feed_dir"`
+	FeedS3Bucket          string                   `yaml:"feed_s3_bucket"`
+	FeedAddr              string                   `yaml:"feed_addr"`
 }
 
 type ScraperConfig struct {
@@ -45,6 +70,12 @@ type ScraperConfig struct {
 	ApiKey             string                 `yaml:"api_key"`
 	AllowedStatusCodes []int                  `yaml:"allowed_status_codes"`
 	MinInterval        int64                  `yaml:"min_scrape_interval"`
+	Vars               map[string]string      `yaml:"vars"`
+	LogLevel           string                 `yaml:"log_level"`
+	Owners             []string               `yaml:"owners"` //emails of the volunteers looking after the scraper, see routing.go
+	Tags               []string               `yaml:"tags"`   //for notification routing, e.g. county or vendor
+	BookingUrl         string                 `yaml:"booking_url"`
+	Location           *GeoCoord              `yaml:"location"` //lat and lng for the public feed, if the scraper doesn't report them
 }
 
 func NewConfigDefaultPath() (*Config, error) {
@@ -74,6 +105,10 @@ func NewConfig(configPath string) (*Config, error) {
 		Log.SetLevel("debug")
 	}
 
+	if err := SetLogFormat(config.LogFormat); err != nil {
+		return nil, err
+	}
+
 	//replace host portion of url, usually for testing
 	hostOverride := os.Getenv(APIHostEnvName)
 	if len(hostOverride) > 0 {
@@ -0,0 +1,536 @@
+package csg
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+//daily digest of scraper health over the last 24h, sent at digest_time by the continuous runner or with "report --send".
+//scrape statistics are kept in hourly buckets, and written to history_dir/stats so separate processes (once runs, report) can share them.
+//each process writes its own file per hour, named after its run id, so processes running side by side never rewrite each other's counts
+
+const DigestWindow = 24 * time.Hour
+const DigestFlapThreshold = 4 //status changes in the window before a scraper is listed as flapping
+const DigestSlowestCount = 5
+const DigestSubject = "COVID WA - Daily scraper digest"
+const DigestTimeFormat = "15:04"
+const ScrapeStatsDir = "stats"
+const ScrapeStatsFileFormat = "2006-01-02T15" //one file per utc hour and run, e.g. 2021-04-01T10.20210401T101500-3fa2c1.json
+
+type ScrapeStats struct {
+	Scrapes         int    `json:"scrapes"`
+	Successes       int    `json:"successes"`
+	Possible        int    `json:"possible"` //successful scrapes returning Possible
+	Changes         int    `json:"changes"`
+	ApiSends        int    `json:"api_sends"`
+	ApiFailures     int    `json:"api_failures"`
+	TotalDurationMs int64  `json:"total_duration_ms"`
+	MaxDurationMs   int64  `json:"max_duration_ms"`
+	LastError       string `json:"last_error,omitempty"`
+}
+
+func (s *ScrapeStats) Add(other *ScrapeStats) {
+	s.Scrapes += other.Scrapes
+	s.Successes += other.Successes
+	s.Possible += other.Possible
+	s.Changes += other.Changes
+	s.ApiSends += other.ApiSends
+	s.ApiFailures += other.ApiFailures
+	s.TotalDurationMs += other.TotalDurationMs
+	if other.MaxDurationMs > s.MaxDurationMs {
+		s.MaxDurationMs = other.MaxDurationMs
+	}
+	if len(other.LastError) > 0 {
+		s.LastError = other.LastError
+	}
+}
+
+func (s *ScrapeStats) AverageDurationMs() int64 {
+	if s.Scrapes == 0 {
+		return 0
+	}
+	return s.TotalDurationMs / int64(s.Scrapes)
+}
+
+type scrapeStatsBuckets map[time.Time]map[string]*ScrapeStats
+
+var scrapeStats = make(scrapeStatsBuckets)        //everything recorded by this process within the window
+var pendingScrapeStats = make(scrapeStatsBuckets) //not yet written to history_dir, only kept when it is configured
+var scrapeStatsLock = new(sync.Mutex)
+
+func (b scrapeStatsBuckets) get(hour time.Time, name string) *ScrapeStats {
+	bucket, exists := b[hour]
+	if !exists {
+		bucket = make(map[string]*ScrapeStats)
+		b[hour] = bucket
+	}
+
+	stats, exists := bucket[name]
+	if !exists {
+		stats = new(ScrapeStats)
+		bucket[name] = stats
+	}
+
+	return stats
+}
+
+func updateScrapeStats(name string, update func(stats *ScrapeStats)) {
+	hour := time.Now().UTC().Truncate(time.Hour)
+
+	scrapeStatsLock.Lock()
+	defer scrapeStatsLock.Unlock()
+
+	update(scrapeStats.get(hour, name))
+	if History.Enabled() {
+		update(pendingScrapeStats.get(hour, name))
+	}
+
+	for bucketHour := range scrapeStats {
+		if hour.Sub(bucketHour) > DigestWindow {
+			delete(scrapeStats, bucketHour)
+		}
+	}
+}
+
+// called by doScrapeAndSend after every scrape
+func recordScrapeStats(name string, duration time.Duration, status Status, err error, changed bool) {
+	updateScrapeStats(name, func(stats *ScrapeStats) {
+		stats.Scrapes++
+		stats.TotalDurationMs += duration.Milliseconds()
+		if duration.Milliseconds() > stats.MaxDurationMs {
+			stats.MaxDurationMs = duration.Milliseconds()
+		}
+
+		if err != nil {
+			stats.LastError = fmt.Sprintf("%s error: %s", ErrorClass(err), redactApiSecret(err.Error()))
+		} else {
+			stats.Successes++
+			if status == StatusPossible {
+				stats.Possible++
+			}
+		}
+
+		if changed {
+			stats.Changes++
+		}
+	})
+}
+
+func recordApiSendStats(name string, sent bool) {
+	updateScrapeStats(name, func(stats *ScrapeStats) {
+		stats.ApiSends++
+		if !sent {
+			stats.ApiFailures++
+		}
+	})
+}
+
+// an update that failed after it was counted by recordApiSendStats, e.g. in a batch
+func recordApiSendFailure(name string) {
+	updateScrapeStats(name, func(stats *ScrapeStats) {
+		stats.ApiFailures++
+	})
+}
+
+// the file this process adds its statistics for the hour to
+func scrapeStatsPath(hour time.Time) string {
+	return filepath.Join(History.dir, ScrapeStatsDir, hour.UTC().Format(ScrapeStatsFileFormat)+"."+CurrentRunId+".json")
+}
+
+// the files of every process for the hour
+func scrapeStatsPaths(hour time.Time) ([]string, error) {
+	return filepath.Glob(filepath.Join(History.dir, ScrapeStatsDir, hour.UTC().Format(ScrapeStatsFileFormat)+".*json"))
+}
+
+func readScrapeStatsFile(filePath string) (map[string]*ScrapeStats, error) {
+	stats := make(map[string]*ScrapeStats)
+
+	jsonBytes, err := ioutil.ReadFile(filePath)
+	if os.IsNotExist(err) {
+		return stats, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(jsonBytes, &stats)
+	return stats, err
+}
+
+// adds pending statistics to this process's hourly files in history_dir, no-op if history_dir isn't configured
+func flushScrapeStats() error {
+	if !History.Enabled() {
+		return nil
+	}
+
+	scrapeStatsLock.Lock()
+	pending := pendingScrapeStats
+	pendingScrapeStats = make(scrapeStatsBuckets)
+	scrapeStatsLock.Unlock()
+
+	if err := os.MkdirAll(filepath.Join(History.dir, ScrapeStatsDir), 0755); err != nil {
+		return err
+	}
+
+	History.lock.Lock()
+	defer History.lock.Unlock()
+
+	for hour, bucket := range pending {
+		filePath := scrapeStatsPath(hour)
+		stats, err := readScrapeStatsFile(filePath)
+		if err != nil {
+			return err
+		}
+
+		for name, delta := range bucket {
+			if _, exists := stats[name]; !exists {
+				stats[name] = new(ScrapeStats)
+			}
+			stats[name].Add(delta)
+		}
+
+		jsonBytes, err := json.Marshal(stats)
+		if err != nil {
+			return err
+		}
+
+		tempPath := filePath + ".tmp"
+		if err = ioutil.WriteFile(tempPath, jsonBytes, 0644); err != nil {
+			return err
+		}
+		if err = os.Rename(tempPath, filePath); err != nil {
+			return err
+		}
+	}
+
+	pruneScrapeStats(time.Now())
+	return nil
+}
+
+// removes the hourly files of every process that are too old for a digest, called with History.lock held
+func pruneScrapeStats(now time.Time) {
+	dir := filepath.Join(History.dir, ScrapeStatsDir)
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		Log.Warnf("Can't read %s: %v", dir, err)
+		return
+	}
+
+	oldest := now.UTC().Add(-DigestWindow).Truncate(time.Hour)
+	for _, file := range files {
+		hour, err := time.Parse(ScrapeStatsFileFormat, strings.SplitN(file.Name(), ".", 2)[0])
+		if err != nil || !hour.Before(oldest) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, file.Name())); err != nil && !os.IsNotExist(err) {
+			Log.Warnf("Can't remove old scrape stats: %v", err)
+		}
+	}
+}
+
+// statistics per scraper for the hours overlapping since..until, from history_dir if configured, otherwise from memory
+func loadScrapeStats(since time.Time, until time.Time) (map[string]*ScrapeStats, error) {
+	totals := make(map[string]*ScrapeStats)
+	add := func(bucket map[string]*ScrapeStats) {
+		for name, stats := range bucket {
+			if _, exists := totals[name]; !exists {
+				totals[name] = new(ScrapeStats)
+			}
+			totals[name].Add(stats)
+		}
+	}
+
+	if History.Enabled() {
+		if err := flushScrapeStats(); err != nil {
+			return nil, err
+		}
+
+		for hour := since.UTC().Truncate(time.Hour); !hour.After(until); hour = hour.Add(time.Hour) {
+			filePaths, err := scrapeStatsPaths(hour)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, filePath := range filePaths {
+				History.lock.Lock()
+				bucket, err := readScrapeStatsFile(filePath)
+				History.lock.Unlock()
+				if err != nil {
+					return nil, err
+				}
+				add(bucket)
+			}
+		}
+
+		return totals, nil
+	}
+
+	scrapeStatsLock.Lock()
+	defer scrapeStatsLock.Unlock()
+	for hour, bucket := range scrapeStats {
+		if !hour.Before(since.UTC().Truncate(time.Hour)) && !hour.After(until) {
+			add(bucket)
+		}
+	}
+
+	return totals, nil
+}
+
+type DigestEntry struct {
+	Name  string
+	Stats ScrapeStats
+}
+
+type Digest struct {
+	Since          time.Time
+	Until          time.Time
+	RunId          string
+	Scrapers       int
+	NeverSucceeded []DigestEntry
+	StuckPossible  []DigestEntry
+	Flapping       []DigestEntry
+	Slowest        []DigestEntry
+	ApiFailures    []DigestEntry
+	Uncovered      []Clinic
+	CoverageError  string
+}
+
+// builds the digest for the window ending at until
+func BuildDigest(scrapeContexts []*ScrapeAndSendContext, until time.Time) (*Digest, error) {
+	digest := &Digest{Since: until.Add(-DigestWindow), Until: until, RunId: CurrentRunId}
+
+	stats, err := loadScrapeStats(digest.Since, until)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(stats))
+	for name := range stats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	digest.Scrapers = len(names)
+
+	entries := make([]DigestEntry, 0, len(names))
+	for _, name := range names {
+		entry := DigestEntry{Name: name, Stats: *stats[name]}
+		entries = append(entries, entry)
+
+		if entry.Stats.Scrapes > 0 && entry.Stats.Successes == 0 {
+			digest.NeverSucceeded = append(digest.NeverSucceeded, entry)
+		}
+		if entry.Stats.Successes > 0 && entry.Stats.Possible == entry.Stats.Successes {
+			digest.StuckPossible = append(digest.StuckPossible, entry)
+		}
+		if entry.Stats.Changes >= DigestFlapThreshold {
+			digest.Flapping = append(digest.Flapping, entry)
+		}
+		if entry.Stats.ApiFailures > 0 {
+			digest.ApiFailures = append(digest.ApiFailures, entry)
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Stats.AverageDurationMs() > entries[j].Stats.AverageDurationMs()
+	})
+	for idx := 0; idx < len(entries) && idx < DigestSlowestCount; idx++ {
+		if entries[idx].Stats.Scrapes > 0 {
+			digest.Slowest = append(digest.Slowest, entries[idx])
+		}
+	}
+
+	digest.Uncovered, err = uncoveredClinics(scrapeContexts)
+	if err != nil {
+		digest.CoverageError = err.Error()
+	}
+
+	return digest, nil
+}
+
+// airtable locations whose key isn't used by any configured scraper
+func uncoveredClinics(scrapeContexts []*ScrapeAndSendContext) ([]Clinic, error) {
+	clinics, err := GetClinicsByKeyPattern(regexp.MustCompile(".*"))
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]bool)
+	for _, ctx := range scrapeContexts {
+		keys[ctx.Config.ApiKey] = true
+	}
+
+	uncovered := make([]Clinic, 0)
+	for _, clinic := range clinics {
+		if !keys[clinic.ApiKey] {
+			uncovered = append(uncovered, clinic)
+		}
+	}
+	sort.Slice(uncovered, func(i, j int) bool {
+		return uncovered[i].Name < uncovered[j].Name
+	})
+
+	return uncovered, nil
+}
+
+func (d *Digest) Text() string {
+	sb := strings.Builder{}
+	loc := templateLocation()
+
+	sb.WriteString(fmt.Sprintf("Scraper digest %s - %s (run %s)\r\n", d.Since.In(loc).Format(HistoryTimeFormat), d.Until.In(loc).Format(HistoryTimeFormat), d.RunId))
+	sb.WriteString(fmt.Sprintf("%d scraper(s) reported\r\n", d.Scrapers))
+
+	section := func(title string, entries []DigestEntry, describe func(entry DigestEntry) string) {
+		sb.WriteString(fmt.Sprintf("\r\n%s (%d)\r\n", title, len(entries)))
+		for _, entry := range entries {
+			sb.WriteString(fmt.Sprintf("  %s: %s\r\n", entry.Name, describe(entry)))
+		}
+	}
+
+	section("Never succeeded", d.NeverSucceeded, func(entry DigestEntry) string {
+		return fmt.Sprintf("%d scrape(s), last %s", entry.Stats.Scrapes, entry.Stats.LastError)
+	})
+	section("Stuck in Possible", d.StuckPossible, func(entry DigestEntry) string {
+		return fmt.Sprintf("%d scrape(s)", entry.Stats.Successes)
+	})
+	section("Flapping", d.Flapping, func(entry DigestEntry) string {
+		return fmt.Sprintf("%d status change(s)", entry.Stats.Changes)
+	})
+	section("Slowest", d.Slowest, func(entry DigestEntry) string {
+		return fmt.Sprintf("avg %dms, max %dms", entry.Stats.AverageDurationMs(), entry.Stats.MaxDurationMs)
+	})
+	section("API send failures", d.ApiFailures, func(entry DigestEntry) string {
+		return fmt.Sprintf("%d/%d failed", entry.Stats.ApiFailures, entry.Stats.ApiSends)
+	})
+
+	if len(d.CoverageError) > 0 {
+		sb.WriteString(fmt.Sprintf("\r\nLocations without a scraper: unavailable (%s)\r\n", d.CoverageError))
+	} else {
+		sb.WriteString(fmt.Sprintf("\r\nLocations without a scraper (%d)\r\n", len(d.Uncovered)))
+		for _, clinic := range d.Uncovered {
+			sb.WriteString(fmt.Sprintf("  %s (%s)\r\n", clinic.Name, clinic.ApiKey))
+		}
+	}
+
+	return sb.String()
+}
+
+var digestHtmlTemplate = template.Must(template.New("digest").Funcs(template.FuncMap{
+	"time": func(t time.Time) string {
+		return t.In(templateLocation()).Format(HistoryTimeFormat)
+	},
+}).Parse(`<html><body>
+<h2>Scraper digest {{ time .Since }} - {{ time .Until }}</h2>
+<p>{{ .Scrapers }} scraper(s) reported, run {{ .RunId }}</p>
+<h3>Never succeeded ({{ len .NeverSucceeded }})</h3>
+<ul>{{ range .NeverSucceeded }}<li><b>{{ .Name }}</b>: {{ .Stats.Scrapes }} scrape(s), last {{ .Stats.LastError }}</li>{{ end }}</ul>
+<h3>Stuck in Possible ({{ len .StuckPossible }})</h3>
+<ul>{{ range .StuckPossible }}<li><b>{{ .Name }}</b>: {{ .Stats.Successes }} scrape(s)</li>{{ end }}</ul>
+<h3>Flapping ({{ len .Flapping }})</h3>
+<ul>{{ range .Flapping }}<li><b>{{ .Name }}</b>: {{ .Stats.Changes }} status change(s)</li>{{ end }}</ul>
+<h3>Slowest ({{ len .Slowest }})</h3>
+<ul>{{ range .Slowest }}<li><b>{{ .Name }}</b>: avg {{ .Stats.AverageDurationMs }}ms, max {{ .Stats.MaxDurationMs }}ms</li>{{ end }}</ul>
+<h3>API send failures ({{ len .ApiFailures }})</h3>
+<ul>{{ range .ApiFailures }}<li><b>{{ .Name }}</b>: {{ .Stats.ApiFailures }}/{{ .Stats.ApiSends }} failed</li>{{ end }}</ul>
+{{ if .CoverageError }}<h3>Locations without a scraper</h3>
+<p>Unavailable ({{ .CoverageError }})</p>
+{{ else }}<h3>Locations without a scraper ({{ len .Uncovered }})</h3>
+<ul>{{ range .Uncovered }}<li>{{ .Name }} ({{ .ApiKey }})</li>{{ end }}</ul>
+{{ end }}</body></html>
+`))
+
+func (d *Digest) Html() (string, error) {
+	var buf bytes.Buffer
+	if err := digestHtmlTemplate.Execute(&buf, d); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func notifyDigest(digest *Digest) error {
+	html, err := digest.Html()
+	if err != nil {
+		return err
+	}
+
+	notification := NewNotification(NotificationKindDigest, "", DigestSubject, digest.Text())
+	notification.Html = html
+	return sendNotification(notification)
+}
+
+// next time the digest is due after now, digest_time is HH:MM in the configured timezone
+func nextDigestTime(digestTime string, now time.Time) (time.Time, error) {
+	clock, err := time.Parse(DigestTimeFormat, digestTime)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("Can't parse digest_time '%s', expecting HH:MM", digestTime)
+	}
+
+	local := now.In(templateLocation())
+	next := time.Date(local.Year(), local.Month(), local.Day(), clock.Hour(), clock.Minute(), 0, 0, local.Location())
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+
+	return next, nil
+}
+
+func sendDigest(scrapeContexts []*ScrapeAndSendContext) {
+	digest, err := BuildDigest(scrapeContexts, time.Now())
+	if err != nil {
+		Log.Errorf("Can't build digest: %v", err)
+		return
+	}
+
+	if err = notifyDigest(digest); err != nil {
+		Log.Errorf("%+v", err)
+	}
+}
+
+// report [--send] [--html]
+func runReportCommand(args []string, scrapeContexts []*ScrapeAndSendContext) error {
+	var send, html bool
+	for _, arg := range args[2:] {
+		switch arg {
+		case "--send":
+			send = true
+		case "--html":
+			html = true
+		default:
+			return fmt.Errorf("Unknown argument: %s", arg)
+		}
+	}
+
+	//a new process has no statistics of its own, they come from the runs that wrote them to history_dir
+	if !History.Enabled() {
+		return fmt.Errorf("report needs history_dir to be configured")
+	}
+
+	digest, err := BuildDigest(scrapeContexts, time.Now())
+	if err != nil {
+		return err
+	}
+
+	if html {
+		htmlStr, err := digest.Html()
+		if err != nil {
+			return err
+		}
+		fmt.Println(htmlStr)
+	} else {
+		fmt.Print(strings.ReplaceAll(digest.Text(), "\r\n", "\n"))
+	}
+
+	if send {
+		return notifyDigest(digest)
+	}
+
+	return nil
+}
@@ -0,0 +1,167 @@
+package csg
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func digestEntryNames(entries []DigestEntry) string {
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name, "digest_") {
+			names = append(names, entry.Name)
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+func TestBuildDigest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"stamp":0,"data":[{"name":"Covered","key":"digest_ok"},{"name":"Uncovered & Co","key":"digest_missing"}]}`))
+	}))
+	defer server.Close()
+
+	prevConfig := config
+	config = &Config{ApiInternalUrl: server.URL}
+	defer func() { config = prevConfig }()
+
+	for idx := 0; idx < 5; idx++ {
+		recordScrapeStats("digest_ok", time.Second, StatusNo, nil, false)
+		recordScrapeStats("digest_broken", 2*time.Second, StatusUnknown, NewHttpStatusError(500, "http://foo", nil), false)
+		recordScrapeStats("digest_possible", time.Millisecond, StatusPossible, nil, false)
+		recordScrapeStats("digest_flappy", 3*time.Second, StatusYes, nil, true)
+		recordApiSendStats("digest_flappy", idx > 0)
+	}
+
+	ctx, _ := newStatusApiTestContext(&ScraperConfig{ApiKey: "digest_ok"})
+	digest, err := BuildDigest([]*ScrapeAndSendContext{ctx}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		"never succeeded":   "digest_broken",
+		"stuck in possible": "digest_possible",
+		"flapping":          "digest_flappy",
+		"api failures":      "digest_flappy",
+	}
+	actual := map[string]string{
+		"never succeeded":   digestEntryNames(digest.NeverSucceeded),
+		"stuck in possible": digestEntryNames(digest.StuckPossible),
+		"flapping":          digestEntryNames(digest.Flapping),
+		"api failures":      digestEntryNames(digest.ApiFailures),
+	}
+	for section, names := range expected {
+		if actual[section] != names {
+			t.Errorf("%s: expected %s, got %s", section, names, actual[section])
+		}
+	}
+
+	if len(digest.Slowest) == 0 || digest.Slowest[0].Name != "digest_flappy" {
+		t.Errorf("Expected digest_flappy to be the slowest: %+v", digest.Slowest)
+	}
+	if len(digest.CoverageError) > 0 || len(digest.Uncovered) != 1 || digest.Uncovered[0].ApiKey != "digest_missing" {
+		t.Errorf("Unexpected coverage: %+v (%s)", digest.Uncovered, digest.CoverageError)
+	}
+
+	if text := digest.Text(); !strings.Contains(text, "digest_broken: 5 scrape(s), last http_status error") {
+		t.Errorf("Unexpected text digest: %s", text)
+	}
+	html, err := digest.Html()
+	if err != nil || !strings.Contains(html, "Uncovered &amp; Co (digest_missing)") {
+		t.Errorf("Unexpected html digest (%v): %s", err, html)
+	}
+}
+
+func TestNextDigestTime(t *testing.T) {
+	prevConfig := config
+	config = &Config{Timezone: "UTC"}
+	defer func() { config = prevConfig }()
+
+	now := time.Date(2021, 4, 1, 9, 30, 0, 0, time.UTC)
+	tests := map[string]time.Time{
+		"08:00": time.Date(2021, 4, 2, 8, 0, 0, 0, time.UTC),
+		"10:15": time.Date(2021, 4, 1, 10, 15, 0, 0, time.UTC),
+	}
+
+	for digestTime, expected := range tests {
+		next, err := nextDigestTime(digestTime, now)
+		if err != nil || !next.Equal(expected) {
+			t.Errorf("%s: expected %v, got %v (%v)", digestTime, expected, next, err)
+		}
+	}
+
+	if _, err := nextDigestTime("8am", now); err == nil {
+		t.Errorf("Expected error for malformed digest_time")
+	}
+}
+
+func TestFlushScrapeStats(t *testing.T) {
+	dir, err := ioutil.TempDir("", "stats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	prevHistory := History
+	History = NewHistoryStore(dir)
+	defer func() { History = prevHistory }()
+
+	prevRunId := CurrentRunId
+	defer func() { CurrentRunId = prevRunId }()
+
+	//files too old for a digest are removed on the next flush
+	CurrentRunId = "old"
+	oldPath := scrapeStatsPath(time.Now().Add(-DigestWindow - 2*time.Hour).Truncate(time.Hour))
+	os.MkdirAll(filepath.Dir(oldPath), 0755)
+	if err := ioutil.WriteFile(oldPath, []byte(`{"digest_flushed": {"scrapes": 1}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	//flushes from three runs writing to the same dir add up
+	for _, runId := range []string{"old", "run1", "run2"} {
+		CurrentRunId = runId
+		recordScrapeStats("digest_flushed", time.Second, StatusNo, nil, false)
+		if err := flushScrapeStats(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if filePaths, err := scrapeStatsPaths(time.Now()); err != nil || len(filePaths) != 3 {
+		t.Errorf("Expected a stats file per run, got %v (%v)", filePaths, err)
+	}
+	if _, err := os.Stat(oldPath); !os.IsNotExist(err) {
+		t.Errorf("Expected old stats to be pruned: %v", err)
+	}
+
+	stats, err := loadScrapeStats(time.Now().Add(-time.Hour), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats["digest_flushed"] == nil || stats["digest_flushed"].Scrapes != 3 || stats["digest_flushed"].TotalDurationMs != 3000 {
+		t.Errorf("Unexpected flushed stats: %+v", stats["digest_flushed"])
+	}
+}
+
+func TestScrapeStatsWithoutHistory(t *testing.T) {
+	prevHistory := History
+	History = NewHistoryStore("")
+	defer func() { History = prevHistory }()
+
+	recordScrapeStats("digest_unflushed", time.Second, StatusNo, nil, false)
+	scrapeStatsLock.Lock()
+	pending := len(pendingScrapeStats)
+	scrapeStatsLock.Unlock()
+	if pending != 0 {
+		t.Errorf("Expected nothing pending without history_dir, got %d hours", pending)
+	}
+
+	if err := runReportCommand([]string{"covidwa-scrapers-go", "report"}, nil); err == nil {
+		t.Errorf("Expected report to fail without history_dir")
+	}
+}
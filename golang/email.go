@@ -0,0 +1,437 @@
+package csg
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+//smtp notification channel, sends multipart text/html messages rendered from templates.
+//the templates get the Notification, grouped notifications have the rest under .Notifications
+
+const SmtpTlsStartTls = "starttls" //upgrade when the server offers it, usually port 587
+const SmtpTlsImplicit = "implicit" //tls from the start, usually port 465
+const SmtpTlsNone = "none"
+
+const DefaultEmailTextTemplate = `{{define "details"}}{{if .Scraper}}Scraper: {{.Scraper}}
+{{if .OldStatus}}Status: {{.OldStatus}} -> {{.Status}}
+{{else if .Status}}Status: {{.Status}}
+{{end}}{{if .Tags}}Tags: {{join .Tags ", "}}
+{{end}}{{if .ApiKey}}Api key: {{.ApiKey}}
+{{end}}{{if .DumpUrl}}Output: {{.DumpUrl}}
+{{end}}{{if .ConfigUrl}}Config: {{.ConfigUrl}}
+{{end}}{{end}}{{end}}
+{{- if .Notifications}}{{range .Notifications}}{{.Body}}
+{{template "details" .}}
+{{end}}{{else}}{{.Body}}
+{{template "details" .}}{{end}}`
+
+const DefaultEmailHtmlTemplate = `{{define "details"}}<p>{{lines .Body}}</p>
+{{if .Scraper}}<table>
+<tr><td>Scraper</td><td>{{.Scraper}}</td></tr>
+{{if .OldStatus}}<tr><td>Status</td><td>{{.OldStatus}} &rarr; <b>{{.Status}}</b></td></tr>
+{{else if .Status}}<tr><td>Status</td><td><b>{{.Status}}</b></td></tr>
+{{end}}{{if .Tags}}<tr><td>Tags</td><td>{{join .Tags ", "}}</td></tr>
+{{end}}{{if .ApiKey}}<tr><td>Api key</td><td>{{.ApiKey}}</td></tr>
+{{end}}{{if .DumpUrl}}<tr><td>Output</td><td>{{if isUrl .DumpUrl}}<a href="{{.DumpUrl}}">{{.DumpUrl}}</a>{{else}}{{.DumpUrl}}{{end}}</td></tr>
+{{end}}{{if .ConfigUrl}}<tr><td>Config</td><td><a href="{{.ConfigUrl}}">{{.ConfigUrl}}</a></td></tr>
+{{end}}</table>
+{{end}}{{end}}<html><body>
+{{if .Notifications}}{{range .Notifications}}{{template "details" .}}<hr>
+{{end}}{{else}}{{template "details" .}}{{end}}</body></html>
+`
+
+var emailTemplateFuncs = map[string]interface{}{
+	"join": strings.Join,
+	"isUrl": func(value string) bool {
+		return strings.HasPrefix(value, "http://") || strings.HasPrefix(value, "https://")
+	},
+	"lines": func(value string) htmltemplate.HTML {
+		lines := strings.Split(strings.ReplaceAll(value, "\r\n", "\n"), "\n")
+		for idx := range lines {
+			lines[idx] = htmltemplate.HTMLEscapeString(lines[idx])
+		}
+		return htmltemplate.HTML(strings.Join(lines, "<br>\n"))
+	},
+}
+
+type SmtpNotifier struct {
+	name         string
+	host         string
+	port         int
+	tlsMode      string
+	insecure     bool
+	auth         smtp.Auth
+	from         *mail.Address
+	to           []*mail.Address
+	timeout      time.Duration
+	textTemplate *template.Template
+	htmlTemplate *htmltemplate.Template
+}
+
+// failures of individual recipients, the message was delivered to the other Delivered recipients
+type SmtpRecipientError struct {
+	Failed    map[string]error
+	Delivered int
+}
+
+func (e *SmtpRecipientError) Error() string {
+	addrs := make([]string, 0, len(e.Failed))
+	for addr := range e.Failed {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+
+	failures := make([]string, 0, len(addrs))
+	for _, addr := range addrs {
+		failures = append(failures, fmt.Sprintf("%s: %v", addr, e.Failed[addr]))
+	}
+	return fmt.Sprintf("%d recipient(s) failed, %d delivered: %s", len(e.Failed), e.Delivered, strings.Join(failures, ", "))
+}
+
+// only worth retrying if nobody got the message and the server said to try again later
+func (e *SmtpRecipientError) Temporary() bool {
+	if e.Delivered > 0 {
+		return false
+	}
+	for _, err := range e.Failed {
+		var protoErr *textproto.Error
+		if errors.As(err, &protoErr) && protoErr.Code >= 400 && protoErr.Code < 500 {
+			return true
+		}
+	}
+	return false
+}
+
+// smtp fields not set on the notifier default to the top level ones
+func NewSmtpNotifier(config *Config, notifierConfig NotifierConfig, timeout time.Duration) (*SmtpNotifier, error) {
+	notifier := &SmtpNotifier{
+		name:     notifierConfig.Name,
+		host:     firstNonEmpty(notifierConfig.SmtpHost, config.SmtpHost),
+		port:     notifierConfig.SmtpPort,
+		tlsMode:  firstNonEmpty(notifierConfig.SmtpTls, SmtpTlsStartTls),
+		insecure: notifierConfig.SmtpInsecure,
+		timeout:  timeout,
+	}
+	if len(notifier.host) == 0 {
+		return nil, fmt.Errorf("smtp_host is not configured")
+	}
+
+	if notifier.port == 0 {
+		notifier.port = config.SmtpPort
+	}
+	if notifier.port == 0 {
+		switch notifier.tlsMode {
+		case SmtpTlsImplicit:
+			notifier.port = 465
+		case SmtpTlsNone:
+			notifier.port = 25
+		default:
+			notifier.port = 587
+		}
+	}
+
+	switch notifier.tlsMode {
+	case SmtpTlsStartTls, SmtpTlsImplicit, SmtpTlsNone:
+	default:
+		return nil, fmt.Errorf("Unknown smtp_tls: %s, expecting %s, %s or %s", notifier.tlsMode, SmtpTlsStartTls, SmtpTlsImplicit, SmtpTlsNone)
+	}
+
+	username := firstNonEmpty(notifierConfig.SmtpUsername, config.SmtpUsername)
+	if len(username) > 0 {
+		notifier.auth = smtp.PlainAuth("", username, firstNonEmpty(notifierConfig.SmtpPassword, config.SmtpPassword), notifier.host)
+		if notifier.insecure {
+			notifier.auth = insecurePlainAuth{notifier.auth}
+		}
+	}
+
+	//a missing from address or recipients only fails sending, as before
+	if from := firstNonEmpty(notifierConfig.FromEmailAddress, config.FromEmailAddress); len(from) > 0 {
+		address, err := mail.ParseAddress(from)
+		if err != nil {
+			return nil, fmt.Errorf("from_email_address: %v", err)
+		}
+		notifier.from = address
+	}
+
+	to := notifierConfig.NotifyEmailAddrs
+	if len(to) == 0 {
+		to = config.NotifyEmailAddrs
+	}
+	for _, addr := range to {
+		address, err := mail.ParseAddress(addr)
+		if err != nil {
+			return nil, fmt.Errorf("notify_email_addrs: %v", err)
+		}
+		notifier.to = append(notifier.to, address)
+	}
+
+	textTemplate, err := readEmailTemplate(notifierConfig.TextTemplate, DefaultEmailTextTemplate)
+	if err != nil {
+		return nil, err
+	}
+	if notifier.textTemplate, err = template.New("text").Funcs(emailTemplateFuncs).Parse(textTemplate); err != nil {
+		return nil, fmt.Errorf("text_template: %v", err)
+	}
+
+	htmlTemplate, err := readEmailTemplate(notifierConfig.HtmlTemplate, DefaultEmailHtmlTemplate)
+	if err != nil {
+		return nil, err
+	}
+	if notifier.htmlTemplate, err = htmltemplate.New("html").Funcs(emailTemplateFuncs).Parse(htmlTemplate); err != nil {
+		return nil, fmt.Errorf("html_template: %v", err)
+	}
+
+	return notifier, nil
+}
+
+func readEmailTemplate(path string, defaultTemplate string) (string, error) {
+	if len(path) == 0 {
+		return defaultTemplate, nil
+	}
+
+	templateBytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return string(templateBytes), nil
+}
+
+func (n *SmtpNotifier) Name() string {
+	return n.name
+}
+
+// text and html bodies, notifications with their own html (the digest) are sent as is
+func (n *SmtpNotifier) render(notification *Notification) (string, string, error) {
+	if len(notification.Html) > 0 {
+		return notification.Body, notification.Html, nil
+	}
+
+	var text, html bytes.Buffer
+	if err := n.textTemplate.Execute(&text, notification); err != nil {
+		return "", "", err
+	}
+	if err := n.htmlTemplate.Execute(&html, notification); err != nil {
+		return "", "", err
+	}
+	return text.String(), html.String(), nil
+}
+
+// the notification's own recipients (a scraper's owners) or the configured ones
+func (n *SmtpNotifier) recipients(notification *Notification) ([]*mail.Address, error) {
+	if len(notification.Recipients) == 0 {
+		return n.to, nil
+	}
+
+	to := make([]*mail.Address, 0, len(notification.Recipients))
+	for _, addr := range notification.Recipients {
+		address, err := mail.ParseAddress(addr)
+		if err != nil {
+			return nil, fmt.Errorf("owner %s: %v", addr, err)
+		}
+		to = append(to, address)
+	}
+	return to, nil
+}
+
+func (n *SmtpNotifier) messageId() string {
+	domain := n.host
+	if at := strings.LastIndex(n.from.Address, "@"); at >= 0 {
+		domain = n.from.Address[at+1:]
+	}
+	return fmt.Sprintf("<%s.%s@%s>", CurrentRunId, randomHex(8), domain)
+}
+
+// RFC 5322 message with a quoted-printable multipart/alternative body
+func (n *SmtpNotifier) message(notification *Notification, to []*mail.Address) ([]byte, error) {
+	text, html, err := n.render(notification)
+	if err != nil {
+		return nil, err
+	}
+
+	var parts bytes.Buffer
+	writer := multipart.NewWriter(&parts)
+	for _, part := range []struct{ contentType, content string }{{"text/plain", text}, {"text/html", html}} {
+		partWriter, err := writer.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType + "; charset=utf-8"},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		qpWriter := quotedprintable.NewWriter(partWriter)
+		if _, err = qpWriter.Write([]byte(crlf(part.content))); err != nil {
+			return nil, err
+		}
+		if err = qpWriter.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err = writer.Close(); err != nil {
+		return nil, err
+	}
+
+	date := notification.Time
+	if date.IsZero() {
+		date = time.Now()
+	}
+
+	toStrs := make([]string, 0, len(to))
+	for _, addr := range to {
+		toStrs = append(toStrs, addr.String())
+	}
+
+	var msg bytes.Buffer
+	header := func(key string, value string) {
+		msg.WriteString(fmt.Sprintf("%s: %s\r\n", key, value))
+	}
+	header("From", n.from.String())
+	header("To", strings.Join(toStrs, ", "))
+	header("Subject", mime.QEncoding.Encode("utf-8", notification.Subject))
+	header("Date", date.Format(time.RFC1123Z))
+	header("Message-ID", n.messageId())
+	header("MIME-Version", "1.0")
+	header("Content-Type", fmt.Sprintf("multipart/alternative; boundary=%s", writer.Boundary()))
+	msg.WriteString("\r\n")
+	msg.Write(parts.Bytes())
+
+	return msg.Bytes(), nil
+}
+
+func crlf(value string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(value, "\r\n", "\n"), "\n", "\r\n")
+}
+
+func (n *SmtpNotifier) dial() (*smtp.Client, error) {
+	addr := net.JoinHostPort(n.host, strconv.Itoa(n.port))
+	dialer := &net.Dialer{Timeout: n.timeout}
+	tlsConfig := &tls.Config{ServerName: n.host, InsecureSkipVerify: n.insecure}
+
+	var conn net.Conn
+	var err error
+	if n.tlsMode == SmtpTlsImplicit {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(n.timeout))
+
+	client, err := smtp.NewClient(conn, n.host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if n.tlsMode == SmtpTlsStartTls {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err = client.StartTLS(tlsConfig); err != nil {
+				client.Close()
+				return nil, err
+			}
+		}
+	}
+
+	return client, nil
+}
+
+// delivers to every recipient the server accepts, returning a SmtpRecipientError for the rest
+func (n *SmtpNotifier) Notify(notification *Notification) error {
+	if n.from == nil {
+		return fmt.Errorf("from_email_address is not configured")
+	}
+	to, err := n.recipients(notification)
+	if err != nil {
+		return err
+	}
+	if len(to) == 0 {
+		return fmt.Errorf("notify_email_addrs is not configured")
+	}
+
+	msg, err := n.message(notification, to)
+	if err != nil {
+		return err
+	}
+
+	client, err := n.dial()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if n.auth != nil {
+		if ok, _ := client.Extension("AUTH"); ok {
+			if err = client.Auth(n.auth); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err = client.Mail(n.from.Address); err != nil {
+		return err
+	}
+
+	recipientErr := &SmtpRecipientError{Failed: make(map[string]error)}
+	accepted := 0
+	for _, addr := range to {
+		if err = client.Rcpt(addr.Address); err != nil {
+			Log.Warnf("notifier %s: recipient %s rejected: %v", n.name, addr.Address, err)
+			recipientErr.Failed[addr.Address] = err
+		} else {
+			accepted++
+		}
+	}
+	if accepted == 0 {
+		return recipientErr
+	}
+
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err = writer.Write(msg); err != nil {
+		return err
+	}
+	if err = writer.Close(); err != nil {
+		return err
+	}
+	recipientErr.Delivered = accepted
+
+	if err = client.Quit(); err != nil {
+		Log.Warnf("notifier %s: %v", n.name, err)
+	}
+
+	if len(recipientErr.Failed) > 0 {
+		return recipientErr
+	}
+	return nil
+}
+
+// smtp.PlainAuth refuses to send credentials without tls to anything but localhost
+type insecurePlainAuth struct {
+	smtp.Auth
+}
+
+func (a insecurePlainAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	tlsServer := *server
+	tlsServer.TLS = true
+	return a.Auth.Start(&tlsServer)
+}
@@ -0,0 +1,151 @@
+package csg
+
+import (
+	"bufio"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+)
+
+// minimal smtp stand-in, rejects recipients starting with "bad" and records the messages it accepts
+func startTestSmtpServer(t *testing.T) (net.Listener, chan string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	messages := make(chan string, 10)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go func(conn net.Conn) {
+				defer conn.Close()
+				reader := bufio.NewReader(conn)
+				reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+				reply("220 localhost ready")
+				for {
+					line, err := reader.ReadString('\n')
+					if err != nil {
+						return
+					}
+					command := strings.ToUpper(strings.TrimSpace(line))
+					switch {
+					case strings.HasPrefix(command, "EHLO"):
+						reply("250-localhost")
+						reply("250 AUTH PLAIN")
+					case strings.HasPrefix(command, "AUTH"):
+						reply("235 ok")
+					case strings.HasPrefix(command, "RCPT TO:<BAD"):
+						reply("550 no such user")
+					case strings.HasPrefix(command, "MAIL"), strings.HasPrefix(command, "RCPT"):
+						reply("250 ok")
+					case command == "DATA":
+						reply("354 go ahead")
+						var data strings.Builder
+						for {
+							dataLine, err := reader.ReadString('\n')
+							if err != nil || dataLine == ".\r\n" {
+								break
+							}
+							data.WriteString(dataLine)
+						}
+						messages <- data.String()
+						reply("250 queued")
+					case command == "QUIT":
+						reply("221 bye")
+						return
+					default:
+						reply("250 ok")
+					}
+				}
+			}(conn)
+		}
+	}()
+
+	return listener, messages
+}
+
+func TestSmtpNotifier(t *testing.T) {
+	listener, messages := startTestSmtpServer(t)
+	defer listener.Close()
+
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	notifierConfig := NotifierConfig{
+		Name:             "email",
+		SmtpHost:         host,
+		SmtpTls:          SmtpTlsNone,
+		SmtpInsecure:     true,
+		SmtpUsername:     "user",
+		FromEmailAddress: "Scrapers <scrapers@example.com>",
+		NotifyEmailAddrs: []string{"ops@example.com", "bad@example.com"},
+	}
+	notifierConfig.SmtpPort, _ = net.LookupPort("tcp", port)
+
+	notifier, err := NewSmtpNotifier(&Config{}, notifierConfig, time.Duration(DefaultNotifierTimeout)*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	notification := NewNotification(NotificationKindChange, "kroger_1", "Änderung", "Detected change: kroger_1, new status: Yes")
+	notification.OldStatus = StatusNo
+	notification.Status = StatusYes
+	notification.Tags = []string{string(TagModerna)}
+	notification.ApiKey = "kroger_key"
+	notification.DumpUrl = "https://s3.example.com/kroger_1.out"
+	notification.ConfigUrl = "https://example.com/config#kroger_1"
+
+	err = notifier.Notify(notification)
+	recipientErr, ok := err.(*SmtpRecipientError)
+	if !ok || recipientErr.Delivered != 1 || recipientErr.Failed["bad@example.com"] == nil || recipientErr.Temporary() {
+		t.Fatalf("Expected bad@example.com to fail alone, got %v", err)
+	}
+
+	msg, err := mail.ReadMessage(strings.NewReader(<-messages))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, header := range []string{"From", "To", "Date", "Message-ID", "Subject"} {
+		if len(msg.Header.Get(header)) == 0 {
+			t.Errorf("Missing %s header", header)
+		}
+	}
+	if subject, _ := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject")); subject != "Änderung" {
+		t.Errorf("Unexpected subject: %s", subject)
+	}
+	if _, err := msg.Header.Date(); err != nil {
+		t.Errorf("Unexpected date: %v", err)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Unexpected content type: %s (%v)", mediaType, err)
+	}
+	reader := multipart.NewReader(msg.Body, params["boundary"])
+	parts := make(map[string]string)
+	for {
+		part, err := reader.NextPart()
+		if err != nil {
+			break
+		}
+		content, _ := ioutil.ReadAll(quotedprintable.NewReader(part))
+		parts[strings.Split(part.Header.Get("Content-Type"), ";")[0]] = string(content)
+	}
+
+	if text := parts["text/plain"]; !strings.Contains(text, "Status: No -> Yes\r\n") || !strings.Contains(text, "Api key: kroger_key") {
+		t.Errorf("Unexpected text part: %s", text)
+	}
+	if html := parts["text/html"]; !strings.Contains(html, `<a href="https://s3.example.com/kroger_1.out">`) || !strings.Contains(html, "<td>moderna</td>") {
+		t.Errorf("Unexpected html part: %s", html)
+	}
+}
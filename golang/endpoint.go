@@ -19,18 +19,28 @@ const EndpointHeaders = "headers"
 const EndpointCookieWhitelist = "cookie_whitelist"
 const EndpointAllowedStatusCodes = "allowed_status_codes"
 const EndpointTimeout = "timeout"
+const EndpointConditionalGet = "conditional_get"
 const EndpointDefaultTimeout = 10
 
 type Endpoint struct {
 	Url                string
 	Method             string
 	Body               string
+	JsonBody           interface{} //serialized to json, string values may contain templates
+	Form               []FormField
+	FormEncoding       string //urlencoded or multipart
 	Headers            []Header
 	CookieWhitelist    []string
 	Cookies            map[string]string
 	AllowedStatusCodes []int
 	HttpClient         *http.Client
 	Timeout            int
+	ConditionalGet     bool              //send If-None-Match/If-Modified-Since based on the previous response
+	NotModified        bool              //set if the last fetch returned 304 and the previous body was reused
+	Captures           map[string]string //named submatches from previous stages, for templates
+	PrevMatch          []string          //submatches from the previous stage, for templates
+	Transforms         []string          //body transforms applied before matching and hashing
+	validators         map[string]*conditionalValidator
 }
 
 type Header struct {
@@ -50,13 +60,14 @@ func NewEndpoint(rawEndpointParams interface{}) (*Endpoint, error) {
 	if _, exists := params[EndpointMethod]; !exists {
 		return nil, fmt.Errorf("Missing endpoint field: %s", EndpointMethod)
 	}
-	endpoint.Method = params[EndpointMethod].(string)
+	endpoint.Method = strings.ToUpper(params[EndpointMethod].(string))
 
-	if endpoint.Method == "POST" {
-		if _, exists := params[EndpointBody]; !exists {
-			return nil, fmt.Errorf("Missing endpoint field: %s", EndpointBody)
-		}
-		endpoint.Body = params[EndpointBody].(string)
+	if !isValidMethod(endpoint.Method) {
+		return nil, fmt.Errorf("Unknown method: %s, expecting one of %v", endpoint.Method, EndpointMethods)
+	}
+
+	if err := parseEndpointBody(endpoint, params); err != nil {
+		return nil, err
 	}
 
 	endpoint.Headers = make([]Header, 0)
@@ -92,6 +103,13 @@ func NewEndpoint(rawEndpointParams interface{}) (*Endpoint, error) {
 	}
 
 	endpoint.Timeout, _ = getIntOptionalWithDefault(params, EndpointTimeout, EndpointDefaultTimeout)
+	endpoint.ConditionalGet = getBool(params, EndpointConditionalGet)
+
+	transforms, err := getTransformsOptional(params)
+	if err != nil {
+		return nil, err
+	}
+	endpoint.Transforms = transforms
 
 	return endpoint, nil
 }
@@ -103,12 +121,17 @@ func (endpoint *Endpoint) GenerateCacheKey(name string) string {
 }
 
 func (endpoint *Endpoint) GenerateCacheKeyWithTTL(name string, ttl int64) string {
+	url := endpoint.Url
+	if templateKey := endpoint.templateCacheKey(name); len(templateKey) > 0 {
+		url = fmt.Sprintf("%s|%s", url, templateKey)
+	}
+
 	if endpoint.Method == "GET" {
-		return fmt.Sprintf("%s|%d", endpoint.Url, ttl)
+		return fmt.Sprintf("%s|%d", url, ttl)
 	} else if endpoint.Method == "POST" {
-		hash := sha256.Sum256([]byte(endpoint.Body))
+		hash := sha256.Sum256([]byte(endpoint.bodyCacheSource()))
 		hashString := hex.EncodeToString(hash[:])
-		return fmt.Sprintf("%s|%s|%d", endpoint.Url, hashString, ttl)
+		return fmt.Sprintf("%s|%s|%d", url, hashString, ttl)
 	} else {
 		return ""
 	}
@@ -125,29 +148,72 @@ func (endpoint *Endpoint) FetchCachedWithTTL(name string, ttl int64) (body []byt
 		return body, true, err
 	}
 
-	body, ok := Cache.GetOrLock(key).([]byte)
-
-	if !ok || body == nil {
-		defer Cache.Unlock(key)
+	var errBody []byte
+	value, cached, err := Cache.GetOrCompute(key, ttl, -1, func() (interface{}, error) {
 		body, _, err := endpoint.Fetch(name)
 		if err != nil {
-			return body, true, err
+			errBody = body
+			return nil, err
 		}
-		Cache.Put(key, body, ttl, -1)
+		return body, nil
+	})
+	if err != nil {
+		return errBody, true, err
+	}
 
-		return body, true, nil
+	body, _ = value.([]byte)
+
+	if cached {
+		endpoint.NotModified = false
+		recordTraceFetch(name, TraceFetch{Url: RedactUrl(endpoint.RenderedUrl(name)), Method: endpoint.Method, CacheHit: true, Bytes: len(body)})
 	}
 
-	return body, false, nil
+	return body, !cached, nil
+}
+
+// the url a fetch requests, for logging.  the configured url if its template can't be rendered
+func (endpoint *Endpoint) RenderedUrl(name string) string {
+	url, err := RenderTemplate(endpoint.Url, endpoint.templateData(name))
+	if err != nil {
+		return endpoint.Url
+	}
+	return url
 }
 
 func (endpoint *Endpoint) Fetch(name string) ([]byte, map[string][]string, error) {
+	trace := TraceFetch{Url: RedactUrl(endpoint.Url), Method: endpoint.Method}
+	start := time.Now()
+
+	body, headers, err := endpoint.fetch(name, &trace)
+
+	trace.DurationMs = time.Since(start).Milliseconds()
+	trace.Bytes = len(body)
+	if err != nil {
+		trace.Error = redactTraceError(err, &trace)
+	}
+	recordTraceFetch(name, trace)
+
+	return body, headers, err
+}
+
+func (endpoint *Endpoint) fetch(name string, trace *TraceFetch) ([]byte, map[string][]string, error) {
 	var resp *http.Response
 	var err error
+	var requestStart time.Time
+	var host string
+
+	endpoint.NotModified = false
 
-	url := replaceMagic(endpoint.Url)
+	templateData := endpoint.templateData(name)
+	url, err := RenderTemplate(endpoint.Url, templateData)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: url: %v", name, err)
+	}
+	log := LogFor(name).With(LogFieldUrl, url)
+	trace.Url = RedactUrl(url)
+	trace.rawUrl = url
 
-	if endpoint.Method == "POST" || endpoint.Method == "GET" {
+	if isValidMethod(endpoint.Method) {
 		client := endpoint.HttpClient
 		if client == nil {
 			client = &http.Client{
@@ -158,15 +224,30 @@ func (endpoint *Endpoint) Fetch(name string) ([]byte, map[string][]string, error
 			}
 		}
 
-		req, err := http.NewRequest(endpoint.Method, url, strings.NewReader(replaceMagic(endpoint.Body)))
+		body, contentType, err := endpoint.renderBody(templateData)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: body: %v", name, err)
+		}
+
+		req, err := http.NewRequest(endpoint.Method, url, strings.NewReader(body))
 		if err != nil {
 			return nil, nil, err
 		}
 
 		for _, header := range endpoint.Headers {
-			req.Header.Add(header.Name, header.Value)
+			headerValue, err := RenderTemplate(header.Value, templateData)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%s: header %s: %v", name, header.Name, err)
+			}
+			req.Header.Add(header.Name, headerValue)
+		}
+
+		if len(contentType) > 0 && len(req.Header.Get("Content-Type")) == 0 {
+			req.Header.Set("Content-Type", contentType)
 		}
 
+		endpoint.applyConditionalHeaders(url, req)
+
 		cookie := req.Header.Get("Cookie")
 		if len(endpoint.Cookies) > 0 {
 			for cookieName, cookieVal := range endpoint.Cookies {
@@ -179,11 +260,15 @@ func (endpoint *Endpoint) Fetch(name string) ([]byte, map[string][]string, error
 		}
 		//Log.Debugf("COOKIE: %s", cookie)
 
+		requestStart = time.Now()
+		host = req.URL.Host
+		trace.Proxy = traceProxy(client, req)
 		resp, err = client.Do(req)
 
 		if err != nil {
-			Log.Debugf("WARNING: Error during fetch: %v", err)
-			return nil, nil, err
+			log.Debugf("WARNING: Error during fetch: %v", err)
+			recordHttpRequest(host, 0, requestStart, 0)
+			return nil, nil, newFetchError(url, err)
 		}
 
 	} else {
@@ -194,6 +279,14 @@ func (endpoint *Endpoint) Fetch(name string) ([]byte, map[string][]string, error
 	if resp != nil && resp.Body != nil {
 		defer resp.Body.Close()
 	}
+	trace.StatusCode = resp.StatusCode
+
+	if prevBody, prevHeaders, notModified := endpoint.handleConditionalResponse(name, url, resp); notModified {
+		recordHttpRequest(host, resp.StatusCode, requestStart, 0)
+		endpoint.NotModified = true
+		trace.NotModified = true
+		return prevBody, prevHeaders, nil
+	}
 
 	respHeaders := make(map[string][]string)
 	gzipContent := false
@@ -237,25 +330,29 @@ func (endpoint *Endpoint) Fetch(name string) ([]byte, map[string][]string, error
 	}
 
 	body, err := ioutil.ReadAll(resp.Body)
+	recordHttpRequest(host, resp.StatusCode, requestStart, len(body))
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, newFetchError(url, err)
 	}
 
 	if gzipContent {
-		Log.Debug("Decompressing gzipped content...")
+		log.Debugf("Decompressing gzipped content...")
 
 		gzReader, err := gzip.NewReader(bytes.NewReader(body))
 		if err != nil {
-			return nil, nil, err
+			return nil, nil, &DecodeError{Url: url, Err: err}
 		}
 
 		body, err = ioutil.ReadAll(gzReader)
 		if err != nil {
-			return nil, nil, err
+			return nil, nil, &DecodeError{Url: url, Err: err}
 		}
 	}
 
-	Log.Debugf("%s: fetched %d bytes with status code %d from %s", name, len(body), resp.StatusCode, url)
+	log.Debugf("fetched %d bytes with status code %d from %s", len(body), resp.StatusCode, url)
+
+	//transformed before anything looks at the body, so cached bodies, validators and hashes all see the same thing
+	body = ApplyTransforms(endpoint.Transforms, body, respHeaders)
 
 	if resp.StatusCode != 200 {
 		allowed := false
@@ -268,11 +365,13 @@ func (endpoint *Endpoint) Fetch(name string) ([]byte, map[string][]string, error
 		}
 
 		if !allowed {
-			Log.Warnf("%s: Status code: %d, %s", name, resp.StatusCode, string(body[:128]))
-			err = fmt.Errorf("Status code: %d", resp.StatusCode)
-			return body, respHeaders, err
+			statusErr := NewHttpStatusError(resp.StatusCode, url, body)
+			log.Warnf("Status code: %d, %s", statusErr.StatusCode, statusErr.Body)
+			return body, respHeaders, statusErr
 		}
 	}
 
+	endpoint.storeConditionalValidator(url, resp, body, respHeaders)
+
 	return body, respHeaders, nil
 }
@@ -0,0 +1,268 @@
+package csg
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+//structured request bodies: json_body (yaml map/list serialized to json) and
+//form (urlencoded or multipart fields)
+
+const EndpointJsonBody = "json_body"
+const EndpointForm = "form"
+const EndpointFormEncoding = "form_encoding"
+
+const FormEncodingUrlencoded = "urlencoded"
+const FormEncodingMultipart = "multipart"
+
+const ContentTypeJson = "application/json"
+const ContentTypeUrlencoded = "application/x-www-form-urlencoded"
+
+var EndpointMethods = []string{"GET", "POST", "PUT", "PATCH", "HEAD", "OPTIONS"}
+
+type FormField struct {
+	Name  string
+	Value string
+}
+
+func isValidMethod(method string) bool {
+	for _, validMethod := range EndpointMethods {
+		if method == validMethod {
+			return true
+		}
+	}
+
+	return false
+}
+
+func parseEndpointBody(endpoint *Endpoint, params map[string]interface{}) error {
+	bodyCount := 0
+
+	if _, exists := params[EndpointBody]; exists {
+		body, err := getStringRequired(params, EndpointBody)
+		if err != nil {
+			return err
+		}
+		endpoint.Body = body
+		bodyCount++
+	}
+
+	if rawJsonBody, exists := params[EndpointJsonBody]; exists {
+		jsonBody, err := normalizeYamlValue(rawJsonBody)
+		if err != nil {
+			return fmt.Errorf("%s: %v", EndpointJsonBody, err)
+		}
+		endpoint.JsonBody = jsonBody
+		bodyCount++
+	}
+
+	if _, exists := params[EndpointForm]; exists {
+		form, err := getMapRequired(params, EndpointForm)
+		if err != nil {
+			return err
+		}
+
+		endpoint.Form, err = parseFormFields(form)
+		if err != nil {
+			return fmt.Errorf("%s: %v", EndpointForm, err)
+		}
+
+		endpoint.FormEncoding = FormEncodingUrlencoded
+		if encoding, exists := getStringOptional(params, EndpointFormEncoding); exists {
+			if encoding != FormEncodingUrlencoded && encoding != FormEncodingMultipart {
+				return fmt.Errorf("Unknown %s: %s", EndpointFormEncoding, encoding)
+			}
+			endpoint.FormEncoding = encoding
+		}
+		bodyCount++
+	}
+
+	if bodyCount > 1 {
+		return fmt.Errorf("Only one of %s, %s or %s can be configured", EndpointBody, EndpointJsonBody, EndpointForm)
+	}
+
+	if bodyCount == 0 && endpoint.Method == "POST" {
+		return fmt.Errorf("Missing endpoint field: %s, %s or %s", EndpointBody, EndpointJsonBody, EndpointForm)
+	}
+
+	return nil
+}
+
+// converts yaml maps (map[interface{}]interface{}) to map[string]interface{} so they can be serialized to json
+func normalizeYamlValue(value interface{}) (interface{}, error) {
+	switch typed := value.(type) {
+	case map[interface{}]interface{}:
+		normalized := make(map[string]interface{})
+		for k, v := range typed {
+			key, ok := k.(string)
+			if !ok {
+				return nil, fmt.Errorf("Expecting string map key, got '%T' instead", k)
+			}
+			normalizedValue, err := normalizeYamlValue(v)
+			if err != nil {
+				return nil, err
+			}
+			normalized[key] = normalizedValue
+		}
+		return normalized, nil
+	case map[string]interface{}:
+		normalized := make(map[string]interface{})
+		for k, v := range typed {
+			normalizedValue, err := normalizeYamlValue(v)
+			if err != nil {
+				return nil, err
+			}
+			normalized[k] = normalizedValue
+		}
+		return normalized, nil
+	case []interface{}:
+		normalized := make([]interface{}, len(typed))
+		for i, v := range typed {
+			normalizedValue, err := normalizeYamlValue(v)
+			if err != nil {
+				return nil, err
+			}
+			normalized[i] = normalizedValue
+		}
+		return normalized, nil
+	default:
+		return value, nil
+	}
+}
+
+// form values can be scalars or lists of scalars (repeated fields), fields are sorted by name
+func parseFormFields(form map[string]interface{}) ([]FormField, error) {
+	names := make([]string, 0, len(form))
+	for name := range form {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fields := make([]FormField, 0, len(form))
+	for _, name := range names {
+		values, isList := form[name].([]interface{})
+		if !isList {
+			values = []interface{}{form[name]}
+		}
+
+		for _, value := range values {
+			switch value.(type) {
+			case map[interface{}]interface{}, map[string]interface{}, []interface{}:
+				return nil, fmt.Errorf("Expecting scalar value for field %s, got '%T' instead", name, value)
+			case nil:
+				value = ""
+			}
+
+			fields = append(fields, FormField{Name: name, Value: fmt.Sprint(value)})
+		}
+	}
+
+	return fields, nil
+}
+
+// renders templates inside every string value, so substituted values are escaped by json.Marshal
+func renderJsonValue(value interface{}, data *TemplateData) (interface{}, error) {
+	switch typed := value.(type) {
+	case string:
+		return RenderTemplate(typed, data)
+	case map[string]interface{}:
+		rendered := make(map[string]interface{})
+		for k, v := range typed {
+			renderedValue, err := renderJsonValue(v, data)
+			if err != nil {
+				return nil, err
+			}
+			rendered[k] = renderedValue
+		}
+		return rendered, nil
+	case []interface{}:
+		rendered := make([]interface{}, len(typed))
+		for i, v := range typed {
+			renderedValue, err := renderJsonValue(v, data)
+			if err != nil {
+				return nil, err
+			}
+			rendered[i] = renderedValue
+		}
+		return rendered, nil
+	default:
+		return value, nil
+	}
+}
+
+// builds the request body and its content type, content type is empty for raw bodies
+func (endpoint *Endpoint) renderBody(data *TemplateData) (body string, contentType string, err error) {
+	if endpoint.JsonBody != nil {
+		rendered, err := renderJsonValue(endpoint.JsonBody, data)
+		if err != nil {
+			return "", "", err
+		}
+
+		jsonBytes, err := json.Marshal(rendered)
+		if err != nil {
+			return "", "", err
+		}
+
+		return string(jsonBytes), ContentTypeJson, nil
+	}
+
+	if endpoint.Form != nil {
+		fields := make([]FormField, len(endpoint.Form))
+		for i, field := range endpoint.Form {
+			value, err := RenderTemplate(field.Value, data)
+			if err != nil {
+				return "", "", fmt.Errorf("field %s: %v", field.Name, err)
+			}
+			fields[i] = FormField{Name: field.Name, Value: value}
+		}
+
+		if endpoint.FormEncoding == FormEncodingMultipart {
+			var buf bytes.Buffer
+			writer := multipart.NewWriter(&buf)
+			for _, field := range fields {
+				if err := writer.WriteField(field.Name, field.Value); err != nil {
+					return "", "", err
+				}
+			}
+			if err := writer.Close(); err != nil {
+				return "", "", err
+			}
+
+			return buf.String(), writer.FormDataContentType(), nil
+		}
+
+		values := url.Values{}
+		for _, field := range fields {
+			values.Add(field.Name, field.Value)
+		}
+
+		return values.Encode(), ContentTypeUrlencoded, nil
+	}
+
+	body, err = RenderTemplate(endpoint.Body, data)
+	return body, "", err
+}
+
+// unrendered body used to build cache keys
+func (endpoint *Endpoint) bodyCacheSource() string {
+	if endpoint.JsonBody != nil {
+		jsonBytes, _ := json.Marshal(endpoint.JsonBody)
+		return string(jsonBytes)
+	}
+
+	if endpoint.Form != nil {
+		parts := make([]string, 0, len(endpoint.Form)+1)
+		parts = append(parts, endpoint.FormEncoding)
+		for _, field := range endpoint.Form {
+			parts = append(parts, fmt.Sprintf("%s=%s", field.Name, field.Value))
+		}
+		return strings.Join(parts, "\x00")
+	}
+
+	return endpoint.Body
+}
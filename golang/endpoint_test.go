@@ -0,0 +1,205 @@
+package csg
+
+import (
+	"encoding/json"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestConditionalGet(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = w.Write([]byte("no appointments"))
+	}))
+	defer server.Close()
+
+	scraper := new(ScraperStandardRegexp)
+	scraper.ScraperName = "TestConditionalGet"
+	scraper.AvailableStatus = StatusYes
+	scraper.UnavailablePattern = regexp.MustCompile("no appointments")
+	scraper.ScrapeEndpoint = &Endpoint{Url: server.URL, Method: "GET", ConditionalGet: true}
+
+	for i := 0; i < 2; i++ {
+		status, _, body, err := scraper.Scrape()
+		if err != nil {
+			t.Errorf("Unexpected error: %v", err)
+			return
+		}
+		if status != StatusNo || string(body) != "no appointments" {
+			t.Errorf("Expected status %s with previous body, got %s: %s", StatusNo, status, string(body))
+			return
+		}
+		if scraper.ScrapeEndpoint.NotModified != (i == 1) {
+			t.Errorf("Expected NotModified to be %t on fetch %d", i == 1, i+1)
+			return
+		}
+		Cache.Destroy()
+	}
+
+	if requests != 2 {
+		t.Errorf("Expected 2 requests, got %d", requests)
+		return
+	}
+
+	stats := GetConditionalGetStats()[scraper.Name()]
+	if stats.Requests != 2 || stats.NotModified != 1 {
+		t.Errorf("Unexpected conditional GET stats: %+v", stats)
+		return
+	}
+}
+
+const TestStructuredBodyYAML = `json_endpoint:
+  url: "%s"
+  method: "put"
+  json_body:
+    operationName: "availability"
+    variables:
+      ids: ["{{ .Vars.location }}"]
+      numDays: 14
+form_endpoint:
+  url: "%s"
+  method: "POST"
+  form:
+    secret: "a&b"
+    id: [1, 2]
+multipart_endpoint:
+  url: "%s"
+  method: "PATCH"
+  form_encoding: "multipart"
+  form:
+    name: "{{ .Vars.location }}"`
+
+func TestStructuredBodies(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		_, _ = w.Write([]byte(r.Method + " " + r.Header.Get("Content-Type") + " " + string(body)))
+	}))
+	defer server.Close()
+
+	params := make(map[string]interface{})
+	yamlStr := strings.ReplaceAll(TestStructuredBodyYAML, "%s", server.URL)
+	if err := yaml.Unmarshal([]byte(yamlStr), params); err != nil {
+		panic(err)
+	}
+
+	name := "TestStructuredBodies"
+	SetTemplateVars(name, map[string]string{"location": `pr_"1"`})
+	defer SetTemplateVars(name, nil)
+
+	expected := map[string]string{
+		"json_endpoint": `PUT application/json {"operationName":"availability","variables":{"ids":["pr_\"1\""],"numDays":14}}`,
+		"form_endpoint": `POST application/x-www-form-urlencoded id=1&id=2&secret=a%26b`,
+	}
+
+	for key, expectedBody := range expected {
+		endpoint, err := getEndpointRequired(params, key)
+		if err != nil {
+			t.Errorf("Unexpected error: %v", err)
+			return
+		}
+
+		body, _, err := endpoint.Fetch(name)
+		if err != nil {
+			t.Errorf("Unexpected error: %v", err)
+			return
+		}
+
+		if string(body) != expectedBody {
+			t.Errorf("Expecting %s, got %s", expectedBody, string(body))
+			return
+		}
+	}
+
+	endpoint, err := getEndpointRequired(params, "multipart_endpoint")
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+
+	body, _, err := endpoint.Fetch(name)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+
+	if !strings.HasPrefix(string(body), "PATCH multipart/form-data; boundary=") || !strings.Contains(string(body), `pr_"1"`) {
+		t.Errorf("Unexpected multipart request: %s", string(body))
+		return
+	}
+
+	params["bad_endpoint"] = map[interface{}]interface{}{"url": server.URL, "method": "POST"}
+	if _, err = getEndpointRequired(params, "bad_endpoint"); err == nil {
+		t.Errorf("Expected error for POST without body, got nil")
+		return
+	}
+}
+
+const TestPrevMagicBodyYAML = `stages:
+  - endpoint:
+      url: "%s/list"
+      method: "GET"
+    next_url_regexp: 'id="([a-z0-9]+)"'
+    recursion_type: "any"
+  - endpoint:
+      url: "%s/slots"
+      method: "POST"
+      json_body:
+        id: "##PREVIOUS##"
+    available_regexp: "available"
+    unavailable_regexp: "none"`
+
+func TestMultistagePrevMagicInJsonBody(t *testing.T) {
+	requested := make([]string, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/list" {
+			_, _ = w.Write([]byte(`<a id="a1"></a><a id="b2"></a>`))
+			return
+		}
+
+		var request struct{ Id string }
+		body, _ := ioutil.ReadAll(r.Body)
+		_ = json.Unmarshal(body, &request)
+		requested = append(requested, request.Id)
+		if request.Id == "b2" {
+			_, _ = w.Write([]byte("available"))
+		} else {
+			_, _ = w.Write([]byte("none"))
+		}
+	}))
+	defer server.Close()
+
+	prevConfig := config
+	config = &Config{}
+	defer func() { config = prevConfig }()
+
+	params := make(map[string]interface{})
+	if err := yaml.Unmarshal([]byte(strings.ReplaceAll(TestPrevMagicBodyYAML, "%s", server.URL)), params); err != nil {
+		panic(err)
+	}
+
+	scraper := &ScraperMultistageRegexp{ScraperName: "TestMultistagePrevMagicInJsonBody", AvailableStatus: StatusYes}
+	if err := scraper.Configure(params); err != nil {
+		t.Fatal(err)
+	}
+
+	//each match is posted, not served the cached response for the first one
+	status, _, body, err := scraper.Scrape()
+	Cache.Destroy()
+	if err != nil || status != StatusYes {
+		t.Errorf("Expected status %s, got %s (%v): %s", StatusYes, status, err, body)
+	}
+	if len(requested) != 2 || requested[0] != "a1" || requested[1] != "b2" {
+		t.Errorf("Expected ##PREVIOUS## to be replaced in the json body, got %v", requested)
+	}
+}
@@ -0,0 +1,200 @@
+package csg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+)
+
+//typed errors returned from fetches and scrapes, use errors.As to inspect them
+
+const ErrorClassHttpStatus = "http_status"
+const ErrorClassTimeout = "timeout"
+const ErrorClassConnection = "connection"
+const ErrorClassDecode = "decode"
+const ErrorClassPattern = "pattern"
+const ErrorClassOther = "other"
+
+const ErrorBodySnippetLength = 128
+
+// non-2xx (and not allowed) status code returned from an endpoint
+type HttpStatusError struct {
+	StatusCode int
+	Url        string
+	Body       string //first ErrorBodySnippetLength bytes of the response body
+}
+
+func NewHttpStatusError(statusCode int, url string, body []byte) *HttpStatusError {
+	return &HttpStatusError{
+		StatusCode: statusCode,
+		Url:        url,
+		Body:       bodySnippet(body, ErrorBodySnippetLength),
+	}
+}
+
+func (e *HttpStatusError) Error() string {
+	return fmt.Sprintf("Status code: %d", e.StatusCode)
+}
+
+// request timed out, either while connecting or while reading the response
+type TimeoutError struct {
+	Url string
+	Err error
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("Timeout fetching %s: %v", e.Url, e.Err)
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// dns lookup or connection failure, including tls handshake errors
+type ConnectionError struct {
+	Url string
+	Err error
+}
+
+func (e *ConnectionError) Error() string {
+	return fmt.Sprintf("Connection error fetching %s: %v", e.Url, e.Err)
+}
+
+func (e *ConnectionError) Unwrap() error {
+	return e.Err
+}
+
+// response body could not be decompressed or parsed
+type DecodeError struct {
+	Url string
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	if len(e.Url) == 0 {
+		return fmt.Sprintf("Decode error: %v", e.Err)
+	}
+	return fmt.Sprintf("Decode error for %s: %v", e.Url, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// configured error_regexp matched the scraped content
+type PatternError struct {
+	Pattern *regexp.Regexp
+}
+
+func NewPatternError(pattern *regexp.Regexp) *PatternError {
+	return &PatternError{Pattern: pattern}
+}
+
+func (e *PatternError) Error() string {
+	return "Error pattern matched"
+}
+
+// wraps a raw error from http.Client.Do in a TimeoutError or ConnectionError
+func newFetchError(url string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if isTimeout(err) {
+		return &TimeoutError{Url: url, Err: err}
+	}
+
+	return &ConnectionError{Url: url, Err: err}
+}
+
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// wraps json errors returned by scrapers in a DecodeError, other errors are returned as is
+func normalizeScrapeError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var decodeErr *DecodeError
+	if errors.As(err, &decodeErr) {
+		return err
+	}
+
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) {
+		return &DecodeError{Err: err}
+	}
+
+	return err
+}
+
+// returns one of the ErrorClass* constants
+func ErrorClass(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	var statusErr *HttpStatusError
+	var timeoutErr *TimeoutError
+	var connErr *ConnectionError
+	var decodeErr *DecodeError
+	var patternErr *PatternError
+
+	switch {
+	case errors.As(err, &statusErr):
+		return ErrorClassHttpStatus
+	case errors.As(err, &timeoutErr):
+		return ErrorClassTimeout
+	case errors.As(err, &connErr):
+		return ErrorClassConnection
+	case errors.As(err, &decodeErr):
+		return ErrorClassDecode
+	case errors.As(err, &patternErr):
+		return ErrorClassPattern
+	}
+
+	if normalizeScrapeError(err) != err {
+		return ErrorClassDecode
+	}
+
+	return ErrorClassOther
+}
+
+// false if retrying right away is unlikely to help, i.e. the site is up but
+// returned something we don't understand
+func IsRetryableError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var statusErr *HttpStatusError
+	if errors.As(err, &statusErr) {
+		code := statusErr.StatusCode
+		if code >= 400 && code < 500 {
+			//anti-bot blocks and rate limits usually go away
+			return code == 403 || code == 408 || code == 429
+		}
+		return true
+	}
+
+	var patternErr *PatternError
+	return !errors.As(err, &patternErr)
+}
+
+func bodySnippet(body []byte, maxLen int) string {
+	if len(body) > maxLen {
+		return string(body[:maxLen])
+	}
+	return string(body)
+}
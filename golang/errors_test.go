@@ -0,0 +1,97 @@
+package csg
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestFetchHttpStatusError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(503)
+		_, _ = w.Write([]byte("down"))
+	}))
+	defer server.Close()
+
+	endpoint := new(Endpoint)
+	endpoint.Url = server.URL
+	endpoint.Method = "GET"
+
+	body, _, err := endpoint.Fetch("TestFetchHttpStatusError")
+	if string(body) != "down" {
+		t.Errorf("Expected body 'down', got '%s'", string(body))
+		return
+	}
+
+	var statusErr *HttpStatusError
+	if !errors.As(err, &statusErr) {
+		t.Errorf("Expected HttpStatusError, got %T: %v", err, err)
+		return
+	}
+
+	if statusErr.StatusCode != 503 || statusErr.Url != server.URL || statusErr.Body != "down" {
+		t.Errorf("Unexpected error fields: %+v", statusErr)
+		return
+	}
+
+	if !IsRetryableError(err) {
+		t.Errorf("Expected 503 to be retryable")
+		return
+	}
+}
+
+func TestFetchTimeoutError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	endpoint := new(Endpoint)
+	endpoint.Url = server.URL
+	endpoint.Method = "GET"
+	endpoint.HttpClient = &http.Client{Timeout: 50 * time.Millisecond}
+
+	_, _, err := endpoint.Fetch("TestFetchTimeoutError")
+	if ErrorClass(err) != ErrorClassTimeout {
+		t.Errorf("Expected %s error, got %s: %v", ErrorClassTimeout, ErrorClass(err), err)
+		return
+	}
+}
+
+func TestErrorClass(t *testing.T) {
+	var jsonDest map[string]interface{}
+	jsonErr := json.Unmarshal([]byte("<html>"), &jsonDest)
+
+	cases := []struct {
+		err       error
+		class     string
+		retryable bool
+	}{
+		{NewHttpStatusError(404, "http://foo", nil), ErrorClassHttpStatus, false},
+		{NewHttpStatusError(429, "http://foo", nil), ErrorClassHttpStatus, true},
+		{&ConnectionError{Url: "http://foo", Err: fmt.Errorf("no such host")}, ErrorClassConnection, true},
+		{NewPatternError(regexp.MustCompile("foo")), ErrorClassPattern, false},
+		{fmt.Errorf("wrapped: %w", NewPatternError(nil)), ErrorClassPattern, false},
+		{jsonErr, ErrorClassDecode, true},
+		{fmt.Errorf("something else"), ErrorClassOther, true},
+	}
+
+	for _, c := range cases {
+		if class := ErrorClass(c.err); class != c.class {
+			t.Errorf("Expected class %s for %v, got %s", c.class, c.err, class)
+		}
+		if retryable := IsRetryableError(c.err); retryable != c.retryable {
+			t.Errorf("Expected retryable=%t for %v, got %t", c.retryable, c.err, retryable)
+		}
+	}
+
+	var decodeErr *DecodeError
+	if !errors.As(normalizeScrapeError(jsonErr), &decodeErr) {
+		t.Errorf("Expected json error to be normalized to DecodeError")
+	}
+}
@@ -0,0 +1,323 @@
+package csg
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//public availability feed: the current status of every location with an api key, as json, geojson (like the
+//vaccinespotter feed) and csv.  written to feed_dir and uploaded to feed_s3_bucket after a once pass, and
+//served on feed_addr in continuous mode.  a once pass builds on the feed it published last time, so
+//last_changed survives across passes and locations that weren't scraped or sent keep their status
+
+const FeedFileName = "feed" //feed.json, feed.geojson and feed.csv
+const FeedFormatJson = "json"
+const FeedFormatGeoJson = "geojson"
+const FeedFormatCsv = "csv"
+
+var FeedFormats = []string{FeedFormatJson, FeedFormatGeoJson, FeedFormatCsv}
+var FeedCsvHeader = []string{"api_key", "scraper", "type", "status", "tags", "last_checked", "last_changed", "appointments", "earliest_slot", "booking_url", "latitude", "longitude"}
+
+var feedContentTypes = map[string]string{
+	FeedFormatJson:    "application/json",
+	FeedFormatGeoJson: "application/geo+json",
+	FeedFormatCsv:     "text/csv; charset=utf-8",
+}
+
+type FeedLocation struct {
+	ApiKey       string     `json:"api_key"`
+	Scraper      string     `json:"scraper"`
+	Type         string     `json:"type"`
+	Status       Status     `json:"status"` //last status sent to the api, never APISkip
+	Tags         []string   `json:"tags"`
+	LastChecked  *time.Time `json:"last_checked"`
+	LastChanged  *time.Time `json:"last_changed"`
+	Appointments *int       `json:"appointments,omitempty"`
+	EarliestSlot *time.Time `json:"earliest_slot,omitempty"`
+	BookingUrl   string     `json:"booking_url,omitempty"`
+	Latitude     *float64   `json:"latitude,omitempty"`
+	Longitude    *float64   `json:"longitude,omitempty"`
+}
+
+type FeedSnapshot struct {
+	Generated time.Time      `json:"generated_time"`
+	RunId     string         `json:"run_id"`
+	Locations []FeedLocation `json:"locations"`
+}
+
+type feedGeoJson struct {
+	Type     string            `json:"type"`
+	Metadata map[string]string `json:"metadata"`
+	Features []feedFeature     `json:"features"`
+}
+
+type feedFeature struct {
+	Type       string        `json:"type"`
+	Geometry   *feedGeometry `json:"geometry"` //null when the location is unknown
+	Properties FeedLocation  `json:"properties"`
+}
+
+type feedGeometry struct {
+	Type        string    `json:"type"`
+	Coordinates []float64 `json:"coordinates"` //longitude, latitude
+}
+
+func feedTime(timestamp int64) *time.Time {
+	if timestamp <= 0 {
+		return nil
+	}
+	t := time.Unix(timestamp, 0).UTC()
+	return &t
+}
+
+// the current state of every scraper with an api key, sorted by api key.  previous is the last feed, if any
+func BuildFeed(tracker *ChangeTracker, scrapeContexts []*ScrapeAndSendContext, previous *FeedSnapshot, now time.Time) *FeedSnapshot {
+	feed := &FeedSnapshot{Generated: now.UTC(), RunId: CurrentRunId, Locations: make([]FeedLocation, 0, len(scrapeContexts))}
+
+	previousLocations := make(map[string]FeedLocation)
+	if previous != nil {
+		for _, location := range previous.Locations {
+			previousLocations[location.Scraper] = location
+		}
+	}
+
+	for _, ctx := range scrapeContexts {
+		snapshot := ctx.Snapshot()
+		if snapshot.Config == nil || len(snapshot.Config.ApiKey) == 0 {
+			continue
+		}
+
+		location := FeedLocation{
+			ApiKey:     snapshot.Config.ApiKey,
+			Scraper:    snapshot.Name,
+			Type:       snapshot.Scraper.Type(),
+			Status:     StatusUnknown,
+			Tags:       snapshot.Tags,
+			BookingUrl: snapshot.Config.BookingUrl,
+		}
+		if location.Tags == nil {
+			location.Tags = []string{}
+		}
+		if state, exists := tracker.State(snapshot.Name); exists {
+			location.Status = state.Status
+			location.LastChecked = feedTime(state.LastScrapeTime)
+			location.LastChanged = feedTime(state.LastChangeTime)
+		}
+		if prevLocation, exists := previousLocations[snapshot.Name]; exists {
+			if location.Status == StatusUnknown || location.Status == StatusApiSkip {
+				//nothing was sent this time, the api still has the previous status
+				location.Status = prevLocation.Status
+				location.LastChanged = prevLocation.LastChanged
+			} else if location.Status == prevLocation.Status && prevLocation.LastChanged != nil {
+				//a once pass starts with an empty tracker, the status changed when the previous feed says it did
+				location.LastChanged = prevLocation.LastChanged
+			}
+			if location.LastChecked == nil {
+				location.LastChecked = prevLocation.LastChecked
+			}
+		}
+		if location.Status == StatusApiSkip {
+			location.Status = StatusUnknown
+			location.LastChanged = nil
+		}
+
+		coordinates := snapshot.Config.Location
+		if details := snapshot.Details; details != nil {
+			location.Appointments = details.Appointments
+			location.EarliestSlot = details.EarliestSlot
+			location.BookingUrl = firstNonEmpty(details.BookingUrl, location.BookingUrl)
+			if details.Coordinates != nil {
+				coordinates = details.Coordinates
+			}
+		}
+		if coordinates != nil && !coordinates.Zero() {
+			location.Latitude = &coordinates.Lat
+			location.Longitude = &coordinates.Lng
+		}
+
+		feed.Locations = append(feed.Locations, location)
+	}
+
+	sort.Slice(feed.Locations, func(i, j int) bool {
+		if feed.Locations[i].ApiKey == feed.Locations[j].ApiKey {
+			return feed.Locations[i].Scraper < feed.Locations[j].Scraper
+		}
+		return feed.Locations[i].ApiKey < feed.Locations[j].ApiKey
+	})
+
+	return feed
+}
+
+func (f *FeedSnapshot) Json() ([]byte, error) {
+	return json.Marshal(f)
+}
+
+// a feature collection with a point per location, locations without coordinates have a null geometry
+func (f *FeedSnapshot) GeoJson() ([]byte, error) {
+	collection := feedGeoJson{
+		Type:     "FeatureCollection",
+		Metadata: map[string]string{"generated_time": f.Generated.Format(time.RFC3339), "run_id": f.RunId},
+		Features: make([]feedFeature, 0, len(f.Locations)),
+	}
+
+	for _, location := range f.Locations {
+		feature := feedFeature{Type: "Feature", Properties: location}
+		if location.Latitude != nil && location.Longitude != nil {
+			feature.Geometry = &feedGeometry{Type: "Point", Coordinates: []float64{*location.Longitude, *location.Latitude}}
+		}
+		collection.Features = append(collection.Features, feature)
+	}
+
+	return json.Marshal(collection)
+}
+
+// one row per location, tags separated by ;
+func (f *FeedSnapshot) Csv() ([]byte, error) {
+	formatTime := func(t *time.Time) string {
+		if t == nil {
+			return ""
+		}
+		return t.UTC().Format(time.RFC3339)
+	}
+	formatFloat := func(value *float64) string {
+		if value == nil {
+			return ""
+		}
+		return strconv.FormatFloat(*value, 'f', -1, 64)
+	}
+
+	buf := new(bytes.Buffer)
+	writer := csv.NewWriter(buf)
+	if err := writer.Write(FeedCsvHeader); err != nil {
+		return nil, err
+	}
+	for _, location := range f.Locations {
+		appointments := ""
+		if location.Appointments != nil {
+			appointments = strconv.Itoa(*location.Appointments)
+		}
+
+		row := []string{location.ApiKey, location.Scraper, location.Type, string(location.Status), strings.Join(location.Tags, ";"),
+			formatTime(location.LastChecked), formatTime(location.LastChanged), appointments, formatTime(location.EarliestSlot),
+			location.BookingUrl, formatFloat(location.Latitude), formatFloat(location.Longitude)}
+		if err := writer.Write(row); err != nil {
+			return nil, err
+		}
+	}
+	writer.Flush()
+
+	return buf.Bytes(), writer.Error()
+}
+
+func (f *FeedSnapshot) Encode(format string) ([]byte, error) {
+	switch format {
+	case FeedFormatJson:
+		return f.Json()
+	case FeedFormatGeoJson:
+		return f.GeoJson()
+	case FeedFormatCsv:
+		return f.Csv()
+	default:
+		return nil, fmt.Errorf("Unknown feed format: %s", format)
+	}
+}
+
+// reads the feed published by the last once pass from feed_dir, or else feed_s3_bucket.  nil if there isn't one
+func loadPreviousFeed() *FeedSnapshot {
+	fileName := FeedFileName + "." + FeedFormatJson
+
+	var data []byte
+	var err error
+	if len(config.FeedDir) > 0 {
+		data, err = ioutil.ReadFile(filepath.Join(config.FeedDir, fileName))
+		if os.IsNotExist(err) {
+			return nil
+		}
+	} else if len(config.FeedS3Bucket) > 0 && HasAWSCredentials() {
+		data, err = GetS3Object(config.FeedS3Bucket, fileName)
+	} else {
+		return nil
+	}
+	if err != nil {
+		Log.Warnf("Can't read the previous feed, last_changed starts over: %v", err)
+		return nil
+	}
+
+	previous := new(FeedSnapshot)
+	if err := json.Unmarshal(data, previous); err != nil {
+		Log.Warnf("Can't parse the previous feed, last_changed starts over: %v", err)
+		return nil
+	}
+	return previous
+}
+
+// writes the feed in every format to feed_dir and uploads it to feed_s3_bucket, whichever are configured
+func publishFeed(feed *FeedSnapshot) error {
+	if len(config.FeedDir) > 0 {
+		if err := os.MkdirAll(config.FeedDir, 0755); err != nil {
+			return fmt.Errorf("Can't create feed dir %s: %v", config.FeedDir, err)
+		}
+	}
+
+	for _, format := range FeedFormats {
+		data, err := feed.Encode(format)
+		if err != nil {
+			return err
+		}
+		fileName := FeedFileName + "." + format
+
+		if len(config.FeedDir) > 0 {
+			//renamed into place so readers never see a partial feed
+			filePath := filepath.Join(config.FeedDir, fileName)
+			if err := ioutil.WriteFile(filePath+".tmp", data, 0644); err != nil {
+				return err
+			}
+			if err := os.Rename(filePath+".tmp", filePath); err != nil {
+				return err
+			}
+			Log.Debugf("Wrote %d locations to %s", len(feed.Locations), filePath)
+		}
+
+		if len(config.FeedS3Bucket) > 0 {
+			if !HasAWSCredentials() {
+				return fmt.Errorf("feed_s3_bucket is configured but no AWS credentials were found")
+			}
+			url, err := PutS3Object(config.FeedS3Bucket, fileName, data, map[string]string{"run-id": CurrentRunId})
+			if err != nil {
+				return err
+			}
+			Log.Debugf("Sent %d locations to S3: %s", len(feed.Locations), url)
+		}
+	}
+
+	Log.Infof("Published a feed of %d locations", len(feed.Locations))
+	return nil
+}
+
+// serves /feed.json, /feed.geojson and /feed.csv, built from the current state on every request
+func registerFeedHandlers(addr string, tracker *ChangeTracker, scrapeContexts []*ScrapeAndSendContext) {
+	for _, format := range FeedFormats {
+		format := format
+		handleHttp(addr, "/"+FeedFileName+"."+format, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			//the tracker has every change since the process started, there's no previous feed to build on
+			data, err := BuildFeed(tracker, scrapeContexts, nil, time.Now()).Encode(format)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", feedContentTypes[format])
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			w.Write(data)
+		}))
+	}
+}
@@ -0,0 +1,109 @@
+package csg
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFeed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "feed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	defer useStatusApiTestConfig(&Config{FeedDir: dir})()
+
+	ctx, tracker := newStatusApiTestContext(&ScraperConfig{ApiKey: "feed_key", Location: &GeoCoord{Lat: 47.6, Lng: -122.3}})
+	noKey, _ := newStatusApiTestContext(&ScraperConfig{})
+	doScrapeAndSend(tracker, ctx, true, nil)
+
+	feed := BuildFeed(tracker, []*ScrapeAndSendContext{ctx, noKey}, nil, time.Now())
+	if len(feed.Locations) != 1 {
+		t.Fatalf("Expected only the scraper with an api key, got %d locations", len(feed.Locations))
+	}
+	location := feed.Locations[0]
+	if location.ApiKey != "feed_key" || location.Status != StatusYes || location.LastChecked == nil || location.LastChanged == nil || location.Tags[0] != string(TagModerna) {
+		t.Errorf("Unexpected location: %+v", location)
+	}
+
+	if err := publishFeed(feed); err != nil {
+		t.Fatal(err)
+	}
+
+	var geoJson struct {
+		Type     string
+		Features []struct {
+			Geometry struct {
+				Coordinates []float64
+			}
+			Properties FeedLocation
+		}
+	}
+	data, _ := ioutil.ReadFile(filepath.Join(dir, "feed.geojson"))
+	if err := json.Unmarshal(data, &geoJson); err != nil || geoJson.Type != "FeatureCollection" || len(geoJson.Features) != 1 {
+		t.Fatalf("Unexpected geojson (%v): %s", err, data)
+	}
+	if coordinates := geoJson.Features[0].Geometry.Coordinates; len(coordinates) != 2 || coordinates[0] != -122.3 || coordinates[1] != 47.6 {
+		t.Errorf("Expected longitude, latitude coordinates, got %v", coordinates)
+	}
+
+	var snapshot FeedSnapshot
+	data, _ = ioutil.ReadFile(filepath.Join(dir, "feed.json"))
+	if err := json.Unmarshal(data, &snapshot); err != nil || len(snapshot.Locations) != 1 || *snapshot.Locations[0].Latitude != 47.6 {
+		t.Errorf("Unexpected json (%v): %s", err, data)
+	}
+
+	data, _ = ioutil.ReadFile(filepath.Join(dir, "feed.csv"))
+	rows, err := csv.NewReader(strings.NewReader(string(data))).ReadAll()
+	if err != nil || len(rows) != 2 || strings.Join(rows[0], ",") != strings.Join(FeedCsvHeader, ",") || rows[1][0] != "feed_key" || rows[1][3] != string(StatusYes) {
+		t.Errorf("Unexpected csv (%v): %s", err, data)
+	}
+}
+
+func TestFeedPreviousPass(t *testing.T) {
+	dir, err := ioutil.TempDir("", "feed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	defer useStatusApiTestConfig(&Config{FeedDir: dir})()
+
+	if loadPreviousFeed() != nil {
+		t.Errorf("Expected no previous feed in an empty feed_dir")
+	}
+
+	changed := time.Now().Add(-48 * time.Hour).Truncate(time.Second).UTC()
+	previous := &FeedSnapshot{Locations: []FeedLocation{{ApiKey: "feed_key", Scraper: new(statusApiTestScraper).Name(), Status: StatusYes, LastChanged: &changed}}}
+	if err := publishFeed(previous); err != nil {
+		t.Fatal(err)
+	}
+
+	//a new once pass, the tracker only knows this pass
+	ctx, tracker := newStatusApiTestContext(&ScraperConfig{ApiKey: "feed_key"})
+	doScrapeAndSend(tracker, ctx, true, nil)
+
+	location := BuildFeed(tracker, []*ScrapeAndSendContext{ctx}, loadPreviousFeed(), time.Now()).Locations[0]
+	if location.Status != StatusYes || location.LastChanged == nil || !location.LastChanged.Equal(changed) || !location.LastChecked.After(changed) {
+		t.Errorf("Expected last_changed from the previous feed, got %+v", location)
+	}
+
+	//nothing sent, the previous status stands
+	tracker.Lock(ctx.Name)
+	tracker.UpdateAndUnlock(ctx.Name, StatusApiSkip)
+	location = BuildFeed(tracker, []*ScrapeAndSendContext{ctx}, loadPreviousFeed(), time.Now()).Locations[0]
+	if location.Status != StatusYes || location.LastChanged == nil || !location.LastChanged.Equal(changed) {
+		t.Errorf("Expected the previous status for a skipped scrape, got %+v", location)
+	}
+	location = BuildFeed(tracker, []*ScrapeAndSendContext{ctx}, nil, time.Now()).Locations[0]
+	if location.Status != StatusUnknown || location.LastChanged != nil {
+		t.Errorf("Expected unknown for a skipped scrape without a previous feed, got %+v", location)
+	}
+}
@@ -0,0 +1,352 @@
+package csg
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+//append-only history of status transitions and errors, one jsonl file per scraper in history_dir.
+//a line is written whenever the status or error class of a scraper differs from its last line
+
+const HistoryFileSuffix = ".jsonl"
+const HistoryDefaultSince = 24 * time.Hour
+const HistoryFlapWindow = 30 * time.Minute //a change reverted within this window counts as a flap
+const HistoryDateFormat = "2006-01-02"
+const HistoryTimeFormat = "2006-01-02 15:04:05 MST"
+
+var History = NewHistoryStore("")
+
+type HistoryEvent struct {
+	Time       time.Time `json:"time"`
+	Scraper    string    `json:"scraper"`
+	ScrapeId   string    `json:"scrape_id,omitempty"`
+	Status     Status    `json:"status"`
+	ErrorClass string    `json:"error_class,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+type HistoryStore struct {
+	dir        string
+	lastEvents map[string]*HistoryEvent
+	lock       *sync.Mutex
+}
+
+type HistorySummary struct {
+	Name         string
+	Since        time.Time
+	Until        time.Time
+	Events       []HistoryEvent //events inside the window
+	Initial      *HistoryEvent  //last event before the window, if any
+	TimeInStatus map[Status]time.Duration
+	Changes      int
+	Flaps        int
+}
+
+// an empty dir disables the store
+func NewHistoryStore(dir string) *HistoryStore {
+	store := new(HistoryStore)
+	store.dir = dir
+	store.lastEvents = make(map[string]*HistoryEvent)
+	store.lock = new(sync.Mutex)
+	return store
+}
+
+func (h *HistoryStore) Enabled() bool {
+	return len(h.dir) > 0
+}
+
+func (h *HistoryStore) filePath(name string) string {
+	return filepath.Join(h.dir, name+HistoryFileSuffix)
+}
+
+// records the result of a scrape if it differs from the last recorded one
+func (h *HistoryStore) Record(name string, status Status, err error) error {
+	if !h.Enabled() {
+		return nil
+	}
+
+	event := &HistoryEvent{Time: time.Now(), Scraper: name, ScrapeId: ScrapeId(name), Status: status}
+	if err != nil {
+		event.ErrorClass = ErrorClass(err)
+		event.Error = redactApiSecret(err.Error())
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	lastEvent, exists := h.lastEvents[name]
+	if !exists {
+		events, err := h.read(name)
+		if err != nil {
+			return err
+		}
+		if len(events) > 0 {
+			lastEvent = &events[len(events)-1]
+		}
+	}
+
+	if lastEvent != nil && lastEvent.Status == event.Status && lastEvent.ErrorClass == event.ErrorClass {
+		h.lastEvents[name] = lastEvent
+		return nil
+	}
+
+	jsonBytes, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(h.filePath(name), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if _, err = file.Write(append(jsonBytes, '\n')); err != nil {
+		return err
+	}
+
+	h.lastEvents[name] = event
+	return nil
+}
+
+// all recorded events of a scraper, oldest first
+func (h *HistoryStore) Read(name string) ([]HistoryEvent, error) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	return h.read(name)
+}
+
+func (h *HistoryStore) read(name string) ([]HistoryEvent, error) {
+	events := make([]HistoryEvent, 0)
+
+	file, err := os.Open(h.filePath(name))
+	if os.IsNotExist(err) {
+		return events, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		var event HistoryEvent
+		if err := json.Unmarshal(line, &event); err != nil {
+			//a partially written last line shouldn't hide the rest of the history
+			Log.Warnf("%s: skipping malformed history line: %v", h.filePath(name), err)
+			continue
+		}
+		events = append(events, event)
+	}
+
+	return events, scanner.Err()
+}
+
+// names of all scrapers with a history, sorted
+func (h *HistoryStore) Names() ([]string, error) {
+	files, err := ioutil.ReadDir(h.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(files))
+	for _, file := range files {
+		if !file.IsDir() && strings.HasSuffix(file.Name(), HistoryFileSuffix) {
+			names = append(names, strings.TrimSuffix(file.Name(), HistoryFileSuffix))
+		}
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// timeline, time in status and change/flap counts between since and until
+func SummarizeHistory(name string, events []HistoryEvent, since time.Time, until time.Time) *HistorySummary {
+	summary := &HistorySummary{
+		Name:         name,
+		Since:        since,
+		Until:        until,
+		Events:       make([]HistoryEvent, 0),
+		TimeInStatus: make(map[Status]time.Duration),
+	}
+
+	for idx := range events {
+		if events[idx].Time.Before(since) {
+			summary.Initial = &events[idx]
+		} else if !events[idx].Time.After(until) {
+			summary.Events = append(summary.Events, events[idx])
+		}
+	}
+
+	current := summary.Initial
+	currentStart := since
+	for idx := range summary.Events {
+		event := &summary.Events[idx]
+		if current != nil {
+			summary.TimeInStatus[current.Status] += event.Time.Sub(currentStart)
+
+			if current.Status != event.Status {
+				summary.Changes++
+			}
+		}
+
+		//A -> B -> A with B lasting less than the flap window
+		if idx > 0 && current != nil && event.Time.Sub(current.Time) < HistoryFlapWindow {
+			var previous *HistoryEvent
+			if idx > 1 {
+				previous = &summary.Events[idx-2]
+			} else {
+				previous = summary.Initial
+			}
+			if previous != nil && previous.Status == event.Status && current.Status != event.Status {
+				summary.Flaps++
+			}
+		}
+
+		current = event
+		currentStart = event.Time
+	}
+
+	if current != nil {
+		summary.TimeInStatus[current.Status] += until.Sub(currentStart)
+	}
+
+	return summary
+}
+
+// parses --since as a duration before now ("36h"), a date or an RFC3339 time
+func parseHistorySince(value string, now time.Time) (time.Time, error) {
+	if duration, err := time.ParseDuration(value); err == nil {
+		return now.Add(-duration), nil
+	}
+	if since, err := time.ParseInLocation(HistoryDateFormat, value, templateLocation()); err == nil {
+		return since, nil
+	}
+	if since, err := time.Parse(time.RFC3339, value); err == nil {
+		return since, nil
+	}
+	return time.Time{}, fmt.Errorf("Can't parse --since value '%s', expecting a duration (36h), date (%s) or RFC3339 time", value, HistoryDateFormat)
+}
+
+// builds the pattern used to match scraper names on the command line, * matches anything
+func scraperNamePattern(arg string) *regexp.Regexp {
+	pattern, err := compileScraperNamePattern(arg)
+	if err != nil {
+		panic(err)
+	}
+	return pattern
+}
+
+// like scraperNamePattern, for names from the config
+func compileScraperNamePattern(arg string) (*regexp.Regexp, error) {
+	patternStr := fmt.Sprintf("^%s$", arg)
+	if strings.Contains(patternStr, "*") {
+		patternStr = strings.ReplaceAll(patternStr, "*", ".*")
+	}
+
+	pattern, err := regexp.Compile(patternStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid scraper name pattern %s: %v", arg, err)
+	}
+	return pattern, nil
+}
+
+func formatHistoryDuration(duration time.Duration) string {
+	return duration.Round(time.Second).String()
+}
+
+// history <pattern> [--since <duration|date>]
+func runHistoryCommand(args []string) error {
+	if !History.Enabled() {
+		return fmt.Errorf("history_dir is not configured")
+	}
+
+	if len(args) < 3 {
+		return fmt.Errorf("Usage: %s history <scraper_name> [--since <36h|%s>]", filepath.Base(args[0]), HistoryDateFormat)
+	}
+
+	pattern := scraperNamePattern(args[2])
+	until := time.Now()
+	since := until.Add(-HistoryDefaultSince)
+
+	for idx := 3; idx < len(args); idx++ {
+		value := strings.TrimPrefix(args[idx], "--since=")
+		if args[idx] == "--since" && idx+1 < len(args) {
+			idx++
+			value = args[idx]
+		} else if value == args[idx] {
+			return fmt.Errorf("Unknown argument: %s", args[idx])
+		}
+
+		var err error
+		if since, err = parseHistorySince(value, until); err != nil {
+			return err
+		}
+	}
+
+	names, err := History.Names()
+	if err != nil {
+		return err
+	}
+
+	loc := templateLocation()
+	matched := 0
+	for _, name := range names {
+		if !pattern.MatchString(name) {
+			continue
+		}
+		matched++
+
+		events, err := History.Read(name)
+		if err != nil {
+			return err
+		}
+		summary := SummarizeHistory(name, events, since, until)
+
+		fmt.Printf("%s (since %s)\n", name, since.In(loc).Format(HistoryTimeFormat))
+		if summary.Initial != nil {
+			fmt.Printf("  %s  %s (before window)\n", summary.Initial.Time.In(loc).Format(HistoryTimeFormat), summary.Initial.Status)
+		}
+		for _, event := range summary.Events {
+			line := fmt.Sprintf("  %s  %s", event.Time.In(loc).Format(HistoryTimeFormat), event.Status)
+			if len(event.ErrorClass) > 0 {
+				line += fmt.Sprintf(" (%s error: %s)", event.ErrorClass, event.Error)
+			}
+			fmt.Println(line)
+		}
+
+		statuses := make([]string, 0, len(summary.TimeInStatus))
+		for status := range summary.TimeInStatus {
+			statuses = append(statuses, string(status))
+		}
+		sort.Strings(statuses)
+
+		totals := make([]string, 0, len(statuses))
+		for _, status := range statuses {
+			totals = append(totals, fmt.Sprintf("%s %s", status, formatHistoryDuration(summary.TimeInStatus[Status(status)])))
+		}
+
+		fmt.Printf("  time in status: %s\n", strings.Join(totals, ", "))
+		fmt.Printf("  changes: %d, flaps: %d\n\n", summary.Changes, summary.Flaps)
+	}
+
+	if matched == 0 {
+		return fmt.Errorf("No history found for: %s", args[2])
+	}
+
+	return nil
+}
@@ -0,0 +1,84 @@
+package csg
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestHistoryStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store := NewHistoryStore(dir)
+	results := []struct {
+		status Status
+		err    error
+	}{
+		{StatusNo, nil},
+		{StatusNo, nil},
+		{StatusYes, nil},
+		{StatusUnknown, NewHttpStatusError(500, "http://foo", nil)},
+		{StatusUnknown, NewHttpStatusError(502, "http://foo", nil)},
+		{StatusYes, nil},
+	}
+	for _, result := range results {
+		if err := store.Record("TestHistoryStore", result.status, result.err); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	//a new store picks up where the last one left off
+	if err := NewHistoryStore(dir).Record("TestHistoryStore", StatusYes, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := store.Read("TestHistoryStore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 4 || events[2].Status != StatusUnknown || events[2].ErrorClass != "http_status" {
+		t.Fatalf("Unexpected events: %+v", events)
+	}
+
+	names, err := store.Names()
+	if err != nil || len(names) != 1 || names[0] != "TestHistoryStore" {
+		t.Errorf("Unexpected names: %v (%v)", names, err)
+	}
+}
+
+func TestSummarizeHistory(t *testing.T) {
+	start := time.Date(2021, 4, 1, 0, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time {
+		return start.Add(time.Duration(minutes) * time.Minute)
+	}
+
+	events := []HistoryEvent{
+		{Time: at(-60), Status: StatusNo},
+		{Time: at(60), Status: StatusYes},
+		{Time: at(70), Status: StatusNo}, //flap
+		{Time: at(120), Status: StatusYes},
+	}
+
+	summary := SummarizeHistory("TestSummarizeHistory", events, at(0), at(180))
+	if summary.Initial == nil || summary.Initial.Status != StatusNo || len(summary.Events) != 3 {
+		t.Fatalf("Unexpected window: %+v", summary)
+	}
+	if summary.Changes != 3 || summary.Flaps != 1 {
+		t.Errorf("Expected 3 changes and 1 flap, got %d and %d", summary.Changes, summary.Flaps)
+	}
+	if summary.TimeInStatus[StatusNo] != 110*time.Minute || summary.TimeInStatus[StatusYes] != 70*time.Minute {
+		t.Errorf("Unexpected time in status: %v", summary.TimeInStatus)
+	}
+
+	if _, err := parseHistorySince("36h", start); err != nil {
+		t.Error(err)
+	}
+	if _, err := parseHistorySince("yesterday", start); err == nil {
+		t.Errorf("Expected error for unparseable --since")
+	}
+}
@@ -0,0 +1,50 @@
+package csg
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+//optional http listeners for the continuous runner, handlers registered for the same address share a server
+
+const HttpServerReadTimeout = 10
+const HttpServerWriteTimeout = 30
+
+var httpMuxes = make(map[string]*http.ServeMux)
+var httpMuxesLock = new(sync.Mutex)
+
+func handleHttp(addr string, pattern string, handler http.Handler) {
+	httpMuxesLock.Lock()
+	defer httpMuxesLock.Unlock()
+
+	mux, exists := httpMuxes[addr]
+	if !exists {
+		mux = http.NewServeMux()
+		httpMuxes[addr] = mux
+	}
+
+	mux.Handle(pattern, handler)
+}
+
+// starts a server for every address with registered handlers
+func startHttpServers() {
+	httpMuxesLock.Lock()
+	defer httpMuxesLock.Unlock()
+
+	for addr, mux := range httpMuxes {
+		server := &http.Server{
+			Addr:         addr,
+			Handler:      mux,
+			ReadTimeout:  HttpServerReadTimeout * time.Second,
+			WriteTimeout: HttpServerWriteTimeout * time.Second,
+		}
+
+		Log.Infof("Listening on %s", addr)
+		go func() {
+			if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				Log.Errorf("http server %s: %v", server.Addr, err)
+			}
+		}()
+	}
+}
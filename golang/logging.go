@@ -1,16 +1,34 @@
 package csg
 
 import (
+	"encoding/json"
 	"fmt"
 	"github.com/kataras/golog"
 	"io"
 	"runtime"
 	"strings"
+	"sync"
 	"time"
 )
 
+const LogFormatText = "text"
+const LogFormatJson = "json"
+
+// context fields carried by scraper loggers, see LogFor
+const LogFieldScraper = "scraper"
+const LogFieldType = "type"
+const LogFieldRunId = "run_id"
+const LogFieldScrapeId = "scrape_id"
+const LogFieldStage = "stage"
+const LogFieldUrl = "url"
+
+const JsonLogTimeFormat = "2006-01-02T15:04:05.000Z07:00"
+
 var Log = newLogger()
 
+var scraperLoggers = make(map[string]*ScraperLogger)
+var scraperLoggersLock = new(sync.Mutex)
+
 type ScraperFormatter struct{}
 
 // The name of the formatter.
@@ -28,7 +46,7 @@ func (s *ScraperFormatter) Options(_ ...interface{}) golog.Formatter {
 // Writes the "log" to "dest" logger.
 func (s *ScraperFormatter) Format(dest io.Writer, log *golog.Log) bool {
 	timestamp := time.Now().Format(time.RFC1123)
-	line := fmt.Sprintf("%s %s %s: %s%s", timestamp, golog.Levels[log.Level].Text(true), getCallingFunction(), log.Message, NEWLINE)
+	line := fmt.Sprintf("%s %s %s: %s%s%s", timestamp, golog.Levels[log.Level].Text(true), getCallingFunction(), textLogContext(log.Fields), log.Message, NEWLINE)
 	if _, err := dest.Write([]byte(line)); err != nil {
 		fmt.Printf("[FATAL] error in logger: %+v\n", err)
 		return false
@@ -36,15 +54,212 @@ func (s *ScraperFormatter) Format(dest io.Writer, log *golog.Log) bool {
 	return true
 }
 
+// renders scraper context the way it used to be embedded in messages, e.g. "name: stage 1: "
+func textLogContext(fields golog.Fields) string {
+	var context string
+	if scraper, exists := fields[LogFieldScraper]; exists {
+		context += fmt.Sprintf("%v: ", scraper)
+	}
+	if stage, exists := fields[LogFieldStage]; exists {
+		context += fmt.Sprintf("stage %v: ", stage)
+	}
+	return context
+}
+
+// one json object per line, doesn't look up the calling function
+type JsonFormatter struct {
+	lock *sync.Mutex
+}
+
+type JsonLogLine struct {
+	Timestamp string                 `json:"timestamp"`
+	Level     string                 `json:"level"`
+	Scraper   string                 `json:"scraper,omitempty"`
+	Type      string                 `json:"type,omitempty"`
+	RunId     string                 `json:"run_id,omitempty"`
+	ScrapeId  string                 `json:"scrape_id,omitempty"`
+	Stage     string                 `json:"stage,omitempty"`
+	Url       string                 `json:"url,omitempty"`
+	Message   string                 `json:"message"`
+	Fields    map[string]interface{} `json:"fields,omitempty"`
+}
+
+// The name of the formatter.
+func (j *JsonFormatter) String() string {
+	return "JsonFormatter"
+}
+
+// Set any options and return a clone,
+// generic. See `Logger.SetFormat`.
+func (j *JsonFormatter) Options(_ ...interface{}) golog.Formatter {
+	return j
+}
+
+// Writes the "log" to "dest" logger.
+func (j *JsonFormatter) Format(dest io.Writer, log *golog.Log) bool {
+	line := JsonLogLine{
+		Timestamp: time.Now().Format(JsonLogTimeFormat),
+		Level:     log.Level.String(),
+		RunId:     CurrentRunId,
+		Message:   log.Message,
+	}
+
+	for key, value := range log.Fields {
+		switch key {
+		case LogFieldScraper:
+			line.Scraper = fmt.Sprint(value)
+		case LogFieldType:
+			line.Type = fmt.Sprint(value)
+		case LogFieldRunId:
+			line.RunId = fmt.Sprint(value)
+		case LogFieldScrapeId:
+			line.ScrapeId = fmt.Sprint(value)
+		case LogFieldStage:
+			line.Stage = fmt.Sprint(value)
+		case LogFieldUrl:
+			line.Url = fmt.Sprint(value)
+		default:
+			if line.Fields == nil {
+				line.Fields = make(map[string]interface{})
+			}
+			line.Fields[key] = value
+		}
+	}
+
+	jsonBytes, err := json.Marshal(line)
+	if err != nil {
+		fmt.Printf("[FATAL] error in logger: %+v\n", err)
+		return false
+	}
+
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	if _, err := dest.Write(append(jsonBytes, '\n')); err != nil {
+		fmt.Printf("[FATAL] error in logger: %+v\n", err)
+		return false
+	}
+	return true
+}
+
 // configure logging here
 func newLogger() *golog.Logger {
 	logger := golog.New()
 	logger.RegisterFormatter(&ScraperFormatter{})
+	logger.RegisterFormatter(&JsonFormatter{lock: new(sync.Mutex)})
 	logger.SetLevel("info")
 	logger.SetFormat("ScraperFormatter")
+	//neither formatter prints golog's stack traces, don't collect them
+	logger.SetStacktraceLimit(-1)
 	return logger
 }
 
+// switches the global logger (and any scraper loggers created afterwards) to "text" or "json" output
+func SetLogFormat(format string) error {
+	switch strings.ToLower(format) {
+	case "", LogFormatText:
+		Log.SetFormat("ScraperFormatter")
+	case LogFormatJson:
+		Log.SetFormat("JsonFormatter")
+	default:
+		return fmt.Errorf("Unknown log format: %s", format)
+	}
+	return nil
+}
+
+func parseLogLevel(levelName string) (golog.Level, error) {
+	level := golog.ParseLevel(levelName)
+	if level == golog.DisableLevel && golog.Levels[golog.DisableLevel].Name != strings.ToLower(levelName) {
+		return level, fmt.Errorf("Unknown log level: %s", levelName)
+	}
+	return level, nil
+}
+
+// logger carrying scraper context fields, so every line is attributable in json output
+type ScraperLogger struct {
+	logger *golog.Logger
+	fields golog.Fields
+}
+
+// registers the logger for a scraper, levelName overrides the global log level if not empty
+func SetScraperLogger(name string, scraperType string, levelName string) (*ScraperLogger, error) {
+	logger := Log.Clone()
+	if len(levelName) > 0 {
+		level, err := parseLogLevel(levelName)
+		if err != nil {
+			return nil, err
+		}
+		logger.Level = level
+	}
+
+	scraperLogger := &ScraperLogger{
+		logger: logger,
+		fields: golog.Fields{LogFieldScraper: name},
+	}
+	if len(scraperType) > 0 {
+		scraperLogger.fields[LogFieldType] = scraperType
+	}
+
+	scraperLoggersLock.Lock()
+	defer scraperLoggersLock.Unlock()
+	scraperLoggers[name] = scraperLogger
+
+	return scraperLogger, nil
+}
+
+// returns the logger registered for a scraper, or one inheriting the global settings
+func LogFor(name string) *ScraperLogger {
+	scraperLoggersLock.Lock()
+	scraperLogger, exists := scraperLoggers[name]
+	scraperLoggersLock.Unlock()
+
+	if exists {
+		return scraperLogger
+	}
+
+	scraperLogger, _ = SetScraperLogger(name, "", "")
+	return scraperLogger
+}
+
+// replaces the registered logger of a scraper with one carrying the ids of its current scrape
+func setScraperLogIds(name string, runId string, scrapeId string) {
+	scraperLogger := LogFor(name).With(LogFieldRunId, runId).With(LogFieldScrapeId, scrapeId)
+
+	scraperLoggersLock.Lock()
+	defer scraperLoggersLock.Unlock()
+	scraperLoggers[name] = scraperLogger
+}
+
+// returns a copy of the logger with an additional context field, e.g. LogFieldStage or LogFieldUrl
+func (l *ScraperLogger) With(key string, value interface{}) *ScraperLogger {
+	fields := make(golog.Fields, len(l.fields)+1)
+	for k, v := range l.fields {
+		fields[k] = v
+	}
+	fields[key] = value
+
+	return &ScraperLogger{logger: l.logger, fields: fields}
+}
+
+func (l *ScraperLogger) withFields(args []interface{}) []interface{} {
+	return append(append(make([]interface{}, 0, len(args)+1), args...), l.fields)
+}
+
+func (l *ScraperLogger) Debugf(format string, args ...interface{}) {
+	l.logger.Debugf(format, l.withFields(args)...)
+}
+
+func (l *ScraperLogger) Infof(format string, args ...interface{}) {
+	l.logger.Infof(format, l.withFields(args)...)
+}
+
+func (l *ScraperLogger) Warnf(format string, args ...interface{}) {
+	l.logger.Warnf(format, l.withFields(args)...)
+}
+
+func (l *ScraperLogger) Errorf(format string, args ...interface{}) {
+	l.logger.Errorf(format, l.withFields(args)...)
+}
+
 func getStackFrame(skipFrames int, skipFnNames []string) runtime.Frame {
 	// We need the frame at index skipFrames+2, since we never want runtime.Callers and getFrame
 	targetFrameIndex := skipFrames + 2
@@ -87,7 +302,7 @@ func getStackFrame(skipFrames int, skipFnNames []string) runtime.Frame {
 // returns the name of the function that called it :)
 // skips any logging/goroutine stack frames
 func getCallingFunction() string {
-	skipFnNames := []string{"kataras", "golang.Run"}
+	skipFnNames := []string{"kataras", "golang.Run", "(*ScraperLogger)"}
 	parts := strings.Split(getStackFrame(2, skipFnNames).Function, "/")
 	return parts[len(parts)-1]
 }
@@ -0,0 +1,54 @@
+package csg
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/kataras/golog"
+	"strings"
+	"testing"
+)
+
+func TestJsonLogFormat(t *testing.T) {
+	output := new(bytes.Buffer)
+	logger := newLogger()
+	logger.SetFormat("JsonFormatter")
+	logger.SetOutput(output)
+
+	scraperLogger := &ScraperLogger{logger: logger, fields: golog.Fields{LogFieldScraper: "TestJsonLogFormat", LogFieldType: "multistage_regexp"}}
+	scraperLogger.With(LogFieldStage, 1).With(LogFieldUrl, "https://foo/bar").Infof("matched %d", 3)
+	scraperLogger.Debugf("not logged at info level")
+
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("Expected 1 line, got %d: %s", len(lines), output.String())
+	}
+
+	var line JsonLogLine
+	if err := json.Unmarshal([]byte(lines[0]), &line); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := JsonLogLine{Timestamp: line.Timestamp, Level: "info", Scraper: "TestJsonLogFormat", Type: "multistage_regexp", Stage: "1", Url: "https://foo/bar", Message: "matched 3"}
+	if line.Timestamp == "" || line.Level != expected.Level || line.Scraper != expected.Scraper || line.Type != expected.Type ||
+		line.Stage != expected.Stage || line.Url != expected.Url || line.Message != expected.Message || line.Fields != nil {
+		t.Errorf("Expected %+v, got %+v", expected, line)
+	}
+}
+
+func TestScraperLogLevel(t *testing.T) {
+	scraperLogger, err := SetScraperLogger("TestScraperLogLevel", "standard_regexp", "debug")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scraperLogger.logger.Level != golog.DebugLevel || LogFor("TestScraperLogLevel") != scraperLogger {
+		t.Errorf("Expected registered debug logger, got level %v", scraperLogger.logger.Level)
+	}
+
+	if _, err := SetScraperLogger("TestScraperLogLevel", "standard_regexp", "verbose"); err == nil {
+		t.Errorf("Expected error for unknown log level")
+	}
+
+	if context := textLogContext(golog.Fields{LogFieldScraper: "foo", LogFieldStage: 2}); context != "foo: stage 2: " {
+		t.Errorf("Unexpected text context: %s", context)
+	}
+}
@@ -0,0 +1,314 @@
+package csg
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//minimal prometheus metrics registry, served in the text exposition format on metrics_addr
+
+const MetricsPath = "/metrics"
+const MetricsPrefix = "covidwa_"
+
+const MetricTypeCounter = "counter"
+const MetricTypeGauge = "gauge"
+const MetricTypeHistogram = "histogram"
+
+const MetricResultSuccess = "success"
+const MetricResultFailure = "failure"
+
+var MetricsDurationBuckets = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120}
+var MetricsLatencyBuckets = []float64{0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+type Metric struct {
+	Name       string
+	Help       string
+	Type       string
+	LabelNames []string
+	Buckets    []float64 //histograms only
+	samples    map[string]*metricSample
+	lock       *sync.Mutex
+}
+
+type metricSample struct {
+	labels       []string
+	value        float64
+	bucketCounts []uint64
+	count        uint64
+}
+
+type metricsRegistry struct {
+	metrics    []*Metric
+	collectors []func()
+	lock       *sync.Mutex
+}
+
+var metrics = &metricsRegistry{lock: new(sync.Mutex)}
+
+var metricScrapeDuration = NewHistogram("scrape_duration_seconds", "Time taken by each scrape.", MetricsDurationBuckets, "scraper", "type")
+var metricScraperStatus = NewGauge("scraper_status", "Last status of each scraper, 1 for the current status.", "scraper", "status")
+var metricScraperStale = NewGauge("scraper_stale", "1 if the scraper is overdue for a successful scrape, see stale_factor.", "scraper")
+var metricScrapeErrors = NewCounter("scrape_errors_total", "Scrape errors by error class.", "scraper", "class")
+var metricHttpRequests = NewCounter("http_requests_total", "Outgoing http requests by host and status code.", "host", "code")
+var metricHttpDuration = NewHistogram("http_request_duration_seconds", "Outgoing http request latency by host.", MetricsLatencyBuckets, "host")
+var metricHttpBytes = NewCounter("http_response_bytes_total", "Bytes received by host.", "host")
+var metricCacheHits = NewCounter("cache_hits_total", "Cache hits.")
+var metricCacheMisses = NewCounter("cache_misses_total", "Cache misses.")
+var metricCacheEvictions = NewCounter("cache_evictions_total", "Cache entries evicted to stay within size bounds.")
+var metricCacheEntries = NewGauge("cache_entries", "Entries currently in the cache.")
+var metricApiSends = NewCounter("api_sends_total", "Status updates sent by sink and result.", "sink", "result")
+var metricOutboxDepth = NewGauge("outbox_depth", "Status updates waiting in the outbox by sink.", "sink")
+var metricNotifications = NewCounter("notifications_sent_total", "Notifications sent by notifier and result.", "notifier", "result")
+var metricProxyAcquire = NewHistogram("proxy_acquire_duration_seconds", "Time taken to find a working proxy.", MetricsLatencyBuckets, "provider")
+var metricConditionalGets = NewCounter("conditional_get_requests_total", "Conditional GET requests by scraper.", "scraper")
+var metricNotModified = NewCounter("conditional_get_not_modified_total", "Conditional GET requests answered with 304 Not Modified.", "scraper")
+
+var _ = RegisterMetricsCollector(collectCacheMetrics)
+var _ = RegisterMetricsCollector(collectConditionalGetMetrics)
+var _ = RegisterMetricsCollector(collectOutboxMetrics)
+
+func newMetric(name string, help string, metricType string, buckets []float64, labelNames []string) *Metric {
+	m := new(Metric)
+	m.Name = MetricsPrefix + name
+	m.Help = help
+	m.Type = metricType
+	m.LabelNames = labelNames
+	m.Buckets = buckets
+	m.samples = make(map[string]*metricSample)
+	m.lock = new(sync.Mutex)
+
+	metrics.lock.Lock()
+	defer metrics.lock.Unlock()
+	metrics.metrics = append(metrics.metrics, m)
+
+	return m
+}
+
+func NewCounter(name string, help string, labelNames ...string) *Metric {
+	return newMetric(name, help, MetricTypeCounter, nil, labelNames)
+}
+
+func NewGauge(name string, help string, labelNames ...string) *Metric {
+	return newMetric(name, help, MetricTypeGauge, nil, labelNames)
+}
+
+func NewHistogram(name string, help string, buckets []float64, labelNames ...string) *Metric {
+	return newMetric(name, help, MetricTypeHistogram, buckets, labelNames)
+}
+
+// registers a function called before metrics are written, for values read from elsewhere (e.g. cache stats)
+func RegisterMetricsCollector(collector func()) bool {
+	metrics.lock.Lock()
+	defer metrics.lock.Unlock()
+
+	metrics.collectors = append(metrics.collectors, collector)
+	return true
+}
+
+// must be called with lock held
+func (m *Metric) sample(labels []string) *metricSample {
+	if len(labels) != len(m.LabelNames) {
+		panic(fmt.Sprintf("%s: expecting %d label values, got %d", m.Name, len(m.LabelNames), len(labels)))
+	}
+
+	key := strings.Join(labels, "\x00")
+	s, exists := m.samples[key]
+	if !exists {
+		s = new(metricSample)
+		s.labels = append([]string(nil), labels...)
+		if m.Type == MetricTypeHistogram {
+			s.bucketCounts = make([]uint64, len(m.Buckets))
+		}
+		m.samples[key] = s
+	}
+
+	return s
+}
+
+func (m *Metric) Add(value float64, labels ...string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.sample(labels).value += value
+}
+
+func (m *Metric) Inc(labels ...string) {
+	m.Add(1, labels...)
+}
+
+// sets the value of a gauge, or of a counter mirrored from another source
+func (m *Metric) Set(value float64, labels ...string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.sample(labels).value = value
+}
+
+func (m *Metric) Observe(value float64, labels ...string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	s := m.sample(labels)
+	s.value += value
+	s.count++
+	for i, bound := range m.Buckets {
+		if value <= bound {
+			s.bucketCounts[i]++
+		}
+	}
+}
+
+func (m *Metric) ObserveDuration(start time.Time, labels ...string) {
+	m.Observe(time.Since(start).Seconds(), labels...)
+}
+
+var metricLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatMetricLabels(names []string, values []string, extraName string, extraValue string) string {
+	parts := make([]string, 0, len(names)+1)
+	for i, name := range names {
+		parts = append(parts, fmt.Sprintf(`%s="%s"`, name, metricLabelEscaper.Replace(values[i])))
+	}
+	if len(extraName) > 0 {
+		parts = append(parts, fmt.Sprintf(`%s="%s"`, extraName, extraValue))
+	}
+
+	if len(parts) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+func formatMetricValue(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+func (m *Metric) write(w io.Writer) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", m.Name, m.Help, m.Name, m.Type)
+
+	keys := make([]string, 0, len(m.samples))
+	for key := range m.samples {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		s := m.samples[key]
+		if m.Type != MetricTypeHistogram {
+			fmt.Fprintf(w, "%s%s %s\n", m.Name, formatMetricLabels(m.LabelNames, s.labels, "", ""), formatMetricValue(s.value))
+			continue
+		}
+
+		for i, bound := range m.Buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", m.Name, formatMetricLabels(m.LabelNames, s.labels, "le", formatMetricValue(bound)), s.bucketCounts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", m.Name, formatMetricLabels(m.LabelNames, s.labels, "le", "+Inf"), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", m.Name, formatMetricLabels(m.LabelNames, s.labels, "", ""), formatMetricValue(s.value))
+		fmt.Fprintf(w, "%s_count%s %d\n", m.Name, formatMetricLabels(m.LabelNames, s.labels, "", ""), s.count)
+	}
+}
+
+// writes all metrics in the prometheus text exposition format
+func WriteMetrics(w io.Writer) {
+	metrics.lock.Lock()
+	collectors := append([]func(){}, metrics.collectors...)
+	registered := append([]*Metric{}, metrics.metrics...)
+	metrics.lock.Unlock()
+
+	for _, collector := range collectors {
+		collector()
+	}
+
+	for _, m := range registered {
+		m.write(w)
+	}
+}
+
+func MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var buf bytes.Buffer
+		WriteMetrics(&buf)
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_, _ = w.Write(buf.Bytes())
+	})
+}
+
+func collectCacheMetrics() {
+	stats := Cache.Stats()
+	metricCacheHits.Set(float64(stats.Hits))
+	metricCacheMisses.Set(float64(stats.Misses))
+	metricCacheEvictions.Set(float64(stats.Evictions))
+	metricCacheEntries.Set(float64(stats.Entries))
+}
+
+func collectConditionalGetMetrics() {
+	for name, stats := range GetConditionalGetStats() {
+		metricConditionalGets.Set(float64(stats.Requests), name)
+		metricNotModified.Set(float64(stats.NotModified), name)
+	}
+}
+
+func collectOutboxMetrics() {
+	outbox := StatusOutbox()
+	if outbox == nil {
+		return
+	}
+
+	depth := outbox.DepthBySink()
+	for _, sink := range StatusSinks() {
+		metricOutboxDepth.Set(float64(depth[sink.Name()]), sink.Name())
+	}
+}
+
+var metricStatuses = []Status{StatusYes, StatusLimited, StatusCall, StatusWaitList, StatusNo, StatusPossible, StatusUnknown, StatusApiSkip, StatusApifail}
+
+func recordScrape(name string, scraperType string, start time.Time, err error) {
+	metricScrapeDuration.ObserveDuration(start, name, scraperType)
+
+	if err != nil {
+		metricScrapeErrors.Inc(name, ErrorClass(err))
+	}
+}
+
+// called once the status is final, i.e. after the api send, which may turn it into APIFail
+func recordScraperStatus(name string, status Status) {
+	for _, s := range metricStatuses {
+		value := 0.0
+		if s == status {
+			value = 1
+		}
+		metricScraperStatus.Set(value, name, string(s))
+	}
+}
+
+// code is 0 if the request failed before a response was received
+func recordHttpRequest(host string, code int, start time.Time, size int) {
+	codeStr := "error"
+	if code > 0 {
+		codeStr = strconv.Itoa(code)
+	}
+
+	metricHttpRequests.Inc(host, codeStr)
+	metricHttpDuration.ObserveDuration(start, host)
+	if size > 0 {
+		metricHttpBytes.Add(float64(size), host)
+	}
+}
+
+func metricResult(success bool) string {
+	if success {
+		return MetricResultSuccess
+	}
+	return MetricResultFailure
+}
@@ -0,0 +1,87 @@
+package csg
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteMetrics(t *testing.T) {
+	recordScrape("TestWriteMetrics", "standard_regexp", time.Now().Add(-3*time.Second), NewHttpStatusError(500, "http://foo", nil))
+	recordScraperStatus("TestWriteMetrics", StatusNo)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("hello"))
+	}))
+	defer server.Close()
+
+	endpoint := new(Endpoint)
+	endpoint.Url = server.URL
+	endpoint.Method = "GET"
+	if _, _, err := endpoint.Fetch("TestWriteMetrics"); err != nil {
+		t.Fatal(err)
+	}
+
+	metricsServer := httptest.NewServer(MetricsHandler())
+	defer metricsServer.Close()
+
+	resp, err := http.Get(metricsServer.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+
+	host := strings.TrimPrefix(server.URL, "http://")
+	expected := []string{
+		"# TYPE covidwa_scrape_duration_seconds histogram",
+		`covidwa_scrape_duration_seconds_bucket{scraper="TestWriteMetrics",type="standard_regexp",le="2.5"} 0`,
+		`covidwa_scrape_duration_seconds_bucket{scraper="TestWriteMetrics",type="standard_regexp",le="5"} 1`,
+		`covidwa_scrape_duration_seconds_count{scraper="TestWriteMetrics",type="standard_regexp"} 1`,
+		`covidwa_scraper_status{scraper="TestWriteMetrics",status="No"} 1`,
+		`covidwa_scraper_status{scraper="TestWriteMetrics",status="Yes"} 0`,
+		`covidwa_scrape_errors_total{scraper="TestWriteMetrics",class="http_status"} 1`,
+		fmt.Sprintf(`covidwa_http_requests_total{host="%s",code="200"} 1`, host),
+		fmt.Sprintf(`covidwa_http_response_bytes_total{host="%s"} 5`, host),
+		"# TYPE covidwa_cache_hits_total counter",
+	}
+
+	for _, line := range expected {
+		if !bytes.Contains(body, []byte(line+"\n")) {
+			t.Errorf("Expected metrics output to contain: %s", line)
+		}
+	}
+}
+
+func metricValue(m *Metric, labels ...string) float64 {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	return m.sample(labels).value
+}
+
+func TestScraperStatusMetricApiFail(t *testing.T) {
+	prevConfig := config
+	config = &Config{ApiInterval: 180} //no error_warning_threshold, so the update fails without being sent
+	defer func() { config = prevConfig }()
+
+	sinks, err := NewStatusSinks(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prevSinks := StatusSinks()
+	SetStatusSinks(sinks)
+	defer SetStatusSinks(prevSinks)
+
+	ctx, tracker := newStatusApiTestContext(&ScraperConfig{ApiKey: "key"})
+	doScrapeAndSend(tracker, ctx, true, nil)
+
+	if ctx.Status != StatusApifail || metricValue(metricScraperStatus, ctx.Name, string(StatusApifail)) != 1 || metricValue(metricScraperStatus, ctx.Name, string(StatusYes)) != 0 {
+		t.Errorf("Expected the status metric to show %s, got %s", StatusApifail, ctx.Status)
+	}
+}
@@ -0,0 +1,347 @@
+package csg
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+//a local stand-in for the covidwa backend, for development without the test backend or api secrets.
+//serves the updater, batch updater and get_internal endpoints, with clinics from a json or csv file, and
+//records the updates it receives.  run it with the mock-api command, or use StartMockApi in tests
+
+const MockApiUpdaterPath = "/v1/updater"
+const MockApiBatchPath = "/v1/batch_updater"
+const MockApiInternalPath = "/v1/get_internal"
+const MockApiUpdatesPath = "/updates" //the updates received so far, as json
+const DefaultMockApiAddr = "localhost:8080"
+const MockApiInvalidSecret = "invalid secret"
+
+type MockApi struct {
+	secret      string //checked if set, any secret is accepted otherwise
+	clinics     []Clinic
+	updates     []*CovidWaUpdatePayload
+	updatesPath string //optional jsonl file the updates are appended to
+	lock        *sync.Mutex
+}
+
+func NewMockApi(clinics []Clinic, secret string) *MockApi {
+	if clinics == nil {
+		clinics = []Clinic{}
+	}
+
+	return &MockApi{
+		secret:  secret,
+		clinics: clinics,
+		updates: make([]*CovidWaUpdatePayload, 0),
+		lock:    new(sync.Mutex),
+	}
+}
+
+// a mock api on a test server, call Close on the server when done
+func StartMockApi(clinics []Clinic, secret string) (*MockApi, *httptest.Server) {
+	api := NewMockApi(clinics, secret)
+	return api, httptest.NewServer(api.Handler())
+}
+
+// config pointing the api urls at a mock api server
+func MockApiConfig(serverUrl string, secret string) *Config {
+	return &Config{
+		ApiUrl:         serverUrl + MockApiUpdaterPath,
+		ApiInternalUrl: serverUrl + MockApiInternalPath,
+		ApiSecret:      secret,
+	}
+}
+
+// reads clinics from a csv file with a header row (id, name, key, url, alternateUrl, scraper_config),
+// or a json file with either a get_internal response or an array of clinics
+func LoadMockApiClinics(path string) ([]Clinic, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		return parseMockApiClinicsCsv(contents)
+	}
+
+	clinics := make([]Clinic, 0)
+	if strings.HasPrefix(strings.TrimSpace(string(contents)), "[") {
+		err = json.Unmarshal(contents, &clinics)
+	} else {
+		apiResp := ClinicsAPIResp{}
+		err = json.Unmarshal(contents, &apiResp)
+		clinics = apiResp.Clinics
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	return clinics, nil
+}
+
+func parseMockApiClinicsCsv(contents []byte) ([]Clinic, error) {
+	rows, err := csv.NewReader(strings.NewReader(string(contents))).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return []Clinic{}, nil
+	}
+
+	columns := make(map[string]int)
+	for idx, column := range rows[0] {
+		columns[strings.TrimSpace(column)] = idx
+	}
+	if _, exists := columns["key"]; !exists {
+		return nil, fmt.Errorf("csv header has no key column: %v", rows[0])
+	}
+
+	value := func(row []string, column string) string {
+		if idx, exists := columns[column]; exists && idx < len(row) {
+			return strings.TrimSpace(row[idx])
+		}
+		return ""
+	}
+
+	clinics := make([]Clinic, 0, len(rows)-1)
+	for _, row := range rows[1:] {
+		clinics = append(clinics, Clinic{
+			Id:            value(row, "id"),
+			Name:          value(row, "name"),
+			ApiKey:        value(row, "key"),
+			Url:           value(row, "url"),
+			AlternateUrl:  value(row, "alternateUrl"),
+			ScraperConfig: value(row, "scraper_config"),
+		})
+	}
+
+	return clinics, nil
+}
+
+func (m *MockApi) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(MockApiUpdaterPath, m.handleUpdate)
+	mux.HandleFunc(MockApiBatchPath, m.handleBatch)
+	mux.HandleFunc(MockApiInternalPath, m.handleInternal)
+	mux.HandleFunc(MockApiUpdatesPath, m.handleUpdates)
+	return mux
+}
+
+// the updates received so far, with their secrets
+func (m *MockApi) Updates() []*CovidWaUpdatePayload {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	return append([]*CovidWaUpdatePayload{}, m.updates...)
+}
+
+// the last update received for the key, nil if none
+func (m *MockApi) LastUpdate(key string) *CovidWaUpdatePayload {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	for idx := len(m.updates) - 1; idx >= 0; idx-- {
+		if m.updates[idx].Key == key {
+			return m.updates[idx]
+		}
+	}
+	return nil
+}
+
+func (m *MockApi) writeJson(w http.ResponseWriter, statusCode int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		Log.Warnf("mock api: %v", err)
+	}
+}
+
+// checks the update, returns an error message if it's rejected
+func (m *MockApi) record(update *CovidWaUpdatePayload) string {
+	if len(m.secret) > 0 && update.Secret != m.secret {
+		return MockApiInvalidSecret
+	}
+	if len(update.Key) == 0 {
+		return "missing key"
+	}
+	if len(update.Status) == 0 {
+		return "missing status"
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	known := len(m.clinics) == 0
+	for _, clinic := range m.clinics {
+		if clinic.ApiKey == update.Key {
+			known = true
+			break
+		}
+	}
+	if !known {
+		Log.Warnf("mock api: update for a key not in the clinics file: %s", update.Key)
+	}
+
+	m.updates = append(m.updates, update)
+	Log.Infof("mock api: %s -> %s %v", update.Key, update.Status, update.ScraperTags)
+
+	if len(m.updatesPath) > 0 {
+		redacted := *update
+		redacted.Secret = TraceRedacted
+		if err := appendJsonLine(m.updatesPath, &redacted); err != nil {
+			Log.Warnf("mock api: %v", err)
+		}
+	}
+
+	return ""
+}
+
+func (m *MockApi) handleUpdate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		m.writeJson(w, http.StatusMethodNotAllowed, map[string]string{"error": "POST only"})
+		return
+	}
+
+	update := new(CovidWaUpdatePayload)
+	if err := json.NewDecoder(r.Body).Decode(update); err != nil {
+		m.writeJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	if message := m.record(update); len(message) > 0 {
+		statusCode := http.StatusBadRequest
+		if message == MockApiInvalidSecret {
+			statusCode = http.StatusUnauthorized
+		}
+		m.writeJson(w, statusCode, map[string]string{"error": message})
+		return
+	}
+
+	m.writeJson(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+func (m *MockApi) handleBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		m.writeJson(w, http.StatusMethodNotAllowed, map[string]string{"error": "POST only"})
+		return
+	}
+
+	request := CovidWaBatchRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		m.writeJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	response := CovidWaBatchResponse{Results: make([]CovidWaBatchResult, 0, len(request.Updates))}
+	for _, update := range request.Updates {
+		message := m.record(update)
+		response.Results = append(response.Results, CovidWaBatchResult{Key: update.Key, Ok: len(message) == 0, Error: message})
+	}
+
+	m.writeJson(w, http.StatusOK, response)
+}
+
+func (m *MockApi) handleInternal(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		m.writeJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if len(m.secret) > 0 && r.PostForm.Get("secret") != m.secret {
+		m.writeJson(w, http.StatusUnauthorized, map[string]string{"error": MockApiInvalidSecret})
+		return
+	}
+
+	m.lock.Lock()
+	apiResp := ClinicsAPIResp{Timestamp: time.Now().Unix(), Clinics: m.clinics}
+	m.lock.Unlock()
+
+	m.writeJson(w, http.StatusOK, apiResp)
+}
+
+func (m *MockApi) handleUpdates(w http.ResponseWriter, r *http.Request) {
+	updates := m.Updates()
+	redacted := make([]CovidWaUpdatePayload, 0, len(updates))
+	for _, update := range updates {
+		copied := *update
+		copied.Secret = TraceRedacted
+		redacted = append(redacted, copied)
+	}
+
+	m.writeJson(w, http.StatusOK, redacted)
+}
+
+func appendJsonLine(path string, value interface{}) error {
+	jsonBytes, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Write(append(jsonBytes, '\n'))
+	return err
+}
+
+// mock-api [--addr <host:port>] [--clinics <file.json|file.csv>] [--updates <file.jsonl>] [--secret <secret>]
+func runMockApiCommand(args []string, stdout io.Writer) error {
+	addr := DefaultMockApiAddr
+	var clinicsPath, updatesPath, secret string
+
+	for idx := 2; idx < len(args); idx++ {
+		name, value := args[idx], ""
+		if eq := strings.Index(name, "="); eq >= 0 {
+			name, value = name[:eq], name[eq+1:]
+		} else if idx+1 < len(args) {
+			idx++
+			value = args[idx]
+		}
+
+		switch name {
+		case "--addr":
+			addr = value
+		case "--clinics":
+			clinicsPath = value
+		case "--updates":
+			updatesPath = value
+		case "--secret":
+			secret = value
+		default:
+			return fmt.Errorf("Unknown argument: %s\nUsage: %s mock-api [--addr <host:port>] [--clinics <file.json|file.csv>] [--updates <file.jsonl>] [--secret <secret>]", args[idx], filepath.Base(args[0]))
+		}
+	}
+
+	var clinics []Clinic
+	if len(clinicsPath) > 0 {
+		var err error
+		if clinics, err = LoadMockApiClinics(clinicsPath); err != nil {
+			return err
+		}
+	}
+
+	api := NewMockApi(clinics, secret)
+	api.updatesPath = updatesPath
+
+	if len(secret) == 0 {
+		secret = "any"
+	}
+	fmt.Fprintf(stdout, "Serving %d clinic(s) on http://%s, point the scrapers at it with:\n", len(api.clinics), addr)
+	fmt.Fprintf(stdout, "  api_url: \"http://%s%s\"\n  api_internal_url: \"http://%s%s\"\n  api_batch_url: \"http://%s%s\" # optional\n", addr, MockApiUpdaterPath, addr, MockApiInternalPath, addr, MockApiBatchPath)
+	fmt.Fprintf(stdout, "  api_secret: \"%s\"\n", secret)
+	fmt.Fprintf(stdout, "Received updates: http://%s%s\n", addr, MockApiUpdatesPath)
+
+	return http.ListenAndServe(addr, api.Handler())
+}
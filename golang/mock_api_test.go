@@ -0,0 +1,72 @@
+package csg
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestMockApi(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mockapi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	csvPath := filepath.Join(dir, "clinics.csv")
+	csvContents := "key,name,url\nwalgreens_1,\"Walgreens, Seattle\",https://www.walgreens.com/\nkroger_2,Fred Meyer,https://www.fredmeyer.com/\n"
+	if err := ioutil.WriteFile(csvPath, []byte(csvContents), 0644); err != nil {
+		t.Fatal(err)
+	}
+	jsonPath := filepath.Join(dir, "clinics.json")
+	if err := ioutil.WriteFile(jsonPath, []byte(`{"stamp": 1, "data": [{"key": "walgreens_1"}]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if clinics, err := LoadMockApiClinics(jsonPath); err != nil || len(clinics) != 1 || clinics[0].ApiKey != "walgreens_1" {
+		t.Errorf("Unexpected clinics from json (%v): %+v", err, clinics)
+	}
+	clinics, err := LoadMockApiClinics(csvPath)
+	if err != nil || len(clinics) != 2 || clinics[0].Name != "Walgreens, Seattle" {
+		t.Fatalf("Unexpected clinics from csv (%v): %+v", err, clinics)
+	}
+
+	api, server := StartMockApi(clinics, "mocksecret")
+	defer server.Close()
+
+	prevConfig := config
+	config = MockApiConfig(server.URL, "mocksecret")
+	config.ApiBatchUrl = server.URL + MockApiBatchPath
+	defer func() { config = prevConfig }()
+
+	matched, err := GetClinicsByKeyPattern(regexp.MustCompile(`walgreens_[0-9]+`))
+	if err != nil || len(matched) != 1 || matched[0].ApiKey != "walgreens_1" {
+		t.Errorf("Unexpected clinics from the mock api (%v): %+v", err, matched)
+	}
+
+	sink := new(CovidWaStatusSink)
+	update := &StatusUpdate{Scraper: "walgreens_1", Key: "walgreens_1", Status: StatusYes, Tags: []string{}, Time: time.Now()}
+	if err := sink.Send(update); err != nil {
+		t.Fatal(err)
+	}
+	if last := api.LastUpdate("walgreens_1"); last == nil || last.Status != StatusYes || last.Secret != "mocksecret" {
+		t.Errorf("Unexpected update: %+v", last)
+	}
+
+	config.ApiSecret = "wrong"
+	if err := sink.Send(update); err == nil {
+		t.Errorf("Expected an error for the wrong secret")
+	}
+	config.ApiSecret = "mocksecret"
+
+	batchSink := NewBatchStatusSink(sink, config.ApiBatchUrl)
+	batchSink.Send(&StatusUpdate{Scraper: "kroger_2", Key: "kroger_2", Status: StatusNo, Time: time.Now()})
+	batchSink.Send(&StatusUpdate{Scraper: "unknown", Key: "", Status: StatusNo, Time: time.Now()})
+	batchSink.Flush()
+	if last := api.LastUpdate("kroger_2"); last == nil || last.Status != StatusNo || len(api.Updates()) != 2 {
+		t.Errorf("Unexpected updates after a batch: %d", len(api.Updates()))
+	}
+}
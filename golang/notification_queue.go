@@ -0,0 +1,205 @@
+package csg
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+//buffers notifications over notify_window and sends them as one message per window.
+//change notifications from scrapers changing more than flap_threshold times an hour are replaced by a
+//single flapping notice, and a notice once the scraper settles.  changes are only remembered in memory, so
+//this needs the continuous runner: a once pass sees at most one change per scraper
+
+const FlapDetectionWindow = time.Hour
+const NotificationQueueIdleInterval = time.Minute //how often settled flapping scrapers are checked for without a window
+
+const NotificationKindFlapping = "flapping"
+const NotificationKindGroup = "group"
+
+var Notifications = NewNotificationQueue(0, 0)
+
+type NotificationQueue struct {
+	window        time.Duration
+	flapThreshold int
+	pending       []*Notification
+	changes       map[string][]time.Time   //recent change notification times per scraper
+	flapping      map[string]*Notification //flapping scrapers and their last change notification
+	lock          *sync.Mutex
+}
+
+// a zero window sends every notification right away, a zero flap threshold disables flap suppression
+func NewNotificationQueue(window time.Duration, flapThreshold int) *NotificationQueue {
+	queue := new(NotificationQueue)
+	queue.window = window
+	queue.flapThreshold = flapThreshold
+	queue.pending = make([]*Notification, 0)
+	queue.changes = make(map[string][]time.Time)
+	queue.flapping = make(map[string]*Notification)
+	queue.lock = new(sync.Mutex)
+	return queue
+}
+
+// queues the notification, or sends it when there's no window
+func (q *NotificationQueue) Add(notification *Notification) error {
+	q.lock.Lock()
+
+	toSend := q.checkFlapping(notification, notification.Time)
+	if q.window > 0 {
+		q.pending = append(q.pending, toSend...)
+		toSend = nil
+	}
+
+	q.lock.Unlock()
+
+	return sendNotifications(toSend)
+}
+
+// the notifications to send in place of the given one
+func (q *NotificationQueue) checkFlapping(notification *Notification, now time.Time) []*Notification {
+	if q.flapThreshold <= 0 || notification.Kind != NotificationKindChange {
+		return []*Notification{notification}
+	}
+
+	name := notification.Scraper
+	changes := append(q.recentChanges(name, now), now)
+	q.changes[name] = changes
+
+	if _, flapping := q.flapping[name]; flapping {
+		q.flapping[name] = notification
+		LogFor(name).Debugf("flapping, suppressed change notification (status: %s)", notification.Status)
+		return []*Notification{}
+	}
+
+	if len(changes) > q.flapThreshold {
+		q.flapping[name] = notification
+		LogFor(name).Warnf("flapping, %d changes in the last %v", len(changes), FlapDetectionWindow)
+
+		body := fmt.Sprintf("Scraper flapping: %s changed %d times in the last %v, suppressing change notifications until it settles\r\n%s", name, len(changes), FlapDetectionWindow, notificationIds(name))
+		flapNotification := NewNotification(NotificationKindFlapping, name, DefaultSubject, body)
+		flapNotification.copyScraperDetails(notification)
+		flapNotification.Status = notification.Status
+		return []*Notification{flapNotification}
+	}
+
+	return []*Notification{notification}
+}
+
+func (q *NotificationQueue) recentChanges(name string, now time.Time) []time.Time {
+	recent := make([]time.Time, 0)
+	for _, changeTime := range q.changes[name] {
+		if now.Sub(changeTime) < FlapDetectionWindow {
+			recent = append(recent, changeTime)
+		}
+	}
+	return recent
+}
+
+// notices for flapping scrapers that settled, i.e. changed no more than the threshold within the last hour
+func (q *NotificationQueue) settled(now time.Time) []*Notification {
+	result := make([]*Notification, 0)
+	for name, last := range q.flapping {
+		changes := q.recentChanges(name, now)
+		q.changes[name] = changes
+		if len(changes) > q.flapThreshold {
+			continue
+		}
+
+		delete(q.flapping, name)
+		LogFor(name).Infof("stopped flapping")
+
+		body := fmt.Sprintf("Scraper stopped flapping: %s, status: %v\r\n%s", name, last.Status, notificationIds(name))
+		notification := NewNotification(NotificationKindRecovered, name, DefaultSubject, body)
+		notification.copyScraperDetails(last)
+		notification.Status = last.Status
+		result = append(result, notification)
+	}
+	return result
+}
+
+func (q *NotificationQueue) IsFlapping(name string) bool {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	_, flapping := q.flapping[name]
+	return flapping
+}
+
+// sends everything queued, grouped into one notification per set of recipients
+func (q *NotificationQueue) Flush(now time.Time) error {
+	q.lock.Lock()
+	pending := append(q.pending, q.settled(now)...)
+	q.pending = make([]*Notification, 0)
+	q.lock.Unlock()
+
+	keys := make([]string, 0)
+	batches := make(map[string][]*Notification)
+	for _, notification := range pending {
+		key := notificationRouteKey(notification)
+		if _, exists := batches[key]; !exists {
+			keys = append(keys, key)
+		}
+		batches[key] = append(batches[key], notification)
+	}
+
+	toSend := make([]*Notification, 0, len(keys))
+	for _, key := range keys {
+		if len(batches[key]) > 1 {
+			toSend = append(toSend, groupNotifications(batches[key]))
+		} else {
+			toSend = append(toSend, batches[key][0])
+		}
+	}
+
+	return sendNotifications(toSend)
+}
+
+// flushes the queue every window until the process exits
+func (q *NotificationQueue) Start() {
+	interval := q.window
+	if interval <= 0 {
+		interval = NotificationQueueIdleInterval
+	}
+
+	go func() {
+		for {
+			time.Sleep(interval)
+			if err := q.Flush(time.Now()); err != nil {
+				Log.Errorf("%+v", err)
+			}
+		}
+	}()
+}
+
+func SetNotificationQueue(queue *NotificationQueue) {
+	Notifications = queue
+}
+
+func sendNotifications(notifications []*Notification) error {
+	failed := make([]string, 0)
+	for _, notification := range notifications {
+		if err := sendNotification(notification); err != nil {
+			failed = append(failed, err.Error())
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%s", strings.Join(failed, "\n"))
+	}
+	return nil
+}
+
+// one notification with the bodies of all of them, webhooks also get each of them under notifications.
+// the notifications should have the same route key, the group is routed like the first of them
+func groupNotifications(notifications []*Notification) *Notification {
+	bodies := make([]string, 0, len(notifications))
+	for _, notification := range notifications {
+		bodies = append(bodies, notification.Body)
+	}
+
+	subject := fmt.Sprintf("%s (%d)", DefaultSubject, len(notifications))
+	group := NewNotification(NotificationKindGroup, "", subject, strings.Join(bodies, "\r\n\r\n"))
+	group.Notifications = notifications
+	group.Owners = notifications[0].Owners
+	return group
+}
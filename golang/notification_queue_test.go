@@ -0,0 +1,105 @@
+package csg
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordingNotifier struct {
+	notifications []*Notification
+	lock          sync.Mutex
+}
+
+func (n *recordingNotifier) Name() string {
+	return "recording"
+}
+
+func (n *recordingNotifier) Notify(notification *Notification) error {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	n.notifications = append(n.notifications, notification)
+	return nil
+}
+
+func (n *recordingNotifier) kinds() []string {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	kinds := make([]string, 0, len(n.notifications))
+	for _, notification := range n.notifications {
+		kinds = append(kinds, notification.Kind)
+	}
+	return kinds
+}
+
+func changeNotification(name string, status Status, now time.Time) *Notification {
+	notification := NewNotification(NotificationKindChange, name, DefaultSubject, "Detected change: "+name)
+	notification.Status = status
+	notification.Time = now
+	return notification
+}
+
+func TestNotificationQueueGroups(t *testing.T) {
+	recorder := new(recordingNotifier)
+	prevNotifiers := Notifiers()
+	SetNotifiers([]Notifier{recorder})
+	defer SetNotifiers(prevNotifiers)
+
+	queue := NewNotificationQueue(time.Minute, 0)
+	now := time.Now()
+	for _, name := range []string{"kroger_1", "kroger_2", "kroger_3"} {
+		if err := queue.Add(changeNotification(name, StatusYes, now)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if kinds := recorder.kinds(); len(kinds) != 0 {
+		t.Fatalf("Expected nothing sent before the window ends, got %v", kinds)
+	}
+
+	if err := queue.Flush(now); err != nil {
+		t.Fatal(err)
+	}
+	if kinds := recorder.kinds(); len(kinds) != 1 || kinds[0] != NotificationKindGroup || len(recorder.notifications[0].Notifications) != 3 {
+		t.Errorf("Expected one group of 3, got %v", kinds)
+	}
+
+	if err := queue.Flush(now); err != nil || len(recorder.kinds()) != 1 {
+		t.Errorf("Expected nothing sent for an empty window, got %v (%v)", recorder.kinds(), err)
+	}
+}
+
+func TestNotificationQueueFlapping(t *testing.T) {
+	recorder := new(recordingNotifier)
+	prevNotifiers := Notifiers()
+	SetNotifiers([]Notifier{recorder})
+	defer SetNotifiers(prevNotifiers)
+
+	queue := NewNotificationQueue(0, 2)
+	now := time.Now()
+	statuses := []Status{StatusYes, StatusNo, StatusYes, StatusNo, StatusYes}
+	for idx, status := range statuses {
+		if err := queue.Add(changeNotification("signetic", status, now.Add(time.Duration(idx)*time.Minute))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected := []string{NotificationKindChange, NotificationKindChange, NotificationKindFlapping}
+	if kinds := recorder.kinds(); len(kinds) != len(expected) || kinds[2] != expected[2] || !queue.IsFlapping("signetic") {
+		t.Fatalf("Expected %v, got %v", expected, kinds)
+	}
+
+	//still flapping within the hour
+	if err := queue.Flush(now.Add(10 * time.Minute)); err != nil || len(recorder.kinds()) != 3 {
+		t.Fatalf("Expected no notice while flapping, got %v (%v)", recorder.kinds(), err)
+	}
+
+	if err := queue.Flush(now.Add(2 * FlapDetectionWindow)); err != nil {
+		t.Fatal(err)
+	}
+	kinds := recorder.kinds()
+	if len(kinds) != 4 || kinds[3] != NotificationKindRecovered || recorder.notifications[3].Status != StatusYes || queue.IsFlapping("signetic") {
+		t.Errorf("Expected a recovered notice with the last status, got %v", kinds)
+	}
+}
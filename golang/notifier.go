@@ -0,0 +1,360 @@
+package csg
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+//notification channels configured under notifiers:, each event is sent to every channel.
+//without notifiers: the smtp_* fields configure a single smtp channel, as before
+
+const NotifierTypeSmtp = "smtp"
+const NotifierTypeWebhook = "webhook"
+const NotifierTypeSlack = "slack"
+const NotifierTypeDiscord = "discord"
+
+const DefaultNotifierTimeout = 30   //seconds
+const DefaultNotifierRetries = 2    //attempts after the first
+const DefaultNotifierRetryDelay = 5 //seconds, doubled after every attempt
+const DiscordMaxContentLength = 2000
+
+const NotificationKindChange = "change"
+const NotificationKindError = "error"
+const NotificationKindStale = "stale"
+const NotificationKindRecovered = "recovered"
+const NotificationKindDigest = "digest"
+
+var notifiers = make([]Notifier, 0)
+var notifiersLock = new(sync.Mutex)
+
+type Notification struct {
+	Kind     string    `json:"kind"`
+	Scraper  string    `json:"scraper,omitempty"`
+	Status   Status    `json:"status,omitempty"`
+	Error    string    `json:"error,omitempty"`
+	Subject  string    `json:"subject"`
+	Body     string    `json:"body"`
+	Html     string    `json:"-"` //optional, sent by email as an alternative to body
+	RunId    string    `json:"run_id"`
+	ScrapeId string    `json:"scrape_id,omitempty"`
+	Time     time.Time `json:"time"`
+	//details of the scraper, see notificationFor
+	OldStatus Status   `json:"old_status,omitempty"`
+	Tags      []string `json:"tags,omitempty"`
+	ApiKey    string   `json:"api_key,omitempty"`
+	DumpUrl   string   `json:"dump_url,omitempty"`
+	ConfigUrl string   `json:"config_url,omitempty"`
+	//used for routing, see routing.go
+	ScraperType string   `json:"type,omitempty"`
+	ScraperTags []string `json:"scraper_tags,omitempty"`
+	Owners      []string `json:"owners,omitempty"`
+	ErrorClass  string   `json:"error_class,omitempty"`
+	Recipients  []string `json:"-"` //overrides the email recipients of an smtp notifier
+	//set on grouped notifications, see NotificationQueue
+	Notifications []*Notification `json:"notifications,omitempty"`
+}
+
+type Notifier interface {
+	Name() string
+	Notify(notification *Notification) error
+}
+
+type NotifierConfig struct {
+	Type       string            `yaml:"type"`
+	Name       string            `yaml:"name"`
+	Url        string            `yaml:"url"`     //webhook, slack and discord
+	Headers    map[string]string `yaml:"headers"` //webhook
+	Timeout    int64             `yaml:"timeout"`
+	Retries    *int              `yaml:"retries"`
+	RetryDelay int64             `yaml:"retry_delay"`
+	//smtp, each defaults to the top level smtp_* field
+	SmtpHost         string   `yaml:"smtp_host"`
+	SmtpPort         int      `yaml:"smtp_port"`
+	SmtpUsername     string   `yaml:"smtp_user"`
+	SmtpPassword     string   `yaml:"smtp_pass"`
+	SmtpTls          string   `yaml:"smtp_tls"`      //starttls (default), implicit or none
+	SmtpInsecure     bool     `yaml:"smtp_insecure"` //skip certificate checks and allow auth without tls, for local testing only
+	FromEmailAddress string   `yaml:"from_email_address"`
+	NotifyEmailAddrs []string `yaml:"notify_email_addrs"`
+	TextTemplate     string   `yaml:"text_template"` //template files, see email.go for the defaults
+	HtmlTemplate     string   `yaml:"html_template"`
+}
+
+func NewNotification(kind string, name string, subject string, body string) *Notification {
+	notification := &Notification{
+		Kind:    kind,
+		Scraper: name,
+		Subject: subject,
+		Body:    body,
+		RunId:   CurrentRunId,
+		Time:    time.Now(),
+	}
+	if len(name) > 0 {
+		notification.ScrapeId = ScrapeId(name)
+	}
+	return notification
+}
+
+// notification about a scraper, with the details used by email templates and webhooks
+func notificationFor(kind string, ctx *ScrapeAndSendContext, subject string, body string) *Notification {
+	notification := NewNotification(kind, ctx.Name, subject, body)
+
+	ctx.mutex.Lock()
+	notification.Tags = ctx.Tags
+	notification.DumpUrl = firstNonEmpty(ctx.LastDumpUrl, ctx.LastDumpPath)
+	ctx.mutex.Unlock()
+
+	if ctx.Scraper != nil {
+		notification.ScraperType = ctx.Scraper.Type()
+	}
+	if ctx.Config != nil {
+		notification.ApiKey = ctx.Config.ApiKey
+		notification.ScraperTags = ctx.Config.Tags
+		notification.Owners = ctx.Config.Owners
+	}
+	if len(config.ConfigUrl) > 0 {
+		notification.ConfigUrl = strings.ReplaceAll(config.ConfigUrl, "##NAME##", ctx.Name)
+	}
+
+	return notification
+}
+
+func (n *Notification) copyScraperDetails(from *Notification) {
+	n.Tags = from.Tags
+	n.ApiKey = from.ApiKey
+	n.DumpUrl = from.DumpUrl
+	n.ConfigUrl = from.ConfigUrl
+	n.ScraperType = from.ScraperType
+	n.ScraperTags = from.ScraperTags
+	n.Owners = from.Owners
+}
+
+// creates the channels configured with notifiers:, or an smtp channel from the smtp_* fields
+func NewNotifiers(config *Config) ([]Notifier, error) {
+	notifierConfigs := config.Notifiers
+	if len(notifierConfigs) == 0 {
+		if len(config.SmtpHost) == 0 {
+			return []Notifier{}, nil
+		}
+		notifierConfigs = []NotifierConfig{{Type: NotifierTypeSmtp}}
+	}
+
+	result := make([]Notifier, 0, len(notifierConfigs))
+	names := make(map[string]bool)
+	for idx, notifierConfig := range notifierConfigs {
+		if len(notifierConfig.Name) == 0 {
+			notifierConfig.Name = notifierConfig.Type
+			if names[notifierConfig.Name] {
+				notifierConfig.Name = fmt.Sprintf("%s-%d", notifierConfig.Type, idx)
+			}
+		}
+		if names[notifierConfig.Name] {
+			return nil, fmt.Errorf("Duplicate notifier name: %s", notifierConfig.Name)
+		}
+		names[notifierConfig.Name] = true
+
+		notifier, err := newNotifier(config, notifierConfig)
+		if err != nil {
+			return nil, fmt.Errorf("notifier %s: %v", notifierConfig.Name, err)
+		}
+
+		retries := DefaultNotifierRetries
+		if notifierConfig.Retries != nil {
+			retries = *notifierConfig.Retries
+		}
+		retryDelay := time.Duration(DefaultNotifierRetryDelay) * time.Second
+		if notifierConfig.RetryDelay > 0 {
+			retryDelay = time.Duration(notifierConfig.RetryDelay) * time.Second
+		}
+
+		result = append(result, &RetryNotifier{notifier: notifier, retries: retries, retryDelay: retryDelay})
+	}
+
+	return result, nil
+}
+
+func newNotifier(config *Config, notifierConfig NotifierConfig) (Notifier, error) {
+	timeout := time.Duration(DefaultNotifierTimeout) * time.Second
+	if notifierConfig.Timeout > 0 {
+		timeout = time.Duration(notifierConfig.Timeout) * time.Second
+	}
+
+	switch notifierConfig.Type {
+	case NotifierTypeSmtp:
+		return NewSmtpNotifier(config, notifierConfig, timeout)
+	case NotifierTypeWebhook, NotifierTypeSlack, NotifierTypeDiscord:
+		if len(notifierConfig.Url) == 0 {
+			return nil, fmt.Errorf("url is not configured")
+		}
+		return &WebhookNotifier{
+			name:    notifierConfig.Name,
+			format:  notifierConfig.Type,
+			url:     notifierConfig.Url,
+			headers: notifierConfig.Headers,
+			client:  &http.Client{Timeout: timeout},
+		}, nil
+	default:
+		return nil, fmt.Errorf("Unknown notifier type: %s", notifierConfig.Type)
+	}
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if len(value) > 0 {
+			return value
+		}
+	}
+	return ""
+}
+
+func SetNotifiers(newNotifiers []Notifier) {
+	notifiersLock.Lock()
+	defer notifiersLock.Unlock()
+
+	notifiers = newNotifiers
+}
+
+func Notifiers() []Notifier {
+	notifiersLock.Lock()
+	defer notifiersLock.Unlock()
+
+	return notifiers
+}
+
+// sends the notification to the channels it's routed to in parallel, returning an error if any of them failed
+func sendNotification(notification *Notification) error {
+	routed := routeNotification(notification, Notifiers())
+	if len(routed) == 0 {
+		return nil
+	}
+
+	Log.Infof("Subject: %s", notification.Subject)
+	Log.Infof("Body: %s", notification.Body)
+
+	errs := make([]error, len(routed))
+	wg := new(sync.WaitGroup)
+	for idx, target := range routed {
+		wg.Add(1)
+		go func(idx int, target routedNotification) {
+			defer wg.Done()
+
+			err := target.notifier.Notify(target.notification)
+			metricNotifications.Inc(target.notifier.Name(), metricResult(err == nil))
+			if err != nil {
+				errs[idx] = fmt.Errorf("%s: %v", target.notifier.Name(), err)
+			}
+		}(idx, target)
+	}
+	wg.Wait()
+
+	failed := make([]string, 0)
+	for _, err := range errs {
+		if err != nil {
+			failed = append(failed, err.Error())
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("Notification failed: %s", strings.Join(failed, "; "))
+	}
+
+	return nil
+}
+
+// retries a channel with an increasing delay, errors that won't go away by themselves aren't retried
+type RetryNotifier struct {
+	notifier   Notifier
+	retries    int
+	retryDelay time.Duration
+}
+
+func (n *RetryNotifier) Name() string {
+	return n.notifier.Name()
+}
+
+func (n *RetryNotifier) Notify(notification *Notification) error {
+	delay := n.retryDelay
+	for attempt := 0; ; attempt++ {
+		err := n.notifier.Notify(notification)
+		if err == nil || attempt >= n.retries || !isRetryableNotifyError(err) {
+			return err
+		}
+
+		Log.Warnf("notifier %s: %v, retrying in %v (%d/%d)", n.Name(), err, delay, attempt+1, n.retries)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+func isRetryableNotifyError(err error) bool {
+	var recipientErr *SmtpRecipientError
+	if errors.As(err, &recipientErr) {
+		return recipientErr.Temporary()
+	}
+	return IsRetryableError(err)
+}
+
+// posts the notification as json: as is for webhook, as a message for slack and discord incoming webhooks
+type WebhookNotifier struct {
+	name    string
+	format  string
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+func (n *WebhookNotifier) Name() string {
+	return n.name
+}
+
+func (n *WebhookNotifier) payload(notification *Notification) interface{} {
+	text := fmt.Sprintf("%s\n%s", notification.Subject, strings.ReplaceAll(notification.Body, "\r\n", "\n"))
+
+	switch n.format {
+	case NotifierTypeSlack:
+		return map[string]string{"text": text}
+	case NotifierTypeDiscord:
+		if len(text) > DiscordMaxContentLength {
+			text = text[:DiscordMaxContentLength-3] + "..."
+		}
+		return map[string]string{"content": text}
+	default:
+		return notification
+	}
+}
+
+func (n *WebhookNotifier) Notify(notification *Notification) error {
+	data, err := json.Marshal(n.payload(notification))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", n.url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range n.headers {
+		req.Header.Set(key, value)
+	}
+
+	//the rest of a chat webhook url is its secret
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return redactTransportError(err, HostPattern.FindString(n.url))
+	}
+	defer resp.Body.Close()
+
+	body, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return NewHttpStatusError(resp.StatusCode, HostPattern.FindString(n.url), body)
+	}
+
+	return nil
+}
@@ -0,0 +1,120 @@
+package csg
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNewNotifiers(t *testing.T) {
+	notifiers, err := NewNotifiers(&Config{SmtpHost: "smtp.example.com", SmtpPort: 587})
+	if err != nil || len(notifiers) != 1 || notifiers[0].Name() != NotifierTypeSmtp {
+		t.Errorf("Expected smtp_host fallback, got %v (%v)", notifiers, err)
+	}
+
+	notifiers, err = NewNotifiers(&Config{})
+	if err != nil || len(notifiers) != 0 {
+		t.Errorf("Expected no notifiers, got %v (%v)", notifiers, err)
+	}
+
+	notifiers, err = NewNotifiers(&Config{Notifiers: []NotifierConfig{{Type: NotifierTypeSlack, Url: "http://a"}, {Type: NotifierTypeSlack, Url: "http://b"}}})
+	if err != nil || len(notifiers) != 2 || notifiers[0].Name() == notifiers[1].Name() {
+		t.Errorf("Expected two distinctly named notifiers, got %v (%v)", notifiers, err)
+	}
+
+	for _, notifierConfig := range []NotifierConfig{{Type: "pager"}, {Type: NotifierTypeWebhook}, {Type: NotifierTypeSmtp}} {
+		if _, err := NewNotifiers(&Config{Notifiers: []NotifierConfig{notifierConfig}}); err == nil {
+			t.Errorf("Expected error for %+v", notifierConfig)
+		}
+	}
+}
+
+func TestSendNotification(t *testing.T) {
+	lock := new(sync.Mutex)
+	received := make(map[string]map[string]interface{})
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		} else if r.URL.Path == "/flaky" {
+			attempts++
+			if attempts == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+		}
+
+		body, _ := ioutil.ReadAll(r.Body)
+		payload := make(map[string]interface{})
+		if err := json.Unmarshal(body, &payload); err != nil {
+			t.Errorf("%s: %v", r.URL.Path, err)
+		}
+		payload["header"] = r.Header.Get("X-Token")
+		received[r.URL.Path] = payload
+	}))
+	defer server.Close()
+
+	retries := 1
+	notifiers, err := NewNotifiers(&Config{Notifiers: []NotifierConfig{
+		{Type: NotifierTypeWebhook, Url: server.URL + "/flaky", Headers: map[string]string{"X-Token": "t"}, Retries: &retries},
+		{Type: NotifierTypeSlack, Url: server.URL + "/slack"},
+		{Type: NotifierTypeDiscord, Url: server.URL + "/discord"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	notifiers[0].(*RetryNotifier).retryDelay = time.Millisecond
+
+	prevNotifiers := Notifiers()
+	SetNotifiers(notifiers)
+	defer SetNotifiers(prevNotifiers)
+
+	notification := NewNotification(NotificationKindChange, "notifier_test", DefaultSubject, "Detected change: notifier_test")
+	notification.Status = StatusYes
+	if err := sendNotification(notification); err != nil {
+		t.Fatal(err)
+	}
+
+	if attempts != 2 || received["/flaky"]["kind"] != NotificationKindChange || received["/flaky"]["status"] != string(StatusYes) || received["/flaky"]["header"] != "t" {
+		t.Errorf("Unexpected webhook payload after %d attempt(s): %v", attempts, received["/flaky"])
+	}
+	if text, _ := received["/slack"]["text"].(string); !strings.Contains(text, "Detected change: notifier_test") {
+		t.Errorf("Unexpected slack payload: %v", received["/slack"])
+	}
+	if content, _ := received["/discord"]["content"].(string); !strings.HasPrefix(content, DefaultSubject) {
+		t.Errorf("Unexpected discord payload: %v", received["/discord"])
+	}
+
+	//client errors aren't retried and fail only their own channel
+	SetNotifiers([]Notifier{notifiers[1], &RetryNotifier{notifier: &WebhookNotifier{name: "missing", url: server.URL + "/missing", client: http.DefaultClient}, retries: 3}})
+	if err := sendNotification(notification); err == nil || !strings.HasPrefix(err.Error(), "Notification failed: missing: ") || strings.Contains(err.Error(), "slack") {
+		t.Errorf("Expected failure of the missing channel, got %v", err)
+	}
+}
+
+func TestUnreachableWebhook(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	secretUrl := server.URL + "/services/T000/B000/webhooksecret"
+	server.Close()
+
+	notifier := &WebhookNotifier{name: "slack", format: NotifierTypeSlack, url: secretUrl, client: http.DefaultClient}
+	err := notifier.Notify(NewNotification(NotificationKindError, "notifier_test", DefaultSubject, "Error during scrape"))
+	if err == nil || strings.Contains(err.Error(), "webhooksecret") || !strings.Contains(err.Error(), server.URL) {
+		t.Errorf("Expected an error naming the host only, got %v", err)
+	}
+
+	sink := &WebhookStatusSink{name: "partner", url: secretUrl, client: http.DefaultClient}
+	err = sink.Send(&StatusUpdate{Scraper: "notifier_test", Key: "key", Status: StatusYes, Time: time.Now()})
+	if err == nil || strings.Contains(err.Error(), "webhooksecret") || !IsRetryableError(err) {
+		t.Errorf("Expected a retryable error naming the host only, got %v", err)
+	}
+}
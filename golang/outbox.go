@@ -0,0 +1,331 @@
+package csg
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+//durable outbox for status updates a sink didn't accept.  each queued update is a json file in outbox_dir,
+//written to a temp file and renamed, so queued updates survive a restart and are replayed on the next run.
+//a newer update for the same sink and key replaces the queued one, and delivery is retried in the
+//background with an increasing delay instead of blocking the scraper
+
+const OutboxFileExtension = ".json"
+const OutboxTempPrefix = ".tmp-"
+const OutboxInterval = 5        //seconds between delivery rounds in continuous mode
+const OutboxMinRetryDelay = 10  //seconds, doubled after every failed attempt
+const OutboxMaxRetryDelay = 900 //seconds
+
+var statusOutbox *Outbox
+
+type OutboxEntry struct {
+	Sink      string        `json:"sink"`
+	Update    *StatusUpdate `json:"update"`
+	Attempts  int           `json:"attempts"`
+	Collapsed int           `json:"collapsed"` //older updates for the key replaced by this one
+	Queued    time.Time     `json:"queued_time"`
+	next      time.Time     //not before this, zero after a restart
+	sending   bool
+}
+
+type Outbox struct {
+	dir     string
+	entries map[string]*OutboxEntry //by outboxKey
+	lock    *sync.Mutex
+}
+
+// creates the outbox dir, or loads the updates queued in it by a previous run
+func NewOutbox(dir string) (*Outbox, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("Can't create outbox dir %s: %v", dir, err)
+	}
+
+	o := new(Outbox)
+	o.dir = dir
+	o.entries = make(map[string]*OutboxEntry)
+	o.lock = new(sync.Mutex)
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		filePath := filepath.Join(dir, file.Name())
+		if strings.HasPrefix(file.Name(), OutboxTempPrefix) {
+			o.remove(filePath) //left by a write that didn't finish
+			continue
+		}
+		if file.IsDir() || filepath.Ext(file.Name()) != OutboxFileExtension {
+			continue
+		}
+
+		contents, err := ioutil.ReadFile(filePath)
+		if err != nil {
+			return nil, err
+		}
+		entry := new(OutboxEntry)
+		if err := json.Unmarshal(contents, entry); err != nil || entry.Update == nil {
+			Log.Warnf("Outbox: skipping unreadable %s: %v", filePath, err)
+			continue
+		}
+		o.entries[outboxKey(entry.Sink, entry.Update)] = entry
+	}
+
+	if len(o.entries) > 0 {
+		Log.Infof("Outbox: replaying %d queued update(s) from %s", len(o.entries), dir)
+	}
+
+	return o, nil
+}
+
+func SetStatusOutbox(outbox *Outbox) {
+	statusSinksLock.Lock()
+	defer statusSinksLock.Unlock()
+
+	statusOutbox = outbox
+}
+
+// nil unless outbox_dir is configured
+func StatusOutbox() *Outbox {
+	statusSinksLock.Lock()
+	defer statusSinksLock.Unlock()
+
+	return statusOutbox
+}
+
+// updates for the same key supersede each other, the key falls back to the scraper name for updates without one
+func outboxKey(sink string, update *StatusUpdate) string {
+	return sink + "/" + firstNonEmpty(update.Key, update.Scraper)
+}
+
+func outboxRetryDelay(attempts int) time.Duration {
+	delay := OutboxMinRetryDelay * math.Pow(2, float64(attempts-1))
+	if delay > OutboxMaxRetryDelay {
+		delay = OutboxMaxRetryDelay
+	}
+	return time.Duration(delay) * time.Second
+}
+
+func (o *Outbox) path(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return filepath.Join(o.dir, hex.EncodeToString(hash[:])+OutboxFileExtension)
+}
+
+func (o *Outbox) remove(path string) {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		Log.Warnf("Outbox: could not remove %s: %v", path, err)
+	}
+}
+
+func (o *Outbox) write(key string, entry *OutboxEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	tmpFile, err := ioutil.TempFile(o.dir, OutboxTempPrefix)
+	if err != nil {
+		return err
+	}
+
+	_, err = tmpFile.Write(data)
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpFile.Name(), o.path(key))
+	}
+	if err != nil {
+		o.remove(tmpFile.Name())
+	}
+	return err
+}
+
+// queues the update for the sink, replacing an older update queued for the same key
+func (o *Outbox) Add(sink string, update *StatusUpdate) error {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	key := outboxKey(sink, update)
+	queued := &OutboxEntry{Sink: sink, Update: update, Queued: time.Now()}
+	entry, exists := o.entries[key]
+	if exists {
+		if entry.Update.Time.After(update.Time) {
+			return nil
+		}
+		queued.Attempts = entry.Attempts
+		queued.Collapsed = entry.Collapsed + 1
+		queued.Queued = entry.Queued
+	}
+
+	if err := o.write(key, queued); err != nil {
+		return err
+	}
+
+	if exists {
+		LogFor(update.Scraper).Debugf("Outbox: %s update %s replaced by %s", sink, entry.Update.Status, update.Status)
+		entry.Update = update
+		entry.Collapsed = queued.Collapsed
+	} else {
+		o.entries[key] = queued
+	}
+	LogFor(update.Scraper).Infof("Outbox: queued %s update %s, %d update(s) queued", sink, update.Status, len(o.entries))
+
+	return nil
+}
+
+// true if an update for the key is waiting for the sink, newer updates have to queue behind it
+func (o *Outbox) Pending(sink string, update *StatusUpdate) bool {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	_, exists := o.entries[outboxKey(sink, update)]
+	return exists
+}
+
+// sends the update to the sinks, queueing it for the ones that fail or already have an update queued for the key.
+// returns the sinks it was queued for, and the ones it couldn't be queued for
+func (o *Outbox) Send(update *StatusUpdate, sinks []*FilteredStatusSink) (queued []*FilteredStatusSink, failed []*FilteredStatusSink) {
+	direct := make([]*FilteredStatusSink, 0, len(sinks))
+	toQueue := make([]*FilteredStatusSink, 0)
+	for _, sink := range sinks {
+		if o.Pending(sink.Name(), update) {
+			toQueue = append(toQueue, sink)
+		} else {
+			direct = append(direct, sink)
+		}
+	}
+	toQueue = append(toQueue, sendStatusUpdate(update, direct)...)
+
+	queued = make([]*FilteredStatusSink, 0, len(toQueue))
+	failed = make([]*FilteredStatusSink, 0)
+	for _, sink := range toQueue {
+		if err := o.Add(sink.Name(), update); err != nil {
+			LogFor(update.Scraper).Errorf("Outbox: can't queue %s update: %v", sink.Name(), err)
+			failed = append(failed, sink)
+		} else {
+			queued = append(queued, sink)
+		}
+	}
+	return queued, failed
+}
+
+// number of queued updates
+func (o *Outbox) Depth() int {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	return len(o.entries)
+}
+
+// number of queued updates by sink name
+func (o *Outbox) DepthBySink() map[string]int {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	result := make(map[string]int)
+	for _, entry := range o.entries {
+		result[entry.Sink]++
+	}
+	return result
+}
+
+// one delivery attempt for each queued update that's due
+func (o *Outbox) Deliver(now time.Time) {
+	o.deliver(now, false)
+}
+
+// one delivery attempt for every queued update, ignoring the retry delay.  used at the end of a once pass,
+// whatever is still queued afterwards is replayed on the next run
+func (o *Outbox) Flush(now time.Time) {
+	o.deliver(now, true)
+}
+
+func (o *Outbox) deliver(now time.Time, all bool) {
+	o.lock.Lock()
+	keys := make([]string, 0)
+	for key, entry := range o.entries {
+		if !entry.sending && (all || !now.Before(entry.next)) {
+			entry.sending = true
+			keys = append(keys, key)
+		}
+	}
+	o.lock.Unlock()
+
+	if len(keys) == 0 {
+		return
+	}
+	sort.Strings(keys)
+
+	sinks := make(map[string]*FilteredStatusSink)
+	for _, sink := range StatusSinks() {
+		sinks[sink.Name()] = sink
+	}
+
+	delivered := 0
+	for _, key := range keys {
+		o.lock.Lock()
+		entry := o.entries[key]
+		update := entry.Update
+		o.lock.Unlock()
+
+		sink, exists := sinks[entry.Sink]
+		if !exists {
+			LogFor(update.Scraper).Warnf("Outbox: status sink %s is no longer configured, dropping its %s update", entry.Sink, update.Status)
+			o.lock.Lock()
+			delete(o.entries, key)
+			o.remove(o.path(key))
+			o.lock.Unlock()
+			continue
+		}
+
+		err := unwrapStatusSink(sink.StatusSink).Send(update)
+		metricApiSends.Inc(sink.Name(), metricResult(err == nil))
+
+		o.lock.Lock()
+		entry.sending = false
+		if err == nil {
+			delivered++
+			if entry.Update == update {
+				delete(o.entries, key)
+				o.remove(o.path(key))
+			} else {
+				//replaced while it was being sent, the newer update goes out next round
+				entry.Attempts = 0
+				entry.next = time.Time{}
+			}
+			LogFor(update.Scraper).Infof("Outbox: delivered %s update %s, queued %v ago", sink.Name(), update.Status, now.Sub(entry.Queued).Round(time.Second))
+		} else {
+			entry.Attempts++
+			delay := outboxRetryDelay(entry.Attempts)
+			entry.next = now.Add(delay)
+			if err := o.write(key, entry); err != nil {
+				Log.Warnf("Outbox: can't update %s: %v", o.path(key), err)
+			}
+			LogFor(update.Scraper).Warnf("Outbox: %s: %v, attempt %d, retrying in %v", sink.Name(), err, entry.Attempts, delay)
+		}
+		o.lock.Unlock()
+	}
+
+	Log.Infof("Outbox: delivered %d of %d update(s), %d still queued", delivered, len(keys), o.Depth())
+}
+
+// delivers queued updates every OutboxInterval until the process exits
+func (o *Outbox) Start() {
+	go func() {
+		for {
+			time.Sleep(time.Duration(OutboxInterval) * time.Second)
+			o.Deliver(time.Now())
+		}
+	}()
+}
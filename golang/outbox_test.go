@@ -0,0 +1,114 @@
+package csg
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+type outboxTestSink struct {
+	fail bool
+	sent []*StatusUpdate
+	lock sync.Mutex
+}
+
+func (s *outboxTestSink) Name() string {
+	return "test"
+}
+
+func (s *outboxTestSink) Send(update *StatusUpdate) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.fail {
+		return fmt.Errorf("sink is down")
+	}
+	s.sent = append(s.sent, update)
+	return nil
+}
+
+func TestOutbox(t *testing.T) {
+	dir, err := ioutil.TempDir("", "outbox")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	sink := &outboxTestSink{fail: true}
+	prevSinks := StatusSinks()
+	SetStatusSinks([]*FilteredStatusSink{{StatusSink: sink}})
+	defer SetStatusSinks(prevSinks)
+
+	outbox, err := NewOutbox(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	first := &StatusUpdate{Scraper: "outbox_test", Key: "outbox_key", Status: StatusNo, Time: now}
+	queued, failed := outbox.Send(first, StatusSinks())
+	if len(queued) != 1 || len(failed) != 0 || outbox.Depth() != 1 {
+		t.Fatalf("Expected the update to be queued, queued: %d, failed: %d", len(queued), len(failed))
+	}
+
+	//the sink is back, but the newer update has to wait behind the queued one and replaces it
+	sink.fail = false
+	second := &StatusUpdate{Scraper: "outbox_test", Key: "outbox_key", Status: StatusYes, Time: now.Add(time.Second)}
+	if queued, _ := outbox.Send(second, StatusSinks()); len(queued) != 1 || len(sink.sent) != 0 || outbox.Depth() != 1 {
+		t.Fatalf("Expected the newer update to be queued, sent: %d, depth: %d", len(sink.sent), outbox.Depth())
+	}
+	if err := outbox.Add("test", first); err != nil || outbox.Depth() != 1 {
+		t.Fatalf("Expected an older update not to replace a newer one: %v", err)
+	}
+
+	//a restart replays the queue
+	replayed, err := NewOutbox(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := replayed.entries[outboxKey("test", second)]
+	if replayed.Depth() != 1 || entry.Update.Status != StatusYes || entry.Collapsed != 1 {
+		t.Fatalf("Unexpected replayed entry: %+v", entry)
+	}
+
+	replayed.Deliver(now)
+	if len(sink.sent) != 1 || sink.sent[0].Status != StatusYes || replayed.Depth() != 0 {
+		t.Fatalf("Expected the newest update to be delivered, sent: %d, depth: %d", len(sink.sent), replayed.Depth())
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, "*"+OutboxFileExtension)); len(files) != 0 {
+		t.Errorf("Expected delivered updates to be removed, found %v", files)
+	}
+
+	//failed deliveries back off
+	sink.fail = true
+	if err := replayed.Add("test", first); err != nil {
+		t.Fatal(err)
+	}
+	replayed.Deliver(now)
+	sink.fail = false
+	replayed.Deliver(now.Add(time.Second))
+	if len(sink.sent) != 1 || replayed.Depth() != 1 {
+		t.Fatalf("Expected delivery to wait for the retry delay, sent: %d", len(sink.sent))
+	}
+	replayed.Deliver(now.Add(outboxRetryDelay(1)))
+	if len(sink.sent) != 2 || replayed.Depth() != 0 {
+		t.Fatalf("Expected delivery after the retry delay, sent: %d", len(sink.sent))
+	}
+
+	//updates for sinks that are no longer configured are dropped
+	if err := replayed.Add("removed", first); err != nil {
+		t.Fatal(err)
+	}
+	replayed.Flush(now)
+	if replayed.Depth() != 0 || len(sink.sent) != 2 {
+		t.Errorf("Expected the update for the removed sink to be dropped, depth: %d", replayed.Depth())
+	}
+
+	if outboxRetryDelay(1) != OutboxMinRetryDelay*time.Second || outboxRetryDelay(20) != OutboxMaxRetryDelay*time.Second {
+		t.Errorf("Unexpected retry delays: %v, %v", outboxRetryDelay(1), outboxRetryDelay(20))
+	}
+}
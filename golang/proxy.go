@@ -203,6 +203,8 @@ func NewPublicHttpProxyProvider(updateInterval int, repeatInterval int) (ProxyPr
 }
 
 func (phpp *PublicHttpProxyProvider) GetProxy() (ProxyEndpoint, error) {
+	defer metricProxyAcquire.ObserveDuration(time.Now(), "public")
+
 	phpp.mutex.Lock()
 	defer phpp.mutex.Unlock()
 
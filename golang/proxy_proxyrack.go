@@ -107,6 +107,8 @@ func NewProxyRackAuthHttpProxyProvider(repeatInterval int, seedRand bool) (Proxy
 }
 
 func (prpp *ProxyRackAuthHttpProxyProvider) GetProxy() (ProxyEndpoint, error) {
+	defer metricProxyAcquire.ObserveDuration(time.Now(), "proxyrack")
+
 	prpp.mutex.Lock()
 	defer prpp.mutex.Unlock()
 
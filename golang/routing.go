@@ -0,0 +1,206 @@
+package csg
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+//routing of notifications to notifiers by scraper name, type, tags, kind, status transition and error class.
+//the first matching route decides where a notification goes, notifications matching no route go to every notifier
+
+const RouteTargetOwners = "owners" //the scraper's owners, emailed through the first smtp notifier. without owners the route's other notifiers, or every notifier, are used
+
+var notificationRoutes = make([]*NotificationRoute, 0)
+
+type NotificationRoute struct {
+	Name       string   `yaml:"name"` //scraper name pattern, * matches anything
+	Type       string   `yaml:"type"`
+	Tags       []string `yaml:"tags"`  //any of the scraper's tags
+	Kinds      []string `yaml:"kinds"` //change, error, stale, recovered, flapping, digest
+	FromStatus Status   `yaml:"from_status"`
+	ToStatus   Status   `yaml:"to_status"`
+	ErrorClass []string `yaml:"error_class"`
+	Notifiers  []string `yaml:"notifiers"` //notifier names, or owners
+	pattern    *regexp.Regexp
+}
+
+// checks the routes against the notifiers they send to
+func NewNotificationRoutes(routes []NotificationRoute, notifiers []Notifier) ([]*NotificationRoute, error) {
+	names := make(map[string]bool)
+	hasSmtp := false
+	for _, notifier := range notifiers {
+		names[notifier.Name()] = true
+		if _, ok := unwrapNotifier(notifier).(*SmtpNotifier); ok {
+			hasSmtp = true
+		}
+	}
+
+	result := make([]*NotificationRoute, 0, len(routes))
+	for idx := range routes {
+		route := routes[idx]
+		if len(route.Notifiers) == 0 {
+			return nil, fmt.Errorf("notification route %d: no notifiers", idx+1)
+		}
+		for _, target := range route.Notifiers {
+			if target == RouteTargetOwners && !hasSmtp {
+				return nil, fmt.Errorf("notification route %d: %s needs an smtp notifier", idx+1, RouteTargetOwners)
+			} else if target != RouteTargetOwners && !names[target] {
+				return nil, fmt.Errorf("notification route %d: unknown notifier: %s", idx+1, target)
+			}
+		}
+		if len(route.Name) > 0 {
+			pattern, err := compileScraperNamePattern(route.Name)
+			if err != nil {
+				return nil, fmt.Errorf("notification route %d: %v", idx+1, err)
+			}
+			route.pattern = pattern
+		}
+		result = append(result, &route)
+	}
+
+	return result, nil
+}
+
+func SetNotificationRoutes(routes []*NotificationRoute) {
+	notifiersLock.Lock()
+	defer notifiersLock.Unlock()
+
+	notificationRoutes = routes
+}
+
+func NotificationRoutes() []*NotificationRoute {
+	notifiersLock.Lock()
+	defer notifiersLock.Unlock()
+
+	return notificationRoutes
+}
+
+func matchesAny(value string, values []string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *NotificationRoute) Matches(notification *Notification) bool {
+	if r.pattern != nil && !r.pattern.MatchString(notification.Scraper) {
+		return false
+	}
+	if len(r.Type) > 0 && r.Type != notification.ScraperType {
+		return false
+	}
+	if len(r.Kinds) > 0 && !matchesAny(notification.Kind, r.Kinds) {
+		return false
+	}
+	if len(r.FromStatus) > 0 && r.FromStatus != notification.OldStatus {
+		return false
+	}
+	if len(r.ToStatus) > 0 && r.ToStatus != notification.Status {
+		return false
+	}
+	if len(r.ErrorClass) > 0 && !matchesAny(notification.ErrorClass, r.ErrorClass) {
+		return false
+	}
+	if len(r.Tags) > 0 {
+		for _, tag := range notification.ScraperTags {
+			if matchesAny(tag, r.Tags) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// names of the notifiers the notification goes to, see RouteTargetOwners
+func notificationTargets(notification *Notification, notifiers []Notifier) []string {
+	if notification.Kind == NotificationKindGroup && len(notification.Notifications) > 0 {
+		notification = notification.Notifications[0]
+	}
+
+	for _, route := range NotificationRoutes() {
+		if route.Matches(notification) {
+			return route.Notifiers
+		}
+	}
+
+	targets := make([]string, 0, len(notifiers))
+	for _, notifier := range notifiers {
+		targets = append(targets, notifier.Name())
+	}
+	return targets
+}
+
+// notifications with the same key go to the same recipients and can be grouped
+func notificationRouteKey(notification *Notification) string {
+	targets := append([]string{}, notificationTargets(notification, Notifiers())...)
+	sort.Strings(targets)
+
+	key := strings.Join(targets, ",")
+	if matchesAny(RouteTargetOwners, targets) {
+		key += "|" + strings.Join(notification.Owners, ",")
+	}
+	return key
+}
+
+type routedNotification struct {
+	notifier     Notifier
+	notification *Notification
+}
+
+// pairs the notification with each notifier it's routed to, owners get a copy addressed to them
+func routeNotification(notification *Notification, notifiers []Notifier) []routedNotification {
+	byName := make(map[string]Notifier)
+	var ownersNotifier Notifier
+	for _, notifier := range notifiers {
+		byName[notifier.Name()] = notifier
+		if _, ok := unwrapNotifier(notifier).(*SmtpNotifier); ok && ownersNotifier == nil {
+			ownersNotifier = notifier
+		}
+	}
+
+	targets := notificationTargets(notification, notifiers)
+	if matchesAny(RouteTargetOwners, targets) && (len(notification.Owners) == 0 || ownersNotifier == nil) {
+		//don't drop it, the route's other notifiers or else every notifier get it instead
+		others := make([]string, 0, len(targets))
+		for _, target := range targets {
+			if target != RouteTargetOwners {
+				others = append(others, target)
+			}
+		}
+		if len(others) == 0 {
+			for _, notifier := range notifiers {
+				others = append(others, notifier.Name())
+			}
+		}
+		Log.Warnf("No owners to notify for %s, sending the %s notification to %s", notification.Scraper, notification.Kind, strings.Join(others, ", "))
+		targets = others
+	}
+
+	routed := make([]routedNotification, 0)
+	for _, target := range targets {
+		if target != RouteTargetOwners {
+			if notifier, exists := byName[target]; exists {
+				routed = append(routed, routedNotification{notifier, notification})
+			}
+			continue
+		}
+
+		ownersNotification := *notification
+		ownersNotification.Recipients = notification.Owners
+		routed = append(routed, routedNotification{ownersNotifier, &ownersNotification})
+	}
+
+	return routed
+}
+
+func unwrapNotifier(notifier Notifier) Notifier {
+	if retryNotifier, ok := notifier.(*RetryNotifier); ok {
+		return retryNotifier.notifier
+	}
+	return notifier
+}
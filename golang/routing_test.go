@@ -0,0 +1,103 @@
+package csg
+
+import (
+	"net"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNotificationRouting(t *testing.T) {
+	listener, messages := startTestSmtpServer(t)
+	defer listener.Close()
+
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	smtpConfig := NotifierConfig{Name: "email", SmtpHost: host, SmtpTls: SmtpTlsNone, FromEmailAddress: "scrapers@example.com", NotifyEmailAddrs: []string{"ops@example.com"}}
+	smtpConfig.SmtpPort, _ = net.LookupPort("tcp", port)
+	smtpNotifier, err := NewSmtpNotifier(&Config{}, smtpConfig, time.Duration(DefaultNotifierTimeout)*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder := new(recordingNotifier)
+	notifiers := []Notifier{smtpNotifier, recorder}
+
+	if _, err := NewNotificationRoutes([]NotificationRoute{{Notifiers: []string{"pager"}}}, notifiers); err == nil {
+		t.Errorf("Expected error for unknown notifier")
+	}
+	if _, err := NewNotificationRoutes([]NotificationRoute{{Notifiers: []string{RouteTargetOwners}}}, []Notifier{recorder}); err == nil {
+		t.Errorf("Expected error for owners without an smtp notifier")
+	}
+	if _, err := NewNotificationRoutes([]NotificationRoute{{Name: "kroger_(", Notifiers: []string{recorder.Name()}}}, notifiers); err == nil {
+		t.Errorf("Expected error for a malformed name pattern")
+	}
+
+	routes, err := NewNotificationRoutes([]NotificationRoute{
+		{Name: "kroger_*", Kinds: []string{NotificationKindChange}, Notifiers: []string{RouteTargetOwners}},
+		{Kinds: []string{NotificationKindError}, ErrorClass: []string{ErrorClassHttpStatus}, Notifiers: []string{RouteTargetOwners, recorder.Name()}},
+	}, notifiers)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prevNotifiers, prevRoutes := Notifiers(), NotificationRoutes()
+	SetNotifiers(notifiers)
+	SetNotificationRoutes(routes)
+	defer func() {
+		SetNotifiers(prevNotifiers)
+		SetNotificationRoutes(prevRoutes)
+	}()
+
+	recipients := func() string {
+		msg, err := mail.ReadMessage(strings.NewReader(<-messages))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return msg.Header.Get("To")
+	}
+
+	change := NewNotification(NotificationKindChange, "kroger_1", DefaultSubject, "Detected change: kroger_1")
+	change.Owners = []string{"alice@example.com"}
+	if err := sendNotification(change); err != nil {
+		t.Fatal(err)
+	}
+	if to := recipients(); to != "<alice@example.com>" || len(recorder.kinds()) != 0 {
+		t.Errorf("Expected the change to go to the owner only, got %s and %v", to, recorder.kinds())
+	}
+
+	escalation := NewNotification(NotificationKindError, "kroger_1", DefaultSubject, "Error during scrape: kroger_1")
+	escalation.Owners = change.Owners
+	escalation.ErrorClass = ErrorClassHttpStatus
+	if err := sendNotification(escalation); err != nil {
+		t.Fatal(err)
+	}
+	if to := recipients(); to != "<alice@example.com>" || len(recorder.kinds()) != 1 {
+		t.Errorf("Expected the error to go to the owner and the global notifier, got %s and %v", to, recorder.kinds())
+	}
+
+	//no route, everyone
+	if err := sendNotification(NewNotification(NotificationKindDigest, "", DigestSubject, "digest")); err != nil {
+		t.Fatal(err)
+	}
+	if to := recipients(); to != "<ops@example.com>" || len(recorder.kinds()) != 2 {
+		t.Errorf("Expected the digest to go to every notifier, got %s and %v", to, recorder.kinds())
+	}
+
+	//no owners, the route's other notifiers
+	unowned := NewNotification(NotificationKindError, "kroger_2", DefaultSubject, "Error during scrape: kroger_2")
+	unowned.ErrorClass = ErrorClassHttpStatus
+	if err := sendNotification(unowned); err != nil {
+		t.Fatal(err)
+	}
+	if kinds := recorder.kinds(); len(kinds) != 3 || kinds[2] != NotificationKindError || len(messages) != 0 {
+		t.Errorf("Expected the unowned error to go to the global notifier only, got %v", kinds)
+	}
+
+	//no owners and no other notifiers, everyone
+	if err := sendNotification(NewNotification(NotificationKindChange, "kroger_2", DefaultSubject, "Detected change: kroger_2")); err != nil {
+		t.Fatal(err)
+	}
+	if to := recipients(); to != "<ops@example.com>" || len(recorder.kinds()) != 4 {
+		t.Errorf("Expected the unowned change to go to every notifier, got %s and %v", to, recorder.kinds())
+	}
+}
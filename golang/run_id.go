@@ -0,0 +1,72 @@
+package csg
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+//ids tying log lines, traces, dumps, api updates and notifications back to the run and the scrape that produced them.
+//a run is one invocation of Run: a once pass, a test, or the continuous runner
+
+const DumpMetadataSuffix = ".meta.json"
+
+var CurrentRunId = NewRunId()
+
+var scrapeIds = make(map[string]string)
+var scrapeIdsLock = new(sync.Mutex)
+
+func randomHex(n int) string {
+	idBytes := make([]byte, n)
+	if _, err := rand.Read(idBytes); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(idBytes)
+}
+
+// e.g. 20210401T101500-3fa2c1
+func NewRunId() string {
+	return time.Now().UTC().Format("20060102T150405") + "-" + randomHex(3)
+}
+
+func NewScrapeId() string {
+	return randomHex(8)
+}
+
+// assigns a new scrape id to the scraper and adds it to the scraper's log lines
+func startScrapeId(name string) string {
+	scrapeId := NewScrapeId()
+
+	scrapeIdsLock.Lock()
+	scrapeIds[name] = scrapeId
+	scrapeIdsLock.Unlock()
+
+	setScraperLogIds(name, CurrentRunId, scrapeId)
+
+	return scrapeId
+}
+
+// id of the current or last scrape of the scraper, if any
+func ScrapeId(name string) string {
+	scrapeIdsLock.Lock()
+	defer scrapeIdsLock.Unlock()
+
+	return scrapeIds[name]
+}
+
+// lines added to notifications so the evidence for them can be found
+func notificationIds(name string) string {
+	ids := fmt.Sprintf("Run: %s", CurrentRunId)
+	if scrapeId := ScrapeId(name); len(scrapeId) > 0 {
+		ids = fmt.Sprintf("%s\r\nScrape: %s", ids, scrapeId)
+	}
+	return ids
+}
+
+// metadata file written next to a dumped body
+func dumpMetadataPath(dumpPath string) string {
+	return strings.TrimSuffix(dumpPath, ".out") + DumpMetadataSuffix
+}
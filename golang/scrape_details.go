@@ -0,0 +1,87 @@
+package csg
+
+import (
+	"sync"
+	"time"
+)
+
+//details a scraper can report while scraping, beyond its status.  they are sent with status updates,
+//to the covidwa api from api_version 2 on
+
+type ScrapeDetails struct {
+	Appointments *int
+	EarliestSlot *time.Time
+	DataTime     *time.Time //when the source last updated its data
+	BookingUrl   string
+	Coordinates  *GeoCoord
+}
+
+var scrapeDetails = make(map[string]*ScrapeDetails)
+var scrapeDetailsLock = new(sync.Mutex)
+
+// clears what the last scrape of the scraper reported
+func startScrapeDetails(name string) {
+	scrapeDetailsLock.Lock()
+	defer scrapeDetailsLock.Unlock()
+
+	delete(scrapeDetails, name)
+}
+
+// what the scraper reported since startScrapeDetails, nil if nothing
+func finishScrapeDetails(name string) *ScrapeDetails {
+	scrapeDetailsLock.Lock()
+	defer scrapeDetailsLock.Unlock()
+
+	details := scrapeDetails[name]
+	delete(scrapeDetails, name)
+	return details
+}
+
+func updateScrapeDetails(name string, update func(details *ScrapeDetails)) {
+	scrapeDetailsLock.Lock()
+	defer scrapeDetailsLock.Unlock()
+
+	details, exists := scrapeDetails[name]
+	if !exists {
+		details = new(ScrapeDetails)
+		scrapeDetails[name] = details
+	}
+	update(details)
+}
+
+// number of available appointments found by the current scrape
+func ReportAppointments(name string, count int) {
+	updateScrapeDetails(name, func(details *ScrapeDetails) {
+		details.Appointments = &count
+	})
+}
+
+// an available slot, the earliest one reported is kept
+func ReportSlot(name string, slot time.Time) {
+	updateScrapeDetails(name, func(details *ScrapeDetails) {
+		if details.EarliestSlot == nil || slot.Before(*details.EarliestSlot) {
+			details.EarliestSlot = &slot
+		}
+	})
+}
+
+// when the source last updated the data the current scrape is based on
+func ReportDataTime(name string, dataTime time.Time) {
+	updateScrapeDetails(name, func(details *ScrapeDetails) {
+		details.DataTime = &dataTime
+	})
+}
+
+// where the appointments found by the current scrape can be booked
+func ReportBookingUrl(name string, url string) {
+	updateScrapeDetails(name, func(details *ScrapeDetails) {
+		details.BookingUrl = url
+	})
+}
+
+// where the location is, for the public feed
+func ReportCoordinates(name string, coordinates GeoCoord) {
+	updateScrapeDetails(name, func(details *ScrapeDetails) {
+		details.Coordinates = &coordinates
+	})
+}
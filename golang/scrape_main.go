@@ -3,25 +3,36 @@ package csg
 import (
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
-	"net/http"
-	"net/smtp"
 	"os"
 	"path/filepath"
-	"regexp"
 	"strings"
+	"sync"
 	"time"
 )
 
 const DefaultSubject = "COVID WA - Notification"
 const SinglePassRetries = 3
+const StatsLogInterval = 3600 //seconds between stats summaries in continuous mode
 
 var config *Config
 
 func Run(args []string) {
 	var err error
 
+	if len(args) > 1 && args[1] == "mock-api" {
+		//no config needed, so it runs without an api secret
+		if err := runMockApiCommand(args, os.Stdout); err != nil {
+			Log.Errorf("%v", err)
+			os.Exit(2)
+		}
+		os.Exit(0)
+	}
+
+	CurrentRunId = NewRunId()
 	config, err = NewConfigDefaultPath()
 	if err != nil {
 		Log.Errorf("Can't read config: %v", err)
@@ -41,10 +52,62 @@ func Run(args []string) {
 		}
 	}
 
+	if len(config.HistoryDir) > 0 {
+		if err := os.MkdirAll(config.HistoryDir, 0755); err != nil {
+			Log.Errorf("Can't create history dir: %s", config.HistoryDir)
+			panic(err)
+		}
+		History = NewHistoryStore(config.HistoryDir)
+	}
+
+	if len(args) > 1 && args[1] == "history" {
+		if err := runHistoryCommand(args); err != nil {
+			Log.Errorf("%v", err)
+			os.Exit(2)
+		}
+		os.Exit(0)
+	}
+
 	if config.PollInterval < 10 || config.PollInterval > 86400 {
 		panic(fmt.Errorf("Poll interval must be between 10 and 86400 seconds, configured: %d", config.PollInterval))
 	}
 
+	notifiers, err := NewNotifiers(config)
+	if err != nil {
+		Log.Errorf("Can't create notifiers: %v", err)
+		panic(err)
+	}
+	SetNotifiers(notifiers)
+	routes, err := NewNotificationRoutes(config.NotificationRoutes, notifiers)
+	if err != nil {
+		Log.Errorf("Can't create notification routes: %v", err)
+		panic(err)
+	}
+	SetNotificationRoutes(routes)
+
+	sinks, err := NewStatusSinks(config)
+	if err != nil {
+		Log.Errorf("Can't create status sinks: %v", err)
+		panic(err)
+	}
+	SetStatusSinks(sinks)
+	if len(config.OutboxDir) > 0 {
+		outbox, err := NewOutbox(config.OutboxDir)
+		if err != nil {
+			Log.Errorf("Can't create outbox: %v", err)
+			panic(err)
+		}
+		SetStatusOutbox(outbox)
+	}
+	SetNotificationQueue(NewNotificationQueue(time.Duration(config.NotifyWindow)*time.Second, config.FlapThreshold))
+
+	cacheBackend, err := NewCacheBackend(config)
+	if err != nil {
+		Log.Errorf("Can't create cache: %v", err)
+		panic(err)
+	}
+	Cache.SetBackend(cacheBackend)
+
 	scraperFactories := GetScraperFactories()
 
 	scrapeContexts := make([]*ScrapeAndSendContext, 0)
@@ -62,6 +125,10 @@ func Run(args []string) {
 		}
 
 		for _, scraper := range scrapers {
+			if _, err = SetScraperLogger(scraper.Name(), scraper.Type(), scraperConfig.LogLevel); err != nil {
+				panic(fmt.Sprintf("%s: %v", scraper.Name(), err))
+			}
+
 			if err = scraper.Configure(scraperConfig.Params); err != nil {
 				panic(fmt.Sprintf("%s: %v", scraper.Name(), err))
 			}
@@ -71,6 +138,7 @@ func Run(args []string) {
 			config.ApiKey = strings.ReplaceAll(config.ApiKey, "##NAME##", scraper.Name())
 
 			Log.Infof("Registering scraper: %s - type: %s, key: %s", scraper.Name(), config.Type, config.ApiKey)
+			SetTemplateVars(scraper.Name(), config.Vars)
 
 			newContext := NewScrapeAndSendContext(scraper, &config)
 			scrapeContexts = append(scrapeContexts, newContext)
@@ -79,6 +147,7 @@ func Run(args []string) {
 	}
 
 	changeTracker := NewChangeTracker(scraperNames)
+	allScrapeContexts := scrapeContexts //once retries narrow scrapeContexts down to the failed scrapers
 
 	if len(args) > 1 {
 		switch args[1] {
@@ -86,9 +155,9 @@ func Run(args []string) {
 			for retryCount := 0; len(scrapeContexts) > 0 && retryCount <= SinglePassRetries; retryCount++ {
 				scraperCount := len(scrapeContexts)
 				if retryCount == 0 {
-					Log.Infof("Running %d scraper(s) once...", scraperCount)
+					Log.Infof("Running %d scraper(s) once (run %s)...", scraperCount, CurrentRunId)
 				} else {
-					Cache.Destroy() //clear out any cached data
+					Cache.Invalidate() //clear out any cached data, including what this process shared on disk
 
 					// don't retry too fast
 					time.Sleep(2 * time.Second)
@@ -109,7 +178,11 @@ func Run(args []string) {
 
 					// build new list of failed scrapers
 					if ctx.Status == StatusUnknown {
-						newScrapeContexts = append(newScrapeContexts, ctx)
+						if IsRetryableError(ctx.Err) {
+							newScrapeContexts = append(newScrapeContexts, ctx)
+						} else {
+							Log.Infof("Scraper '%s' failed with a non-retryable error (%s), not retrying", ctx.Name, ErrorClass(ctx.Err))
+						}
 					}
 
 					scrapersLeft := scraperCount - doneCount - 1
@@ -129,15 +202,34 @@ func Run(args []string) {
 				scrapeContexts = newScrapeContexts
 			}
 
+			logCacheStats()
+			Cache.Sweep()   //remove expired entries, once runs have no background sweeper
 			Cache.Destroy() //clear out any crud left in the cache
+			logConditionalGetStats()
+			if err := flushScrapeStats(); err != nil {
+				Log.Warnf("Can't write scrape stats: %v", err)
+			}
+			if err := Notifications.Flush(time.Now()); err != nil {
+				Log.Errorf("%+v", err)
+			}
+			flushStatusBatches()
+			if outbox := StatusOutbox(); outbox != nil {
+				outbox.Flush(time.Now())
+			}
+			if len(config.FeedDir) > 0 || len(config.FeedS3Bucket) > 0 {
+				if err := publishFeed(BuildFeed(changeTracker, allScrapeContexts, loadPreviousFeed(), time.Now())); err != nil {
+					Log.Errorf("Can't publish feed: %v", err)
+				}
+			}
+		case "report":
+			if err := runReportCommand(args, scrapeContexts); err != nil {
+				Log.Errorf("%v", err)
+				os.Exit(2)
+			}
+			os.Exit(0)
 		case "test":
 			if len(args) > 2 {
-				patternStr := fmt.Sprintf("^%s$", args[2])
-				if strings.Contains(patternStr, "*") {
-					patternStr = strings.ReplaceAll(patternStr, "*", ".*")
-				}
-
-				pattern := regexp.MustCompile(patternStr)
+				pattern := scraperNamePattern(args[2])
 				Log.Debugf("Testing all scrapers with names matching %v", pattern)
 				scraperCount := 0
 				errorCount := 0
@@ -152,12 +244,21 @@ func Run(args []string) {
 
 				for doneCount := 0; doneCount < scraperCount; doneCount++ {
 					ctx := <-resultChan
-					Log.Infof("Scraper %s returned a status of %s", ctx.Name, ctx.Status)
+					if ctx.Err != nil {
+						Log.Infof("Scraper %s returned a status of %s (%s error: %v)", ctx.Name, ctx.Status, ErrorClass(ctx.Err), ctx.Err)
+					} else {
+						Log.Infof("Scraper %s returned a status of %s", ctx.Name, ctx.Status)
+					}
+					logTrace(ctx.LastTrace)
 
 					if ctx.Status == StatusUnknown || ctx.Status == StatusApifail {
 						errorCount++
 					}
 				}
+				flushStatusBatches()
+				if err := Notifications.Flush(time.Now()); err != nil {
+					Log.Errorf("%+v", err)
+				}
 
 				if scraperCount == 0 {
 					Log.Warnf("Scraper not found: %s", args[2])
@@ -173,13 +274,60 @@ func Run(args []string) {
 			printUsageAndExit(args)
 		}
 	} else {
-		Log.Infof("Running %d scrapers continuously...", len(scrapeContexts))
+		Log.Infof("Running %d scrapers continuously (run %s)...", len(scrapeContexts), CurrentRunId)
+		Cache.StartSweeper(DefaultCacheSweepInterval)
 
+		if len(config.MetricsAddr) > 0 {
+			handleHttp(config.MetricsAddr, MetricsPath, MetricsHandler())
+		}
+		watchdog := NewWatchdog(changeTracker, scrapeContexts, config.StaleFactor)
+		if len(config.StatusAddr) > 0 {
+			statusApi := NewStatusApi(changeTracker, scrapeContexts)
+			statusApi.SetWatchdog(watchdog)
+			statusApi.Register(config.StatusAddr)
+		}
+		if len(config.FeedAddr) > 0 {
+			registerFeedHandlers(config.FeedAddr, changeTracker, scrapeContexts)
+		}
+		startHttpServers()
+		Notifications.Start()
+		if outbox := StatusOutbox(); outbox != nil {
+			outbox.Start()
+		}
+		if len(config.ApiBatchUrl) > 0 {
+			startStatusBatches(time.Duration(config.ApiBatchWindow) * time.Second)
+		}
+
+		var nextDigest time.Time
+		if len(config.DigestTime) > 0 {
+			if nextDigest, err = nextDigestTime(config.DigestTime, time.Now()); err != nil {
+				panic(err)
+			}
+			Log.Infof("Next digest: %s", nextDigest.Format(HistoryTimeFormat))
+		}
+
+		lastStatsLog := time.Now()
 		for {
+			markSchedulerLoop()
+			watchdog.Check(time.Now().Unix())
 			for _, ctx := range scrapeContexts {
 				go doScrapeAndSend(changeTracker, ctx, false, nil)
 			}
 			time.Sleep(time.Duration(1) * time.Second)
+
+			if time.Since(lastStatsLog) >= StatsLogInterval*time.Second {
+				logConditionalGetStats()
+				logCacheStats()
+				if err := flushScrapeStats(); err != nil {
+					Log.Warnf("Can't write scrape stats: %v", err)
+				}
+				lastStatsLog = time.Now()
+			}
+
+			if !nextDigest.IsZero() && time.Now().After(nextDigest) {
+				go sendDigest(scrapeContexts)
+				nextDigest, _ = nextDigestTime(config.DigestTime, time.Now())
+			}
 		}
 	}
 }
@@ -241,11 +389,18 @@ func GetScraperFactories() map[string]ScraperFactory {
 }
 
 type ScrapeAndSendContext struct {
-	Name    string
-	Scraper Scraper
-	Config  *ScraperConfig
-	Status  Status
-	Tags    []string
+	Name         string
+	Scraper      Scraper
+	Config       *ScraperConfig
+	Status       Status
+	Tags         []string
+	Err          error  //error from the last scrape, if any
+	LastDumpPath string //file the last unrecognized output was written to, if any
+	LastDumpUrl  string //s3 url of the last unrecognized output, if any
+	LastTrace    *ScrapeTrace
+	Details      *ScrapeDetails //reported by the last scrape, if anything
+	ScrapeId     string //id of the last scrape, see run_id.go
+	mutex        *sync.Mutex
 }
 
 func NewScrapeAndSendContext(scraper Scraper, scraperConfig *ScraperConfig) *ScrapeAndSendContext {
@@ -253,16 +408,33 @@ func NewScrapeAndSendContext(scraper Scraper, scraperConfig *ScraperConfig) *Scr
 	ctx.Name = scraper.Name()
 	ctx.Scraper = scraper
 	ctx.Config = scraperConfig
+	ctx.mutex = new(sync.Mutex)
 
 	return ctx
 }
 
-//forceScrape: ignore any interval checks and just scrape immediately
-func doScrapeAndSend(tracker *ChangeTracker, ctx *ScrapeAndSendContext, forceScrape bool, resultChan chan *ScrapeAndSendContext) {
-	minInterval := config.PollInterval
+func (ctx *ScrapeAndSendContext) MinInterval() int64 {
 	if ctx.Config.MinInterval > 0 {
-		minInterval = ctx.Config.MinInterval
+		return ctx.Config.MinInterval
 	}
+	return config.PollInterval
+}
+
+// returns a copy that is safe to read while the scraper is running
+func (ctx *ScrapeAndSendContext) Snapshot() ScrapeAndSendContext {
+	ctx.mutex.Lock()
+	defer ctx.mutex.Unlock()
+
+	snapshot := *ctx
+	snapshot.Tags = append([]string(nil), ctx.Tags...)
+	snapshot.mutex = nil
+
+	return snapshot
+}
+
+//forceScrape: ignore any interval checks and just scrape immediately
+func doScrapeAndSend(tracker *ChangeTracker, ctx *ScrapeAndSendContext, forceScrape bool, resultChan chan *ScrapeAndSendContext) {
+	minInterval := ctx.MinInterval()
 
 	lastScrapeTime := tracker.LastScrape(ctx.Name)
 	currentTime := time.Now().Unix()
@@ -281,16 +453,35 @@ func doScrapeAndSend(tracker *ChangeTracker, ctx *ScrapeAndSendContext, forceScr
 		return
 	}
 
+	scrapeStart := time.Now()
+	scrapeId := startScrapeId(ctx.Name)
+	startTrace(ctx.Name, scrapeId)
+	startScrapeDetails(ctx.Name)
 	status, tags, body, err := ctx.Scraper.Scrape()
+	details := finishScrapeDetails(ctx.Name)
+	scrapeDuration := time.Since(scrapeStart)
+	err = normalizeScrapeError(err)
+	trace := finishTrace(ctx.Name, status, err)
+	recordScrape(ctx.Name, ctx.Scraper.Type(), scrapeStart, err)
+	ctx.mutex.Lock()
 	ctx.Status = status
 	ctx.Tags = tags.ToStringArray()
+	ctx.Err = err
+	ctx.LastTrace = trace
+	ctx.ScrapeId = scrapeId
+	ctx.Details = details
+	ctx.mutex.Unlock()
+
+	if err := History.Record(ctx.Name, status, err); err != nil {
+		Log.Warnf("Can't record history: %v", err)
+	}
 
 	if err != nil {
-		Log.Errorf("%s: %v", ctx.Name, err)
+		LogFor(ctx.Name).Errorf("%s error: %v", ErrorClass(err), err)
 		errorCount := tracker.Error(ctx.Name, err)
 
 		if errorCount == config.ErrorWarningThreshold && config.NotifyOnError {
-			if err := notifyError(ctx.Name, err); err != nil {
+			if err := notifyError(ctx, err); err != nil {
 				Log.Errorf("%+v", err)
 			}
 		}
@@ -305,34 +496,69 @@ func doScrapeAndSend(tracker *ChangeTracker, ctx *ScrapeAndSendContext, forceScr
 		hashString := hex.EncodeToString(hash[:])
 
 		if (status == StatusPossible || status == StatusUnknown) && config.DumpOutput {
-			contentUrl = dumpOutput(ctx.Name, hashString, body)
+			var dumpPath string
+			contentUrl, dumpPath = dumpOutput(ctx.Name, hashString, body)
+			if len(dumpPath) > 0 && trace != nil {
+				if err := writeTraceFile(traceFilePath(dumpPath), trace); err != nil {
+					Log.Warnf("%v", err)
+				}
+			}
+
+			ctx.mutex.Lock()
+			ctx.LastDumpPath = dumpPath
+			ctx.LastDumpUrl = contentUrl
+			ctx.mutex.Unlock()
 		}
 	}
 
+	prevState, _ := tracker.State(ctx.Name)
 	apiSend, changed := tracker.UpdateAndUnlock(ctx.Name, ctx.Status)
+	recordScrapeStats(ctx.Name, scrapeDuration, status, err, changed)
+
+	//only when the error notification went out
+	if err == nil && prevState.ErrorCount > 0 && prevState.ErrorCount >= config.ErrorWarningThreshold && config.NotifyOnError {
+		if err := notifyErrorStreakEnded(ctx, ctx.Status, prevState.ErrorCount); err != nil {
+			Log.Errorf("%+v", err)
+		}
+	}
 
 	if changed && config.NotifyOnChange {
-		if err := notifyChange(ctx.Name, ctx.Status); err != nil {
+		if err := notifyChange(ctx, prevState.Status, ctx.Status); err != nil {
 			Log.Errorf("%+v", err)
 		}
 	}
 
 	if apiSend {
-		sent := false
-		for retries := 0; retries < config.ErrorWarningThreshold; retries++ {
-			sent = doApiSend(ctx.Name, ctx.Config.ApiKey, ctx.Status, ctx.Tags, contentUrl)
-			if sent {
-				break
+		update := NewStatusUpdate(ctx, contentUrl, scrapeId)
+		//retries only go to the sinks that failed
+		sinks := statusSinksFor(update)
+		if outbox := StatusOutbox(); outbox != nil {
+			//failed updates are delivered in the background, only the ones that couldn't be queued fail the scraper
+			if len(sinks) > 0 {
+				var queued []*FilteredStatusSink
+				queued, sinks = outbox.Send(update, sinks)
+				recordApiSendStats(ctx.Name, len(queued) == 0 && len(sinks) == 0)
+			}
+		} else {
+			for retries := 0; len(sinks) > 0 && retries < config.ErrorWarningThreshold; retries++ {
+				sinks = sendStatusUpdate(update, sinks)
+				recordApiSendStats(ctx.Name, len(sinks) == 0)
+				if len(sinks) == 0 {
+					break
+				}
+				time.Sleep(time.Duration(5) * time.Second)
 			}
-			time.Sleep(time.Duration(5) * time.Second)
 		}
 
-		if !sent {
-			nerr := fmt.Errorf("Error(s) while sending updates to covidwa API")
-			if err := notifyError(ctx.Name, nerr); err != nil {
+		if len(sinks) > 0 {
+			nerr := fmt.Errorf("Error(s) while sending status updates to: %s", statusSinkNames(sinks))
+			if err := notifyError(ctx, nerr); err != nil {
 				Log.Errorf("%+v", err)
 			}
+			ctx.mutex.Lock()
 			ctx.Status = StatusApifail
+			ctx.mutex.Unlock()
+			recordScraperStatus(ctx.Name, StatusApifail)
 			if resultChan != nil {
 				resultChan <- ctx
 			}
@@ -340,58 +566,13 @@ func doScrapeAndSend(tracker *ChangeTracker, ctx *ScrapeAndSendContext, forceScr
 		}
 	}
 
+	recordScraperStatus(ctx.Name, status)
 	if resultChan != nil {
 		resultChan <- ctx
 	}
 }
 
-func doApiSend(name string, key string, status Status, tags []string, contentUrl string) bool {
-	statusStr := string(status)
-
-	if len(key) == 0 || config.TestMode || status == StatusApiSkip {
-		Log.Debugf("(silent) name: %s, key: %s, status: %s, tags: %v", name, key, statusStr, tags)
-		return true
-	}
-
-	client := &http.Client{}
-	tagStr := strings.Join(tags, `","`)
-	if len(tagStr) > 0 {
-		tagStr = fmt.Sprintf(`"%s"`, tagStr)
-	}
-
-	var data string
-	if len(contentUrl) > 0 {
-		data = fmt.Sprintf(`{"key": "%s", "status":"%s","secret":"%s","content_url":"%s","scraperTags":[%s]}`, key, statusStr, config.ApiSecret, contentUrl, tagStr)
-	} else {
-		data = fmt.Sprintf(`{"key": "%s", "status":"%s","secret":"%s","scraperTags":[%s]}`, key, statusStr, config.ApiSecret, tagStr)
-	}
-
-	req, _ := http.NewRequest("POST", config.ApiUrl, strings.NewReader(data))
-	req.Header.Add("Content-Type", "application/json")
-	resp, err := client.Do(req)
-
-	if err != nil {
-		Log.Errorf("%+v", err)
-		return false
-	}
-
-	bytes, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		Log.Errorf("%+v", err)
-		return false
-	}
-
-	Log.Debug(fmt.Sprintf("%s: %s", strings.ReplaceAll(data, config.ApiSecret, "<snip>"), string(bytes)))
-
-	if resp.StatusCode != 200 {
-		Log.Errorf("%s: API Status code is %d!", name, resp.StatusCode)
-		return false
-	}
-
-	return true
-}
-
-func dumpOutput(name string, hash string, body []byte) (url string) {
+func dumpOutput(name string, hash string, body []byte) (url string, filePath string) {
 	if len(hash) == 0 {
 		hashBytes := sha256.Sum256(body)
 		hash = hex.EncodeToString(hashBytes[:])
@@ -401,9 +582,14 @@ func dumpOutput(name string, hash string, body []byte) (url string) {
 	url = ""
 	var err error
 
+	metadata := map[string]string{"scraper": name, "run-id": CurrentRunId}
+	if scrapeId := ScrapeId(name); len(scrapeId) > 0 {
+		metadata["scrape-id"] = scrapeId
+	}
+
 	if config.DumpOutputS3 {
 		if HasAWSCredentials() {
-			url, err = PutS3Object(S3ScraperOutputBucket, fileName, body)
+			url, err = PutS3Object(S3ScraperOutputBucket, fileName, body, metadata)
 			if err != nil {
 				Log.Warnf("%v", err)
 			} else {
@@ -415,65 +601,70 @@ func dumpOutput(name string, hash string, body []byte) (url string) {
 	}
 
 	if config.DumpOutput {
-		filePath := filepath.Join(config.DumpDir, fileName)
+		filePath = filepath.Join(config.DumpDir, fileName)
 
 		if _, err := os.Stat(filePath); err == nil {
 			//fprintlnDebug("%s already exists, skipping", filePath)
-			return url
+			return url, filePath
 		}
 
 		err = ioutil.WriteFile(filePath, body, 0644)
 		if err != nil {
 			Log.Warnf("%v", err)
+			return url, ""
 		}
 
 		Log.Debugf("Wrote %d bytes to file: %s", len(body), filePath)
+
+		metadata["time"] = time.Now().Format(time.RFC3339)
+		if metadataBytes, err := json.MarshalIndent(metadata, "", "  "); err == nil {
+			if err = ioutil.WriteFile(dumpMetadataPath(filePath), metadataBytes, 0644); err != nil {
+				Log.Warnf("%v", err)
+			}
+		}
 	}
 
-	return url
+	return url, filePath
 }
 
-func notifyError(name string, err error) error {
+func notifyError(ctx *ScrapeAndSendContext, err error) error {
+	name := ctx.Name
 	subject := DefaultSubject
-	body := fmt.Sprintf("Error during scrape: %s: %v", name, err)
+	body := fmt.Sprintf("Error during scrape: %s: %s error: %v", name, ErrorClass(err), err)
 
-	return sendEmail(subject, body)
+	var statusErr *HttpStatusError
+	if errors.As(err, &statusErr) {
+		body = fmt.Sprintf("%s\r\nUrl: %s\r\nResponse: %s", body, statusErr.Url, statusErr.Body)
+	}
+	body = fmt.Sprintf("%s\r\n%s", body, notificationIds(name))
+
+	notification := notificationFor(NotificationKindError, ctx, subject, body)
+	notification.Error = err.Error()
+	notification.ErrorClass = ErrorClass(err)
+	return Notifications.Add(notification)
 }
 
-func notifyChange(name string, status Status) error {
+func notifyChange(ctx *ScrapeAndSendContext, oldStatus Status, status Status) error {
 	subject := DefaultSubject
-	body := fmt.Sprintf("Detected change: %s, new status: %v", name, status)
+	body := fmt.Sprintf("Detected change: %s, new status: %v\r\n%s", ctx.Name, status, notificationIds(ctx.Name))
 
-	return sendEmail(subject, body)
+	notification := notificationFor(NotificationKindChange, ctx, subject, body)
+	notification.OldStatus = oldStatus
+	notification.Status = status
+	return Notifications.Add(notification)
 }
 
-func sendEmail(subject string, body string) error {
-	if len(config.SmtpHost) == 0 {
-		return nil
-	}
-
-	Log.Infof("Subject: %s", subject)
-	Log.Infof("Body: %s", body)
-
-	sb := strings.Builder{}
-	sb.WriteString(fmt.Sprintf("Subject: %s\r\n", subject))
-	sb.WriteString("\r\n")
-	sb.WriteString(body)
-	fmt.Println(sb.String())
-
-	auth := smtp.PlainAuth("", config.SmtpUsername, config.SmtpPassword, config.SmtpHost)
-
-	err := smtp.SendMail(fmt.Sprintf("%s:%d", config.SmtpHost, config.SmtpPort), auth, config.FromEmailAddress, config.NotifyEmailAddrs, []byte(sb.String()))
-
-	if err != nil {
-		Log.Errorf("sendEmail: %+v", err)
-	}
+func notifyErrorStreakEnded(ctx *ScrapeAndSendContext, status Status, errorCount int) error {
+	subject := DefaultSubject
+	body := fmt.Sprintf("Scraper recovered: %s, status: %v after %d error(s) in a row\r\n%s", ctx.Name, status, errorCount, notificationIds(ctx.Name))
 
-	return err
+	notification := notificationFor(NotificationKindRecovered, ctx, subject, body)
+	notification.Status = status
+	return Notifications.Add(notification)
 }
 
 func printUsageAndExit(args []string) {
 	exeName := filepath.Base(args[0])
-	fmt.Printf("Usage: %s once | test <scraper_name>\n", exeName)
+	fmt.Printf("Usage: %s once | test <scraper_name> | history <scraper_name> [--since <36h|2006-01-02>] | report [--send] [--html] | mock-api [--addr <host:port>] [--clinics <file>] [--updates <file>] [--secret <secret>]\n", exeName)
 	os.Exit(0)
 }
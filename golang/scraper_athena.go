@@ -99,7 +99,7 @@ func (s *ScraperAthena) Configure(params map[string]interface{}) error {
 	s.NamePattern = getPatternOptional(params, SigneticParamKeyNamePattern)
 
 	if s.NamePattern != nil {
-		Log.Debugf("%s: Configured name pattern: %v", s.Name(), s.NamePattern)
+		LogFor(s.Name()).Debugf("Configured name pattern: %v", s.NamePattern)
 	}
 
 	url, exists := getStringOptional(params, "url")
@@ -161,7 +161,7 @@ func (s *ScraperAthena) ScrapeUrls(urls ...string) (status Status, tags TagSet,
 
 	if len(deptId) == 0 {
 		status = StatusPossible
-		Log.Errorf("%s: Coulld not parse department or location id from %s", s.Name(), url)
+		LogFor(s.Name()).Errorf("Coulld not parse department or location id from %s", url)
 		return
 	}
 
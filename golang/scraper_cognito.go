@@ -107,7 +107,7 @@ func (s *ScraperCognito) ScrapeUrls(urls ...string) (status Status, tags TagSet,
 			site := strings.TrimPrefix(dateUrl, "https://www.cognitoforms.com/")
 			unavailMessage := match2[len(match2)-1]
 			unavailMessage = strings.Split(unavailMessage, "\n")[0]
-			Log.Infof("%s: %s unavailable: %s", s.Name(), site, unavailMessage)
+			LogFor(s.Name()).Infof("%s unavailable: %s", site, unavailMessage)
 		} else {
 			status = StatusYes
 			return
@@ -130,7 +130,7 @@ func (s *ScraperCognito) ScrapeForm(urls ...string) (body []byte, err error) {
 	if err != nil {
 		return
 	}
-	Log.Debugf("%s: Session Token: %s", s.Name(), sessionToken)
+	LogFor(s.Name()).Debugf("Session Token: %s", sessionToken)
 
 	var formParamsJsonStr string
 	formParamsJsonStr, body, err = ExtractScrapeUrl(s.Name(), CognitoFormParamPattern, urls...)
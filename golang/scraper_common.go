@@ -111,6 +111,24 @@ func (ts TagSet) Merge(ts2 TagSet) TagSet {
 	return ts
 }
 
+func (ts TagSet) MarshalJSON() ([]byte, error) {
+	return json.Marshal(ts.ToStringArray())
+}
+
+func (ts *TagSet) UnmarshalJSON(data []byte) error {
+	strArr := make([]string, 0)
+	if err := json.Unmarshal(data, &strArr); err != nil {
+		return err
+	}
+
+	ts.arr = make([]Tag, 0, len(strArr))
+	for _, str := range strArr {
+		ts.arr = append(ts.arr, Tag(str))
+	}
+
+	return nil
+}
+
 type StatusAndTagSet struct {
 	Status Status
 	TagSet TagSet
@@ -167,9 +185,8 @@ func (c GeoCoord) String() string {
 var magicDateTimeGenericRE = regexp.MustCompile(MagicDateTimeGeneric)
 
 func replaceMagic(input string) string {
-	//force Pacific Time
-	loc, _ := time.LoadLocation("America/Los_Angeles")
-	now := time.Now().In(loc)
+	//Pacific Time unless another timezone is configured
+	now := time.Now().In(templateLocation())
 
 	return replaceMagicWithTime(input, now)
 }
@@ -535,13 +552,14 @@ func GetRegexCount(name string, re *regexp.Regexp, body []byte) int {
 		return -1
 	}
 
+	log := LogFor(name)
 	total := 0
 	if len(re.SubexpNames()) < 2 {
-		Log.Debugf("%s: matching single appointments", name)
+		log.Debugf("matching single appointments")
 		matches := re.FindAll(body, -1)
 		total = len(matches)
 	} else {
-		Log.Debugf("%s: matching appointment counts", name)
+		log.Debugf("matching appointment counts")
 		matches := GetAllRegexSubmatches(re, string(body))
 		for _, match := range matches {
 			for _, submatch := range match {
@@ -551,12 +569,12 @@ func GetRegexCount(name string, re *regexp.Regexp, body []byte) int {
 						n, parseErr := strconv.Atoi(v)
 						if parseErr != nil {
 							n = 0
-							Log.Errorf("%s: %v", name, parseErr)
+							log.Errorf("%v", parseErr)
 						}
 						total += n
 					}
 				} else {
-					Log.Errorf("%s: could not match number or list of numbers: %v, %s", name, ListOfNumbersPattern, submatch)
+					log.Errorf("could not match number or list of numbers: %v, %s", ListOfNumbersPattern, submatch)
 				}
 			}
 		}
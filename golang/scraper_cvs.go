@@ -186,7 +186,7 @@ func CvsGetStores(name string, proxyProvider ProxyProvider) (map[string]CountAnd
 
 		for _, city := range cities {
 			if city.State != "WA" {
-				Log.Errorf("%s: Unexpected state: %s", name, city.State)
+				LogFor(name).Errorf("Unexpected state: %s", city.State)
 			} else {
 				cityStr := fmt.Sprintf("%s, %s", city.City, city.State)
 				storesByCity, body, err := CvsGetStoresBySearchString(name, cityStr, proxyProvider)
@@ -197,7 +197,7 @@ func CvsGetStores(name string, proxyProvider ProxyProvider) (map[string]CountAnd
 					stores[store] = countAndTags
 				}
 				if cacheMiss {
-					Log.Debugf("%s: looking up stores in %s", name, cityStr)
+					LogFor(name).Debugf("looking up stores in %s", cityStr)
 				}
 			}
 		}
@@ -282,20 +282,13 @@ func CvsGetStoresBySearchString(name string, str string, proxyProvider ProxyProv
 	var resp *CvsGetStoresApiResp
 	var cacheKey = fmt.Sprintf("cvs|%s", str)
 
-	respCached := Cache.GetOrLock(cacheKey)
-	if respCached != nil {
-		resp = respCached.(*CvsGetStoresApiResp)
-	} else {
-		defer Cache.Unlock(cacheKey)
-	}
-
-	if resp == nil {
-		resp = new(CvsGetStoresApiResp)
+	respCached, _, err := Cache.GetOrCompute(cacheKey, 60, 0, func() (interface{}, error) {
+		resp := new(CvsGetStoresApiResp)
 		for retries := 0; ; retries++ {
 			if proxyProvider != nil {
 				proxyEndpoint, err = proxyProvider.GetProxy()
 				if err != nil {
-					return nil, nil, err
+					return nil, err
 				}
 				endpoint.HttpClient = proxyEndpoint.GetHttpClient()
 			}
@@ -305,7 +298,7 @@ func CvsGetStoresBySearchString(name string, str string, proxyProvider ProxyProv
 				if proxyEndpoint != nil {
 					proxyEndpoint.BlackList()
 				}
-				return nil, body, err
+				return nil, err
 			}
 
 			err = json.Unmarshal(body, resp)
@@ -313,17 +306,20 @@ func CvsGetStoresBySearchString(name string, str string, proxyProvider ProxyProv
 				if proxyEndpoint != nil {
 					proxyEndpoint.BlackList()
 					if CvsAntiBotPattern.Match(body) && retries < 5 {
-						Log.Debugf("%s: Anti bot block detected, retrying %d/5", name, retries+1)
+						LogFor(name).Debugf("Anti bot block detected, retrying %d/5", retries+1)
 						continue
 					}
 				}
-				return nil, body, err
+				return nil, err
 			}
 
-			Cache.Put(cacheKey, resp, 60, 0)
-			break
+			return resp, nil
 		}
+	})
+	if err != nil {
+		return nil, body, err
 	}
+	resp = respCached.(*CvsGetStoresApiResp)
 
 	stores := make(map[string]CountAndTagSet)
 
@@ -95,7 +95,7 @@ func (s *ScraperJotform) Configure(params map[string]interface{}) error {
 	s.NamePattern = getPatternOptional(params, JotformParamKeyNamePattern)
 
 	if s.NamePattern != nil {
-		Log.Debugf("%s: Configured name pattern: %v", s.Name(), s.NamePattern)
+		LogFor(s.Name()).Debugf("Configured name pattern: %v", s.NamePattern)
 	}
 
 	if getBool(params, JotformParamKeyPrepmod) {
@@ -143,27 +143,27 @@ func (s *ScraperJotform) ScrapeUrls(urls ...string) (status Status, tags TagSet,
 
 		jotformId, formBody, _ := ExtractScrapeUrl(s.Name(), JotformIdPattern, url)
 		if len(jotformId) == 0 {
-			Log.Warnf("%s: Could not parse id from %s", s.Name(), url)
+			LogFor(s.Name()).Warnf("Could not parse id from %s", url)
 			continue
 		}
 
 		if s.NamePattern != nil {
 			if !s.NamePattern.Match(formBody) {
-				Log.Debugf("%s: Page body did not match name pattern, skipping...", s.Name())
+				LogFor(s.Name()).Debugf("Page body did not match name pattern, skipping...")
 				continue
 			} else {
-				Log.Debugf("%s: Page body matched name pattern: %v", s.Name(), s.NamePattern)
+				LogFor(s.Name()).Debugf("Page body matched name pattern: %v", s.NamePattern)
 			}
 		}
 
 		jotformDomain := JotformDomainPattern.FindString(url)
 		if len(jotformDomain) == 0 {
 			status = StatusPossible
-			Log.Errorf("%s: Could not parse domain from %s", s.Name(), url)
+			LogFor(s.Name()).Errorf("Could not parse domain from %s", url)
 			return
 		}
 
-		Log.Debugf("%s: found jotform id: %s, domain: %s", s.Name(), jotformId, jotformDomain)
+		LogFor(s.Name()).Debugf("found jotform id: %s, domain: %s", jotformId, jotformDomain)
 
 		if jotformDomain == "form.jotform.com" {
 			//hack to make some sites work
@@ -232,7 +232,7 @@ func (s *ScraperJotform) ScrapeJotformAPI(jotformDomain string, jotformId string
 
 	for groupId, group := range jsonData.Groups {
 		if extraDebug {
-			Log.Debugf("%s: Group id: %v", s.Name(), groupId)
+			LogFor(s.Name()).Debugf("Group id: %v", groupId)
 		}
 		if dates, ok := group.(map[string]interface{}); ok {
 			for dateKey, date := range dates {
@@ -269,7 +269,8 @@ func (s *ScraperJotform) ScrapeJotformAPI(jotformDomain string, jotformId string
 		}
 	}
 
-	Log.Debugf("%s: Total availability: %d", s.Name(), availableSlots)
+	LogFor(s.Name()).Debugf("Total availability: %d", availableSlots)
+	ReportAppointments(s.Name(), availableSlots)
 
 	if availableSlots > s.LimitedThreshold {
 		status = StatusYes
@@ -123,13 +123,9 @@ func (cg *KrogerFetcher) Fetch(group string, dist int) ([]byte, error) {
 		Value: "gzip, deflate, br",
 	}
 
-	var cachedData *KrogerFetcherCacheData
-	var ok bool
-	if cachedData, ok = Cache.GetOrLock(cg.epCacheName).(*KrogerFetcherCacheData); ok {
-		Log.Debugf("Endpoint (cached) uses left: %d", Cache.UsesLeft(cg.epCacheName))
-	} else {
-		defer Cache.Unlock(cg.epCacheName)
-		cachedData = new(KrogerFetcherCacheData)
+	cachedValue, cached, err := Cache.GetOrCompute(cg.epCacheName, KrogerEndpointCacheTTL, KrogerEndpointReuseMax, func() (interface{}, error) {
+		var err error
+		cachedData := new(KrogerFetcherCacheData)
 		cachedData.SensorDataUses = 0
 
 		Log.Debugf("Endpoint (new) uses left: %d", KrogerEndpointReuseMax)
@@ -163,7 +159,15 @@ func (cg *KrogerFetcher) Fetch(group string, dist int) ([]byte, error) {
 
 		time.Sleep(100 * time.Millisecond)
 
-		Cache.Put(cg.epCacheName, cachedData, KrogerEndpointCacheTTL, KrogerEndpointReuseMax)
+		return cachedData, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	cachedData := cachedValue.(*KrogerFetcherCacheData)
+	if cached {
+		Log.Debugf("Endpoint (cached) uses left: %d", Cache.UsesLeft(cg.epCacheName))
 	}
 
 	reqType := Header{
@@ -319,7 +323,7 @@ func (s *ScraperKroger) Configure(params map[string]interface{}) error {
 	var err error
 	s.Zipcode, err = getStringRequired(params, KrogerParamKeyZipcode)
 	if err != nil {
-		Log.Errorf("%s: %v", s.Name(), err)
+		LogFor(s.Name()).Errorf("%v", err)
 	}
 	return nil
 }
@@ -362,19 +366,15 @@ func (s *ScraperKroger) Scrape() (status Status, tags TagSet, body []byte, err e
 	status = StatusUnknown
 
 	cacheKey := fmt.Sprintf(KrogerCacheKey, s.LocationNo)
-	cachedStatusAndTagSet := Cache.GetOrLock(cacheKey)
-	if cachedStatusAndTagSet != nil {
+	if cachedStatusAndTagSet, ok := Cache.Get(cacheKey); ok {
 		statusAndTagSet := cachedStatusAndTagSet.(StatusAndTagSet)
 		status = statusAndTagSet.Status
 		tags = statusAndTagSet.TagSet
 		return
-	} else {
-		//don't have to defer since we're protected by the mutex
-		Cache.Unlock(cacheKey)
 	}
 
 	if len(s.Zipcode) < 5 {
-		Log.Errorf("%s: Missing or invalid zip code", s.Name())
+		LogFor(s.Name()).Errorf("Missing or invalid zip code")
 		status = StatusPossible
 		return
 	}
@@ -403,7 +403,7 @@ func (s *ScraperKroger) Scrape() (status Status, tags TagSet, body []byte, err e
 		if _, exists := s.KnownLocations[store.StoreId]; !exists {
 			if store.LocDetails.Address.State == "WA" {
 				s.KnownLocations[store.StoreId] = true
-				Log.Errorf("%s: Kroger WA location not found in airtable: %v", s.Name(), store.LocDetails)
+				LogFor(s.Name()).Errorf("Kroger WA location not found in airtable: %v", store.LocDetails)
 			}
 		}
 
@@ -439,7 +439,7 @@ func (s *ScraperKroger) Scrape() (status Status, tags TagSet, body []byte, err e
 	}
 
 	if !storeFound {
-		Log.Errorf("%s: Store ID %s not found in zip code %s!", s.Name(), s.LocationNo, s.Zipcode)
+		LogFor(s.Name()).Errorf("Store ID %s not found in zip code %s!", s.LocationNo, s.Zipcode)
 	}
 
 	return
@@ -111,7 +111,7 @@ func (s *ScraperMsOutlook) Configure(params map[string]interface{}) error {
 	s.NamePattern = getPatternOptional(params, MsOutlookParamKeyNamePattern)
 
 	if s.NamePattern != nil {
-		Log.Debugf("%s: Configured name pattern: %v", s.Name(), s.NamePattern)
+		LogFor(s.Name()).Debugf("Configured name pattern: %v", s.NamePattern)
 	}
 
 	url, exists := getStringOptional(params, "url")
@@ -201,15 +201,15 @@ func (s *ScraperMsOutlook) ScrapeUrls(urls ...string) (status Status, tags TagSe
 	apptCount := 0
 
 	for _, svc := range formData.Services {
-		Log.Debugf("%s: checking service '%s'", s.Name(), svc.Name)
+		LogFor(s.Name()).Debugf("checking service '%s'", svc.Name)
 
 		if s.NamePattern != nil && !s.NamePattern.MatchString(svc.Name) {
-			Log.Debugf("%s: skipping '%s' because it didn't match name pattern", s.Name(), svc.Name)
+			LogFor(s.Name()).Debugf("skipping '%s' because it didn't match name pattern", svc.Name)
 			continue
 		}
 
 		if len(svc.StaffList) == 0 {
-			Log.Warnf("%s: skipping '%s' because it didn't have any staff", s.Name(), svc.Name)
+			LogFor(s.Name()).Warnf("skipping '%s' because it didn't have any staff", svc.Name)
 			continue
 		}
 
@@ -231,10 +231,10 @@ func (s *ScraperMsOutlook) ScrapeUrls(urls ...string) (status Status, tags TagSe
 		startDateStr := now.Format(MsOutlookDateFormat)
 		endDateStr := now.AddDate(0, 0, svc.SchedulingPolicy.CapTimeInDays+1).Format(MsOutlookDateFormat)
 		endpoint.Body = fmt.Sprintf(MsOutlookServiceReq, strings.Join(svc.StaffList, `","`), startDateStr, endDateStr, s.Timezone.String(), svc.Id)
-		Log.Debugf("%s: request: %s", s.Name(), endpoint.Body)
+		LogFor(s.Name()).Debugf("request: %s", endpoint.Body)
 		body, _, err = endpoint.FetchCached(s.Name())
 		if err != nil {
-			Log.Errorf("%s: %v", s.Name(), err)
+			LogFor(s.Name()).Errorf("%v", err)
 			continue
 		}
 
@@ -256,23 +256,23 @@ func (s *ScraperMsOutlook) ScrapeUrls(urls ...string) (status Status, tags TagSe
 		for _, staff := range apiResp.Staff {
 			for _, timeblock := range staff.TimeBlocks {
 				if len(timeblock.Start) < len(MsOutlookDateTimeFormat) {
-					Log.Warnf("%s: Missing or malformed datetime: %s", s.Name(), timeblock.Start)
+					LogFor(s.Name()).Warnf("Missing or malformed datetime: %s", timeblock.Start)
 					continue
 				}
 				if len(timeblock.End) < len(MsOutlookDateTimeFormat) {
-					Log.Warnf("%s: Missing or malformed datetime: %s", s.Name(), timeblock.End)
+					LogFor(s.Name()).Warnf("Missing or malformed datetime: %s", timeblock.End)
 					continue
 				}
 
 				var start, end time.Time
 				start, err = time.ParseInLocation(MsOutlookDateTimeFormat, timeblock.Start[:len(MsOutlookDateTimeFormat)], s.Timezone)
 				if err != nil {
-					Log.Warnf("%s: %v", s.Name(), err)
+					LogFor(s.Name()).Warnf("%v", err)
 					continue
 				}
 				end, err = time.ParseInLocation(MsOutlookDateTimeFormat, timeblock.End[:len(MsOutlookDateTimeFormat)], s.Timezone)
 				if err != nil {
-					Log.Warnf("%s: %v", s.Name(), err)
+					LogFor(s.Name()).Warnf("%v", err)
 					continue
 				}
 
@@ -289,7 +289,8 @@ func (s *ScraperMsOutlook) ScrapeUrls(urls ...string) (status Status, tags TagSe
 						continue
 					}
 
-					Log.Debugf("%s: Available: %v", s.Name(), apptTime)
+					LogFor(s.Name()).Debugf("Available: %v", apptTime)
+					ReportSlot(s.Name(), apptTime)
 					apptCount++
 					err = nil
 					tags = tags.ParseAndAddVaccineType(svc.Name)
@@ -298,6 +299,7 @@ func (s *ScraperMsOutlook) ScrapeUrls(urls ...string) (status Status, tags TagSe
 		}
 	}
 
+	ReportAppointments(s.Name(), apptCount)
 	if apptCount > s.LimitedThreshold {
 		status = StatusYes
 	} else if apptCount > 0 {
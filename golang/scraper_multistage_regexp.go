@@ -101,10 +101,14 @@ func (s *ScraperMultistageRegexp) Configure(params map[string]interface{}) error
 		stage.NumApptsPattern = getPatternOptional(stageParams, ParamKeyNumAppts)
 		stage.NumApptsTakenPattern = getPatternOptional(stageParams, ParamKeyNumApptsTaken)
 
+		if err = applyScraperTransforms(params, stage.Endpoint); err != nil {
+			return err
+		}
+
 		s.Stages = append(s.Stages, stage)
 	}
 
-	Log.Debugf("%s: Configured %d stages", s.Name(), len(s.Stages))
+	LogFor(s.Name()).Debugf("Configured %d stages", len(s.Stages))
 
 	return nil
 }
@@ -140,7 +144,7 @@ func (s *ScraperMultistageRegexp) Scrape() (status Status, tags TagSet, body []b
 			}
 		}
 
-		status, body, err = s.ScrapeRecursive(0, nil)
+		status, body, err = s.ScrapeRecursive(0, nil, nil)
 		if status == StatusUnknown && proxyEndpoint != nil {
 			proxyEndpoint.BlackList()
 		}
@@ -152,7 +156,9 @@ func (s *ScraperMultistageRegexp) Scrape() (status Status, tags TagSet, body []b
 	return
 }
 
-func (s *ScraperMultistageRegexp) ScrapeRecursive(idx int, prevMatch []string) (status Status, body []byte, err error) {
+// prevMatch: submatches of the previous stage's next_url_regexp
+// captures: named submatches from all previous stages
+func (s *ScraperMultistageRegexp) ScrapeRecursive(idx int, prevMatch []string, captures map[string]string) (status Status, body []byte, err error) {
 	if idx >= len(s.Stages) {
 		//reached the end of all stages and still no yes or no match
 		//set status as possible so developer can take a look
@@ -161,18 +167,21 @@ func (s *ScraperMultistageRegexp) ScrapeRecursive(idx int, prevMatch []string) (
 
 	stage := s.Stages[idx]
 	originalUrl := stage.Endpoint.Url
-	decorateEndpoint(s.Name(), stage.Endpoint, prevMatch)
+	originalBody := stage.Endpoint.Body
+	decorateEndpoint(s.Name(), stage.Endpoint, prevMatch, captures)
 
 	body, _, err = stage.Endpoint.FetchCached(s.Name())
+	fetchUrl := stage.Endpoint.RenderedUrl(s.Name())
+	stage.Endpoint.Url = originalUrl
+	stage.Endpoint.Body = originalBody
 	if err != nil {
 		return StatusUnknown, body, err
 	}
 
-	fetchUrl := stage.Endpoint.Url
-	stage.Endpoint.Url = originalUrl
+	log := LogFor(s.Name()).With(LogFieldStage, idx).With(LogFieldUrl, fetchUrl)
 
 	if stage.AvailablePattern != nil && stage.AvailablePattern.Match(body) {
-		Log.Debugf("%s: stage %d: Available pattern matched", s.Name(), idx)
+		log.Debugf("Available pattern matched")
 
 		if stage.LimitedThreshold > 0 && stage.NumApptsPattern != nil {
 			totalAppointments := GetRegexCount(s.Name(), stage.NumApptsPattern, body)
@@ -181,7 +190,8 @@ func (s *ScraperMultistageRegexp) ScrapeRecursive(idx int, prevMatch []string) (
 				totalAppointments -= GetRegexCount(s.Name(), stage.NumApptsTakenPattern, body)
 			}
 
-			Log.Debugf("%s: stage %d: total appointments: %d", s.Name(), idx, totalAppointments)
+			log.Debugf("total appointments: %d", totalAppointments)
+			ReportAppointments(s.Name(), totalAppointments)
 
 			if totalAppointments <= stage.LimitedThreshold {
 				status = StatusLimited
@@ -194,35 +204,35 @@ func (s *ScraperMultistageRegexp) ScrapeRecursive(idx int, prevMatch []string) (
 
 		return
 	} else if stage.UnavailablePattern != nil && stage.UnavailablePattern.Match(body) {
-		Log.Debugf("%s: stage %d: Unavailable pattern matched", s.Name(), idx)
+		log.Debugf("Unavailable pattern matched")
 		return StatusNo, body, nil
 	} else if stage.ErrorPattern != nil && stage.ErrorPattern.Match(body) {
-		return StatusUnknown, body, fmt.Errorf("Error pattern matched")
+		return StatusUnknown, body, NewPatternError(stage.ErrorPattern)
 	} else if stage.NextUrlPattern != nil {
 		matches := stage.NextUrlPattern.FindAllStringSubmatch(string(body), -1)
 
 		if len(matches) == 0 {
-			Log.Warnf("%s: stage %d: did not match any next urls", s.Name(), idx)
+			log.Warnf("did not match any next urls")
 			return StatusPossible, body, nil
 		}
 
 		for _, match := range matches {
-			Log.Debugf("%s: stage %d: matched url value: %s", s.Name(), idx, match)
+			log.Debugf("matched url value: %s", match)
 
 			if s.Stages[idx].RecursionType == MultistageRecursionTypeFirst {
 				//default, just return the first recursion branch
-				return s.ScrapeRecursive(idx+1, match)
+				return s.ScrapeRecursive(idx+1, match, mergeCaptures(captures, stage.NextUrlPattern, match))
 			}
 		}
 
 		if s.Stages[idx].RecursionType == MultistageRecursionTypeAny {
-			Log.Debugf("%s: stage %d: recursion any", s.Name(), idx)
+			log.Debugf("recursion any")
 
 			var anyNo, anyLimited bool
 			var anyNoBody, anyLimitedBody, anyBody []byte
 
 			for _, match := range matches {
-				status, body, err = s.ScrapeRecursive(idx+1, match)
+				status, body, err = s.ScrapeRecursive(idx+1, match, mergeCaptures(captures, stage.NextUrlPattern, match))
 				if err != nil {
 					return status, body, err
 				}
@@ -253,17 +263,46 @@ func (s *ScraperMultistageRegexp) ScrapeRecursive(idx int, prevMatch []string) (
 			}
 		}
 
-		Log.Errorf("%s: Sanity check failed, this should never be reached", s.Name())
+		log.Errorf("Sanity check failed, this should never be reached")
 		return StatusUnknown, body, nil
 	} else {
-		Log.Debugf("%s: Stage %d (%s) returning Possible", s.Name(), idx, fetchUrl)
+		log.Debugf("returning Possible")
 		return StatusPossible, body, nil
 	}
 }
 
-func decorateEndpoint(name string, endpoint *Endpoint, prevMatch []string) {
-	endpoint.Url = replacePrevMagic(name, endpoint.Url, prevMatch)
-	endpoint.Body = replacePrevMagic(name, endpoint.Body, prevMatch)
+// templates are left as configured, RenderTemplate replaces the previous stage's values after executing them, so
+// matched text is never parsed as template code
+func decorateEndpoint(name string, endpoint *Endpoint, prevMatch []string, captures map[string]string) {
+	if !isTemplate(endpoint.Url) {
+		endpoint.Url = replacePrevMagic(name, endpoint.Url, prevMatch)
+	}
+	if !isTemplate(endpoint.Body) {
+		endpoint.Body = replacePrevMagic(name, endpoint.Body, prevMatch)
+	}
+	endpoint.PrevMatch = prevMatch
+	endpoint.Captures = captures
+}
+
+// returns a copy of captures with the named submatches of match added
+func mergeCaptures(captures map[string]string, pattern *regexp.Regexp, match []string) map[string]string {
+	merged := make(map[string]string)
+	for k, v := range captures {
+		merged[k] = v
+	}
+
+	for i, name := range pattern.SubexpNames() {
+		if len(name) > 0 && i < len(match) {
+			merged[name] = match[i]
+		}
+	}
+
+	return merged
+}
+
+// whether source uses the previous stage's submatches through ##PREVIOUS## or ##PREV_SUBMATCH_n##
+func hasPrevMagic(source string) bool {
+	return strings.Contains(source, MagicPrevValue) || MagicPrevPattern.MatchString(source)
 }
 
 func replacePrevMagic(name string, source string, prevMatch []string) string {
@@ -272,9 +311,9 @@ func replacePrevMagic(name string, source string, prevMatch []string) string {
 	if strings.Contains(source, MagicPrevValue) {
 		if len(prevMatch) > 0 {
 			//use last submatch
-			dest = strings.ReplaceAll(source, MagicPrevValue, prevMatch[len(prevMatch)-1])
+			dest = strings.ReplaceAll(dest, MagicPrevValue, prevMatch[len(prevMatch)-1])
 		} else {
-			Log.Errorf("%s: Found PREVIOUS pattern, but no matches found in previous url", name)
+			LogFor(name).Errorf("Found PREVIOUS pattern, but no matches found in previous url")
 		}
 	}
 
@@ -282,21 +321,21 @@ func replacePrevMagic(name string, source string, prevMatch []string) string {
 	for _, magicSubmatch := range magicSubmatches {
 		magicSubmatchIndex, err := strconv.Atoi(magicSubmatch[16 : len(magicSubmatch)-2])
 		if err != nil {
-			Log.Errorf("%s: Malformed PREV_SUBMATCH pattern: %s: %v", name, magicSubmatch, err)
+			LogFor(name).Errorf("Malformed PREV_SUBMATCH pattern: %s: %v", magicSubmatch, err)
 			continue
 		}
 
 		if len(prevMatch) < 2 {
-			Log.Errorf("%s: Found PREV_SUBMATCH pattern(s), but no submatches found in previous url", name)
+			LogFor(name).Errorf("Found PREV_SUBMATCH pattern(s), but no submatches found in previous url")
 			continue
 		}
 
 		if magicSubmatchIndex < 1 || magicSubmatchIndex >= len(prevMatch) {
-			Log.Errorf("%s: PREV_SUBMATCH index out of range: expected [1,%d), got %d", name, len(prevMatch), magicSubmatchIndex)
+			LogFor(name).Errorf("PREV_SUBMATCH index out of range: expected [1,%d), got %d", len(prevMatch), magicSubmatchIndex)
 			continue
 		}
 
-		dest = strings.ReplaceAll(source, magicSubmatch, prevMatch[magicSubmatchIndex])
+		dest = strings.ReplaceAll(dest, magicSubmatch, prevMatch[magicSubmatchIndex])
 	}
 
 	return dest
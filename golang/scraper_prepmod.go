@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"regexp"
 	"strconv"
-	"strings"
 )
 
 const ScraperTypePrepmod = "prepmod"
@@ -103,8 +102,7 @@ func (s *ScraperPrepmod) ScrapeUrls(urls ...string) (status Status, tags TagSet,
 	}
 
 	for _, url := range urls {
-		url = strings.ReplaceAll(url, `\u002F`, `/`)
-		url = strings.ReplaceAll(url, `&amp;`, `&`)
+		url = string(ApplyTransforms([]string{TransformJsonUnescape, TransformHtmlUnescape}, []byte(url), nil))
 
 		endpoint := new(Endpoint)
 		endpoint.Url = url
@@ -115,7 +113,7 @@ func (s *ScraperPrepmod) ScrapeUrls(urls ...string) (status Status, tags TagSet,
 		}
 
 		if !PrepmodPagePattern.Match(body) {
-			Log.Errorf("%s: page did not match Prepmod appointment page pattern", s.Name())
+			LogFor(s.Name()).Errorf("page did not match Prepmod appointment page pattern")
 			if status != StatusYes && status != StatusLimited && status != StatusWaitList {
 				status = StatusPossible
 			}
@@ -141,7 +139,7 @@ func (s *ScraperPrepmod) ScrapeUrls(urls ...string) (status Status, tags TagSet,
 					appointmentCount += parsedCount
 				}
 
-				Log.Debugf("%s: appointment count: %d", s.Name(), appointmentCount)
+				LogFor(s.Name()).Debugf("appointment count: %d", appointmentCount)
 				if appointmentCount > s.LimitedThresold {
 					status = StatusYes
 				} else if appointmentCount > 0 {
@@ -149,7 +147,7 @@ func (s *ScraperPrepmod) ScrapeUrls(urls ...string) (status Status, tags TagSet,
 						status = StatusLimited
 					}
 				} else {
-					Log.Errorf("%s: Found matching available patterns but appointment count was < 0", s.Name())
+					LogFor(s.Name()).Errorf("Found matching available patterns but appointment count was < 0")
 					if status != StatusYes && status != StatusLimited && status != StatusWaitList {
 						status = StatusPossible
 					}
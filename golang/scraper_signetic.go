@@ -100,13 +100,13 @@ func (s *ScraperSignetic) Configure(params map[string]interface{}) error {
 	s.NamePattern = getPatternOptional(params, SigneticParamKeyNamePattern)
 
 	if s.NamePattern != nil {
-		Log.Debugf("%s: Configured name pattern: %v", s.Name(), s.NamePattern)
+		LogFor(s.Name()).Debugf("Configured name pattern: %v", s.NamePattern)
 	}
 
 	threshold, _ := getFloatOptional(params, SigneticParamKeyLimitedThreshold)
 	if threshold > 0 {
 		s.LimitedThreshold = int(threshold)
-		Log.Debugf("%s: Configured yes threshold: %v", s.Name(), s.LimitedThreshold)
+		LogFor(s.Name()).Debugf("Configured yes threshold: %v", s.LimitedThreshold)
 	}
 
 	url, exists := getStringOptional(params, "url")
@@ -167,18 +167,18 @@ func (s *ScraperSignetic) ScrapeUrls(urls ...string) (status Status, tags TagSet
 
 	signeticOrgId := SigneticOrgIdPattern.FindString(homeUrl)
 	if len(signeticOrgId) == 0 {
-		Log.Warnf("%s: Could not parse signetic org id from %s", s.Name(), homeUrl)
+		LogFor(s.Name()).Warnf("Could not parse signetic org id from %s", homeUrl)
 	}
 
 	signeticDomain := SigneticDomainPattern.FindString(homeUrl)
 	if len(signeticDomain) == 0 {
 		status = StatusPossible
-		Log.Errorf("%s: Could not parse domain from %s", s.Name(), homeUrl)
+		LogFor(s.Name()).Errorf("Could not parse domain from %s", homeUrl)
 		return
 	}
 
 	if len(signeticOrgId) > 0 {
-		Log.Debugf("%s: found signetic org id: %s, domain: %s", s.Name(), signeticOrgId, signeticDomain)
+		LogFor(s.Name()).Debugf("found signetic org id: %s, domain: %s", signeticOrgId, signeticDomain)
 		// STEP 1.5: Check if org is enabled
 
 		endpoint := new(Endpoint)
@@ -195,12 +195,12 @@ func (s *ScraperSignetic) ScrapeUrls(urls ...string) (status Status, tags TagSet
 			return
 		}
 		if apiResp.Status != SigneticStatusLive {
-			Log.Debugf("%s: org %s is not live, returning no", s.Name(), signeticOrgId)
+			LogFor(s.Name()).Debugf("org %s is not live, returning no", signeticOrgId)
 			status = StatusNo
 			return
 		}
 	} else {
-		Log.Debugf("%s: found signetic domain: %s", s.Name(), signeticDomain)
+		LogFor(s.Name()).Debugf("found signetic domain: %s", signeticDomain)
 	}
 
 	// STEP 2: Get list of locations/services from org
@@ -221,7 +221,7 @@ func (s *ScraperSignetic) ScrapeUrls(urls ...string) (status Status, tags TagSet
 
 	if SigneticNotAllowedPattern.Match(body) {
 		//if api is disabled, just return no
-		Log.Warnf("%s: clinic api returned 405 not allowed", s.Name())
+		LogFor(s.Name()).Warnf("clinic api returned 405 not allowed")
 
 		status = StatusNo
 		return
@@ -236,12 +236,12 @@ func (s *ScraperSignetic) ScrapeUrls(urls ...string) (status Status, tags TagSet
 	var locationServiceIds = make(map[string]bool)
 	for _, location := range jsonData {
 		if s.NamePattern != nil && !s.NamePattern.MatchString(location.Name) {
-			Log.Debugf("%s: name pattern did not match: %s <> %v", s.Name(), location.Name, s.NamePattern)
+			LogFor(s.Name()).Debugf("name pattern did not match: %s <> %v", location.Name, s.NamePattern)
 			continue
 		}
 
 		if location.Status != SigneticStatusLive {
-			Log.Debugf("%s: location is not live: %s: %v", s.Name(), location.Name, location.Status)
+			LogFor(s.Name()).Debugf("location is not live: %s: %v", location.Name, location.Status)
 			continue
 		}
 
@@ -266,9 +266,9 @@ func (s *ScraperSignetic) ScrapeUrls(urls ...string) (status Status, tags TagSet
 	}
 
 	if len(signeticOrgId) > 0 {
-		Log.Debugf("%s: found %d service(s) for signetic org id: %s", s.Name(), len(locationServiceIds), signeticOrgId)
+		LogFor(s.Name()).Debugf("found %d service(s) for signetic org id: %s", len(locationServiceIds), signeticOrgId)
 	} else {
-		Log.Debugf("%s: found %d location(s)", s.Name(), len(locationServiceIds))
+		LogFor(s.Name()).Debugf("found %d location(s)", len(locationServiceIds))
 	}
 
 	// STEP 3: Get timeslots for each location+service, return yes on first timeslot with availability
@@ -302,20 +302,21 @@ func (s *ScraperSignetic) ScrapeUrls(urls ...string) (status Status, tags TagSet
 			} else if resp.APIVersion == 0 && !resp.FirstDoseEligible {
 				continue
 			}
-			Log.Debugf("%s: Counting appts in slot id %s", s.Name(), resp.SlotId)
+			LogFor(s.Name()).Debugf("Counting appts in slot id %s", resp.SlotId)
 			for _, timeslot := range resp.TimeSlots {
 				if timeslot.Availability > 0 {
-					//Log.Debugf("%s: Found availability at location %s, time %s: %d", s.Name(), combinedId, timeslot.Time, timeslot.Availability)
+					//LogFor(s.Name()).Debugf("Found availability at location %s, time %s: %d", combinedId, timeslot.Time, timeslot.Availability)
 
 					totalAvailability += timeslot.Availability
 				}
 			}
 
-			Log.Debugf("%s: %d appts found so far", s.Name(), totalAvailability)
+			LogFor(s.Name()).Debugf("%d appts found so far", totalAvailability)
 		}
 	}
 
-	Log.Debugf("%s: Total availability: %d", s.Name(), totalAvailability)
+	LogFor(s.Name()).Debugf("Total availability: %d", totalAvailability)
+	ReportAppointments(s.Name(), totalAvailability)
 	if totalAvailability > 0 {
 		status = StatusLimited
 		if totalAvailability > s.LimitedThreshold {
@@ -123,19 +123,19 @@ func (s *ScraperSimplyBook) Scrape() (status Status, tags TagSet, body []byte, e
 	status = StatusUnknown
 
 	if len(s.Domain) < 1 {
-		Log.Errorf("%s: Domain not found in key or configured", s.Name())
+		LogFor(s.Name()).Errorf("Domain not found in key or configured")
 		status = StatusPossible
 		return
 	}
 
 	if len(s.Id) < 1 {
-		Log.Errorf("%s: Id not found in key or configured", s.Name())
+		LogFor(s.Name()).Errorf("Id not found in key or configured")
 		status = StatusPossible
 		return
 	}
 
 	if s.ServiceNamePattern == nil {
-		Log.Errorf("%s: %s not configured", s.Name(), SimplyBookParamKeyServiceNamePattern)
+		LogFor(s.Name()).Errorf("%s not configured", SimplyBookParamKeyServiceNamePattern)
 		status = StatusPossible
 		return
 	}
@@ -155,7 +155,7 @@ func (s *ScraperSimplyBook) Scrape() (status Status, tags TagSet, body []byte, e
 	}
 
 	if len(validServiceIds) < 1 {
-		Log.Warnf("%s: could not find any valid service ids that match name pattern '%v'", s.Name(), s.ServiceNamePattern)
+		LogFor(s.Name()).Warnf("could not find any valid service ids that match name pattern '%v'", s.ServiceNamePattern)
 		status = StatusNo
 		return
 	}
@@ -183,7 +183,8 @@ func (s *ScraperSimplyBook) Scrape() (status Status, tags TagSet, body []byte, e
 		}
 	}
 
-	Log.Debugf("%s: Availability: %d", s.Name(), totalAvail)
+	LogFor(s.Name()).Debugf("Availability: %d", totalAvail)
+	ReportAppointments(s.Name(), totalAvail)
 
 	if totalAvail <= 0 {
 		status = StatusNo
@@ -228,68 +229,68 @@ func (s *ScraperSimplyBook) GetTokenAndCookie() (token string, cookieName string
 	cacheKey := fmt.Sprintf("simplybook-tokens-%s", s.Domain)
 	cookieName = fmt.Sprintf("sess_user_publicv2_%s", s.Domain)
 
-	if cachedTokens, ok := Cache.GetOrLock(cacheKey).([]string); ok {
-		return cachedTokens[0], cookieName, cachedTokens[1], nil, nil
-	} else {
-		defer Cache.Unlock(cacheKey)
-
+	cachedTokens, _, err := Cache.GetOrCompute(cacheKey, SimplyBookCacheTTL, -1, func() (interface{}, error) {
 		endpoint := new(Endpoint)
 		endpoint.Method = "GET"
 		endpoint.Url = fmt.Sprintf(SimplyBookPageUrl, s.Domain)
 		endpoint.Cookies = make(map[string]string)
 		endpoint.CookieWhitelist = []string{"*"}
+
+		var err error
 		body, _, err = endpoint.Fetch(s.Name())
 		if err != nil {
-			return
+			return nil, err
 		}
 
-		if _, exists := endpoint.Cookies[cookieName]; !exists {
-			err = fmt.Errorf("could not get cookie '%s' from response", cookieValue)
-			return
-		} else {
-			cookieValue = endpoint.Cookies[cookieName]
+		cookieValue, exists := endpoint.Cookies[cookieName]
+		if !exists {
+			return nil, fmt.Errorf("could not get cookie '%s' from response", cookieName)
 		}
 
-		if match := SimplyBookCsrfPattern.FindStringSubmatch(string(body)); len(match) < 2 {
-			err = fmt.Errorf("could not get Csrf token from response")
-			return
-		} else {
-			token = match[1]
+		match := SimplyBookCsrfPattern.FindStringSubmatch(string(body))
+		if len(match) < 2 {
+			return nil, fmt.Errorf("could not get Csrf token from response")
 		}
 
-		Cache.Put(cacheKey, []string{token, cookieValue}, SimplyBookCacheTTL, -1)
-
+		return []string{match[1], cookieValue}, nil
+	})
+	if err != nil {
 		return
 	}
+
+	tokens := cachedTokens.([]string)
+	return tokens[0], cookieName, tokens[1], body, nil
 }
 
 func (s *ScraperSimplyBook) GetServiceIds(token string, cookieName string, cookieValue string) (validServices map[string]string, body []byte, err error) {
 	cacheKey := fmt.Sprintf("simplybook-services-%s", s.Domain)
 
-	if cachedServiceIds, ok := Cache.GetOrLock(cacheKey).(map[string]string); ok {
-		return cachedServiceIds, nil, nil
-	} else {
-		defer Cache.Unlock(cacheKey)
-
+	cachedServiceIds, _, err := Cache.GetOrCompute(cacheKey, SimplyBookCacheTTL, -1, func() (interface{}, error) {
 		url := fmt.Sprintf(SimplyBookAPIServiceUrl, s.Domain)
 		services := make([]SimplyBookService, 0)
+
+		var err error
 		body, err = s.FetchAndUnmarshal(url, token, cookieName, cookieValue, &services)
 		if err != nil {
-			return
+			return nil, err
 		}
 
-		validServices = make(map[string]string)
+		validServices := make(map[string]string)
 
 		for _, service := range services {
 			if s.ServiceNamePattern.MatchString(service.Name) {
-				Log.Debugf("%s: service id %s (%s) matched name pattern '%v'", s.Name(), service.Id, service.Name, s.ServiceNamePattern)
+				LogFor(s.Name()).Debugf("service id %s (%s) matched name pattern '%v'", service.Id, service.Name, s.ServiceNamePattern)
 				validServices[service.Id] = service.Name
 			} else {
-				Log.Debugf("%s: service id %s (%s) did not match name pattern '%v'", s.Name(), service.Id, service.Name, s.ServiceNamePattern)
+				LogFor(s.Name()).Debugf("service id %s (%s) did not match name pattern '%v'", service.Id, service.Name, s.ServiceNamePattern)
 			}
 		}
 
-		Cache.Put(cacheKey, validServices, SimplyBookCacheTTL, -1)
+		return validServices, nil
+	})
+	if err != nil {
 		return
 	}
+
+	return cachedServiceIds.(map[string]string), body, nil
 }
@@ -89,7 +89,7 @@ func (s *ScraperSolvHealth) Configure(params map[string]interface{}) error {
 
 	if namePattern != nil {
 		s.NamePattern = namePattern
-		Log.Debugf("%s: Configured name pattern: %v", s.Name(), s.NamePattern)
+		LogFor(s.Name()).Debugf("Configured name pattern: %v", s.NamePattern)
 	}
 
 	url, exists := getStringOptional(params, "url")
@@ -138,11 +138,11 @@ type SolvAPIData struct {
 
 func (s *ScraperSolvHealth) ScrapeSolvId(solvId string) (status Status, tags TagSet, body []byte, err error) {
 	if len(solvId) != 6 {
-		Log.Warnf("%s: invalid solv id: %s", s.Name(), solvId)
+		LogFor(s.Name()).Warnf("invalid solv id: %s", solvId)
 		status = StatusNo
 		return
 	} else {
-		Log.Debugf("%s: trying solv id: %s", s.Name(), solvId)
+		LogFor(s.Name()).Debugf("trying solv id: %s", solvId)
 	}
 
 	loc, _ := time.LoadLocation("America/Los_Angeles")
@@ -186,7 +186,7 @@ func (s *ScraperSolvHealth) ScrapeSolvId(solvId string) (status Status, tags Tag
 		}
 
 		if !match {
-			Log.Debugf("%s: no names matched pattern '%v', skipping...", s.Name(), s.NamePattern)
+			LogFor(s.Name()).Debugf("no names matched pattern '%v', skipping...", s.NamePattern)
 			status = StatusNo
 			return
 		}
@@ -236,14 +236,14 @@ func (s *ScraperSolvHealth) ScrapeSolvId(solvId string) (status Status, tags Tag
 
 			if availability > 0 && !busy && !is_reservations_disabled {
 				if extraDebug {
-					Log.Debugf("%s: Availability detected at %v: %d", s.Name(), apptTime, int(availability))
+					LogFor(s.Name()).Debugf("Availability detected at %v: %d", apptTime, int(availability))
 				}
 				totalAvailability += int(availability)
 			}
 		}
 	}
 
-	Log.Debugf("%s: Total availability: %d", s.Name(), totalAvailability)
+	LogFor(s.Name()).Debugf("Total availability: %d", totalAvailability)
 
 	if totalAvailability > s.LimitedThreshold {
 		status = StatusYes
@@ -14,6 +14,7 @@ type ScraperStandardHash struct {
 	ScrapeEndpoint  *Endpoint
 	UnavailableHash string
 	AvailableHash   string
+	lastStatus      Status //reused when the endpoint reports the page as not modified
 }
 
 type ScraperStandardHashFactory struct {
@@ -53,7 +54,8 @@ func (s *ScraperStandardHash) Configure(params map[string]interface{}) error {
 	}
 
 	s.AvailableHash, _ = getStringOptional(params, ParamKeyAvailableHash)
-	return nil
+
+	return applyScraperTransforms(params, s.ScrapeEndpoint)
 }
 
 func (s *ScraperStandardHash) Scrape() (status Status, tags TagSet, body []byte, err error) {
@@ -65,10 +67,15 @@ func (s *ScraperStandardHash) Scrape() (status Status, tags TagSet, body []byte,
 		return
 	}
 
+	if s.ScrapeEndpoint.NotModified && len(s.lastStatus) > 0 {
+		status = s.lastStatus
+		return
+	}
+
 	hash := sha256.Sum256(body)
 	hashString := hex.EncodeToString(hash[:])
 
-	Log.Infof("%s: Hashed: %s", s.Name(), hashString)
+	LogFor(s.Name()).Infof("Hashed: %s", hashString)
 
 	if len(s.AvailableHash) > 0 && hashString == s.AvailableHash {
 		status = StatusYes
@@ -78,5 +85,7 @@ func (s *ScraperStandardHash) Scrape() (status Status, tags TagSet, body []byte,
 		status = StatusPossible
 	}
 
+	s.lastStatus = status
+
 	return
 }
@@ -1,7 +1,6 @@
 package csg
 
 import (
-	"fmt"
 	"regexp"
 )
 
@@ -21,6 +20,7 @@ type ScraperStandardRegexp struct {
 	NumApptsPattern      *regexp.Regexp
 	NumApptsTakenPattern *regexp.Regexp
 	AvailableStatus      Status
+	lastStatus           Status //reused when the endpoint reports the page as not modified
 }
 
 type ScraperStandardRegexpFactory struct {
@@ -72,7 +72,7 @@ func (s *ScraperStandardRegexp) Configure(params map[string]interface{}) error {
 		s.AvailableStatus = Status(availableStatus)
 	}
 
-	return nil
+	return applyScraperTransforms(params, s.ScrapeEndpoint)
 }
 
 func (s *ScraperStandardRegexp) Scrape() (status Status, tags TagSet, body []byte, err error) {
@@ -83,6 +83,17 @@ func (s *ScraperStandardRegexp) Scrape() (status Status, tags TagSet, body []byt
 		return
 	}
 
+	if s.ScrapeEndpoint.NotModified && len(s.lastStatus) > 0 {
+		status = s.lastStatus
+		return
+	}
+
+	defer func() {
+		if err == nil {
+			s.lastStatus = status
+		}
+	}()
+
 	if s.AvailablePattern != nil && s.AvailablePattern.Match(body) {
 		if s.LimitedThreshold > 0 && s.NumApptsPattern != nil {
 			totalAppointments := GetRegexCount(s.Name(), s.NumApptsPattern, body)
@@ -91,7 +102,7 @@ func (s *ScraperStandardRegexp) Scrape() (status Status, tags TagSet, body []byt
 				totalAppointments -= GetRegexCount(s.Name(), s.NumApptsTakenPattern, body)
 			}
 
-			Log.Debugf("%s: total appointments: %d", s.Name(), totalAppointments)
+			LogFor(s.Name()).Debugf("total appointments: %d", totalAppointments)
 
 			if totalAppointments <= s.LimitedThreshold {
 				status = StatusLimited
@@ -105,7 +116,7 @@ func (s *ScraperStandardRegexp) Scrape() (status Status, tags TagSet, body []byt
 		status = StatusNo
 	} else if s.ErrorPattern != nil && s.ErrorPattern.Match(body) {
 		status = StatusUnknown
-		err = fmt.Errorf("Error pattern matched")
+		err = NewPatternError(s.ErrorPattern)
 	} else {
 		status = StatusPossible
 	}
@@ -144,7 +144,7 @@ func (s *ScraperSwitch) Scrape() (status Status, tags TagSet, body []byte, err e
 	}
 
 	if status == StatusUnknown {
-		Log.Warnf("%s: No patterns matched, returning default status", s.Name())
+		LogFor(s.Name()).Warnf("No patterns matched, returning default status")
 		status = s.DefaultStatus
 	}
 
@@ -173,7 +173,7 @@ func (s *ScraperSwitch) ScrapeRecursive(url string, depth int, crawled map[strin
 
 	for idx, item := range s.List {
 		if item.Pattern.Match(body) {
-			Log.Debugf("%s: Matched pattern on switch index %d (%s)", s.Name(), idx, item.Scraper.Type())
+			LogFor(s.Name()).Debugf("Matched pattern on switch index %d (%s)", idx, item.Scraper.Type())
 
 			var scrapedTags TagSet
 
@@ -181,7 +181,7 @@ func (s *ScraperSwitch) ScrapeRecursive(url string, depth int, crawled map[strin
 				if urlScraper, ok := item.Scraper.(UrlScraper); ok {
 					status, scrapedTags, body, err = urlScraper.ScrapeUrls(url)
 				} else {
-					Log.Errorf("%s: Scraper type %s does not support auto_url", s.Name(), item.Scraper.Type())
+					LogFor(s.Name()).Errorf("Scraper type %s does not support auto_url", item.Scraper.Type())
 					continue
 				}
 			} else {
@@ -191,12 +191,12 @@ func (s *ScraperSwitch) ScrapeRecursive(url string, depth int, crawled map[strin
 			tags = tags.Merge(scrapedTags)
 
 			if err != nil {
-				Log.Errorf("%s: %v", s.Name(), err)
+				LogFor(s.Name()).Errorf("%v", err)
 				dumpOutput(s.Name(), "", body)
 				err = nil
 			}
 
-			Log.Debugf("%s: Scraper type %s returned %s", s.Name(), item.Scraper.Type(), status)
+			LogFor(s.Name()).Debugf("Scraper type %s returned %s", item.Scraper.Type(), status)
 
 			if status == StatusYes {
 				return
@@ -218,7 +218,7 @@ func (s *ScraperSwitch) ScrapeRecursive(url string, depth int, crawled map[strin
 				continue
 			}
 			if crawl {
-				Log.Debugf("%s: Crawl (%d): %s", s.Name(), depth, nextUrl)
+				LogFor(s.Name()).Debugf("Crawl (%d): %s", depth, nextUrl)
 				var scrapedTags TagSet
 				status, scrapedTags, body, err = s.ScrapeRecursive(nextUrl, depth+1, crawled)
 				tags = tags.Merge(scrapedTags)
@@ -230,7 +230,7 @@ func (s *ScraperSwitch) ScrapeRecursive(url string, depth int, crawled map[strin
 				}
 			} else {
 				crawled[nextUrl] = true
-				Log.Debugf("%s: Ignore (%d): %s", s.Name(), depth, nextUrl)
+				LogFor(s.Name()).Debugf("Ignore (%d): %s", depth, nextUrl)
 			}
 		}
 	}
@@ -252,7 +252,7 @@ func (s *ScraperSwitch) GetCrawlUrls(url string, body []byte) (map[string]bool,
 	host := hostMatch[1]
 	protocol := ProtocolPattern.FindString(url)
 	if len(protocol) == 0 {
-		Log.Warnf("%s: Could not parse protocol from url: %s", s.Name(), url)
+		LogFor(s.Name()).Warnf("Could not parse protocol from url: %s", url)
 		protocol = "http://"
 	}
 
@@ -273,7 +273,7 @@ func (s *ScraperSwitch) GetCrawlUrls(url string, body []byte) (map[string]bool,
 		if !s.CrawlExternal {
 			hostMatch := HostPattern.FindStringSubmatch(nextUrl)
 			if len(hostMatch) != 2 {
-				Log.Errorf("%s: Could not parse host from url: %s", s.Name(), nextUrl)
+				LogFor(s.Name()).Errorf("Could not parse host from url: %s", nextUrl)
 				urls[nextUrl] = false
 				continue
 			}
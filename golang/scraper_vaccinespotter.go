@@ -107,9 +107,14 @@ type VSAPIResp struct {
 }
 
 type VSFeature struct {
+	Geometry   *VSGeometry    `json:"geometry"`
 	Properties VSFeatureProps `json:"properties"`
 }
 
+type VSGeometry struct {
+	Coordinates []float64 `json:"coordinates"` //longitude, latitude
+}
+
 type VSFeatureProps struct {
 	Provider     string          `json:"provider"`
 	LocationId   string          `json:"provider_location_id"`
@@ -132,51 +137,58 @@ func (s *ScraperVaccineSpotter) Scrape() (status Status, tags TagSet, body []byt
 	}
 
 	if len(s.ProviderName) < 1 {
-		Log.Errorf("%s: Provider Name not found in key or configured", s.Name())
+		LogFor(s.Name()).Errorf("Provider Name not found in key or configured")
 		status = StatusPossible
 		return
 	}
 
 	if len(s.LocationId) < 1 {
-		Log.Errorf("%s: Location Id not found in key or configured", s.Name())
+		LogFor(s.Name()).Errorf("Location Id not found in key or configured")
 		status = StatusPossible
 		return
 	}
 
 	const cacheKey = "VaccineSpotterParsedJSON"
-	var apiResp *VSAPIResp
-
-	if apiResp, _ = Cache.GetOrLock(cacheKey).(*VSAPIResp); apiResp == nil {
-		defer Cache.Unlock(cacheKey)
 
+	cachedResp, _, err := Cache.GetOrCompute(cacheKey, VaccineSpotterCacheTTL, -1, func() (interface{}, error) {
+		var err error
 		body, _, err = s.Endpoint.Fetch(s.Name())
 		if err != nil {
-			return
+			return nil, err
 		}
 
-		apiResp = new(VSAPIResp)
+		apiResp := new(VSAPIResp)
 
 		err = json.Unmarshal(body, apiResp)
 		if err != nil {
-			return
+			return nil, err
 		}
-		Cache.Put(cacheKey, apiResp, VaccineSpotterCacheTTL, -1)
+		return apiResp, nil
+	})
+	if err != nil {
+		return
 	}
+	apiResp := cachedResp.(*VSAPIResp)
 
 	for _, location := range apiResp.Features {
 		if location.Properties.Provider == s.ProviderName && location.Properties.LocationId == s.LocationId {
 			lastFetched, parseErr := time.Parse(VaccineSpotterTimePattern, location.Properties.LastFetched)
 			if parseErr != nil {
-				Log.Errorf("%s: %v", s.Name(), parseErr)
+				LogFor(s.Name()).Errorf("%v", parseErr)
 			} else {
 				dataAge := time.Since(lastFetched).Seconds()
 				if dataAge > VaccineSpotterMinDataAge {
-					Log.Warnf("%s: maximum data age exceeed: %f > %d", s.Name(), dataAge, VaccineSpotterMinDataAge)
+					LogFor(s.Name()).Warnf("maximum data age exceeed: %f > %d", dataAge, VaccineSpotterMinDataAge)
 					status = StatusApiSkip
 					return
 				} else {
 					appts := len(location.Properties.Appointments)
-					Log.Debugf("%s: number of appts: %d, age: %fs", s.Name(), appts, dataAge)
+					LogFor(s.Name()).Debugf("number of appts: %d, age: %fs", appts, dataAge)
+					ReportAppointments(s.Name(), appts)
+					ReportDataTime(s.Name(), lastFetched)
+					if location.Geometry != nil && len(location.Geometry.Coordinates) == 2 {
+						ReportCoordinates(s.Name(), GeoCoord{Lat: location.Geometry.Coordinates[1], Lng: location.Geometry.Coordinates[0]})
+					}
 					if appts > s.LimitedThreshold {
 						status = StatusYes
 					} else if appts > 0 {
@@ -186,6 +198,9 @@ func (s *ScraperVaccineSpotter) Scrape() (status Status, tags TagSet, body []byt
 					}
 
 					for _, appt := range location.Properties.Appointments {
+						if apptTime, err := time.Parse(VaccineSpotterTimePattern, appt.Time); err == nil {
+							ReportSlot(s.Name(), apptTime)
+						}
 						for _, vaccineType := range appt.VaccineTypes {
 							tags = tags.ParseAndAddVaccineType(vaccineType)
 						}
@@ -115,20 +115,13 @@ func (cg *WalgreensFetcher) Fetch(lat float64, lng float64, radius int) ([]byte,
 		Value: "",
 	}
 
-	var cachedData *WalgreensFetcherCacheData
-	var err error
-	var ok bool
-
 	name := fmt.Sprintf("WalgreensFetcher (%.5f, %.5f, %d)", lat, lng, radius)
 
-	if cachedData, ok = Cache.GetOrLock(cg.epCacheKey).(*WalgreensFetcherCacheData); ok {
-		Log.Debugf("Endpoint (cached) uses left: %d", Cache.UsesLeft(cg.epCacheKey))
-		userAgent.Value = cachedData.SensorData.UserAgent
-	} else {
-		defer Cache.Unlock(cg.epCacheKey)
+	cachedValue, cached, err := Cache.GetOrCompute(cg.epCacheKey, WalgreensCacheTTL, WalgreensEndpointReuseMax, func() (interface{}, error) {
 		Log.Debugf("Endpoint (new) uses left: %d", WalgreensEndpointReuseMax)
 
-		cachedData = new(WalgreensFetcherCacheData)
+		var err error
+		cachedData := new(WalgreensFetcherCacheData)
 		cachedData.SensorData, err = cg.SensorData.GetSensorData()
 		if err != nil {
 			return nil, err
@@ -142,8 +135,6 @@ func (cg *WalgreensFetcher) Fetch(lat float64, lng float64, radius int) ([]byte,
 			cachedData.Endpoint.HttpClient = cachedData.ProxyEndpoint.GetHttpClient()
 		}
 
-		Cache.Put(cg.epCacheKey, cachedData, WalgreensCacheTTL, WalgreensEndpointReuseMax)
-
 		userAgent.Value = cachedData.SensorData.UserAgent
 
 		// CALL 1: LOGIN PAGE
@@ -251,6 +242,17 @@ func (cg *WalgreensFetcher) Fetch(lat float64, lng float64, radius int) ([]byte,
 		}
 
 		Log.Debugf("JWT=%s", endpoint.Cookies["jwt"])
+
+		return cachedData, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	cachedData := cachedValue.(*WalgreensFetcherCacheData)
+	if cached {
+		Log.Debugf("Endpoint (cached) uses left: %d", Cache.UsesLeft(cg.epCacheKey))
+		userAgent.Value = cachedData.SensorData.UserAgent
 	}
 
 	endpoint := cachedData.Endpoint
@@ -394,7 +396,7 @@ func (s *ScraperWalgreens) Scrape() (status Status, tags TagSet, body []byte, er
 
 	if len(apiResp.Locations) >= WalgreensMaxLocations {
 		if len(apiResp.Locations) > WalgreensMaxLocations {
-			Log.Errorf("%s: Walgreens API returned more than the expected number of locations: %d > %d", s.Name(), len(apiResp.Locations), WalgreensMaxLocations)
+			LogFor(s.Name()).Errorf("Walgreens API returned more than the expected number of locations: %d > %d", len(apiResp.Locations), WalgreensMaxLocations)
 		}
 
 		_, body, err = s.ScrapeCoord(s.FineLoc, 1)
@@ -442,31 +444,32 @@ type WalgreensManufacturer struct {
 }
 
 func (s *ScraperWalgreens) ScrapeCoord(coord GeoCoord, radius int) (apiResp *WalgreensAPIResp, body []byte, err error) {
-	var ok bool
 	cacheKey := fmt.Sprintf("walgreens-%s-%d", coord.String(), radius)
-	apiResp, ok = Cache.GetOrLock(cacheKey).(*WalgreensAPIResp)
-
-	if !ok || apiResp == nil {
-		defer Cache.Unlock(cacheKey)
 
+	cachedResp, _, err := Cache.GetOrCompute(cacheKey, WalgreensCacheTTL, -1, func() (interface{}, error) {
+		var err error
 		body, err = s.Fetcher.Fetch(coord.Lat, coord.Lng, radius)
 		if err != nil {
 			s.Fetcher.reportProxyError()
-			return nil, body, err
+			return nil, err
 		}
 
-		apiResp = new(WalgreensAPIResp)
+		apiResp := new(WalgreensAPIResp)
 		err = json.Unmarshal(body, apiResp)
 		if err != nil {
-			return nil, body, err
+			return nil, err
 		}
 
 		if len(apiResp.Locations) < 1 {
 			apiResp.ErrorBody = string(body)
 		}
 
-		Cache.Put(cacheKey, apiResp, WalgreensCacheTTL, -1)
+		return apiResp, nil
+	})
+	if err != nil {
+		return nil, body, err
 	}
+	apiResp = cachedResp.(*WalgreensAPIResp)
 
 	if len(apiResp.Locations) < 1 {
 		if !WalgreensNoDataPattern.MatchString(apiResp.ErrorBody) {
@@ -498,22 +501,19 @@ func (s *ScraperWalgreens) ScrapeCoord(coord GeoCoord, radius int) (apiResp *Wal
 
 func (s *ScraperWalgreens) SetAvailable(store string, countAndTags CountAndTagSet) {
 	cacheKey := fmt.Sprintf("walgreensStore|%s", store)
-	available := Cache.GetOrLock(cacheKey)
-	if available == nil {
-		defer Cache.Unlock(cacheKey)
-		Cache.Put(cacheKey, countAndTags, WalgreensCacheTTL, -1)
-	}
+	//keep the first value reported within the TTL
+	Cache.GetOrCompute(cacheKey, WalgreensCacheTTL, -1, func() (interface{}, error) {
+		return countAndTags, nil
+	})
 }
 
 func (s *ScraperWalgreens) GetAvailable(store string) CountAndTagSet {
 	cacheKey := fmt.Sprintf("walgreensStore|%s", store)
-	available := Cache.GetOrLock(cacheKey)
-	if available == nil {
-		defer Cache.Unlock(cacheKey)
+	if available, ok := Cache.Get(cacheKey); ok {
+		return available.(CountAndTagSet)
+	} else {
 		return CountAndTagSet{
 			Count: 0,
 		}
-	} else {
-		return available.(CountAndTagSet)
 	}
 }
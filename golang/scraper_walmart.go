@@ -124,25 +124,22 @@ func (s *ScraperWalmart) Scrape() (status Status, tags TagSet, body []byte, err
 	status = StatusUnknown
 
 	cacheKey := fmt.Sprintf("walmart-%s", s.StoreNumber)
-	statusCached := Cache.GetOrLock(cacheKey)
-	if statusCached != nil {
+	if statusCached, ok := Cache.Get(cacheKey); ok {
 		statusAndTags := statusCached.(StatusAndTagSet)
 		status = statusAndTags.Status
 		tags = statusAndTags.TagSet
 		return
-	} else {
-		Cache.Unlock(cacheKey)
 	}
 
 	if len(s.Zipcode) < 1 {
 		status = StatusPossible
-		Log.Errorf("%s: zipcode not configured", s.Name())
+		LogFor(s.Name()).Errorf("zipcode not configured")
 		return
 	}
 
 	if s.ProxyProvider == nil {
 		status = StatusPossible
-		Log.Errorf("%s: proxy not configured", s.Name())
+		LogFor(s.Name()).Errorf("proxy not configured")
 		return
 	}
 
@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"net/url"
 	"regexp"
-	"strings"
 	"time"
 )
 
@@ -156,17 +155,17 @@ func (s *ScraperWpSsa) ScrapeUrls(urls ...string) (status Status, tags TagSet, b
 					time.Sleep(time.Second)
 					continue
 				}
-				Log.Errorf("%s: Could not circumvent anti-bot", s.Name())
+				LogFor(s.Name()).Errorf("Could not circumvent anti-bot")
 			}
 
 			return
 		} else {
-			Log.Debugf("%s: successful after %d retries", s.Name(), retries)
+			LogFor(s.Name()).Debugf("successful after %d retries", retries)
 			break
 		}
 	}
 
-	embedUrl = strings.ReplaceAll(embedUrl, "&#038;", "&")
+	embedUrl = string(ApplyTransforms([]string{TransformHtmlUnescape}, []byte(embedUrl), nil))
 
 	apptTypesJsonStr := ""
 	for retries := 0; ; retries++ {
@@ -179,12 +178,12 @@ func (s *ScraperWpSsa) ScrapeUrls(urls ...string) (status Status, tags TagSet, b
 					time.Sleep(time.Second)
 					continue
 				}
-				Log.Errorf("%s: Could not circumvent anti-bot", s.Name())
+				LogFor(s.Name()).Errorf("Could not circumvent anti-bot")
 			}
 
 			return
 		} else {
-			Log.Debugf("%s: successful after %d retries", s.Name(), retries)
+			LogFor(s.Name()).Debugf("successful after %d retries", retries)
 			break
 		}
 	}
@@ -238,7 +237,7 @@ func (s *ScraperWpSsa) ScrapeUrls(urls ...string) (status Status, tags TagSet, b
 		}
 
 		if now.Before(bookingStart) || now.After(bookingEnd) {
-			Log.Infof("%s: outside booking window: %v - %v", s.Name(), bookingStart, bookingEnd)
+			LogFor(s.Name()).Infof("outside booking window: %v - %v", bookingStart, bookingEnd)
 			status = StatusNo
 			return
 		}
@@ -259,9 +258,9 @@ func (s *ScraperWpSsa) ScrapeUrls(urls ...string) (status Status, tags TagSet, b
 					time.Sleep(time.Second)
 					continue
 				}
-				Log.Errorf("%s: Could not circumvent anti-bot", s.Name())
+				LogFor(s.Name()).Errorf("Could not circumvent anti-bot")
 			} else {
-				Log.Debugf("%s: successful after %d retries", s.Name(), retries)
+				LogFor(s.Name()).Debugf("successful after %d retries", retries)
 				break
 			}
 		}
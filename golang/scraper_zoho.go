@@ -113,11 +113,11 @@ func (s *ScraperZoho) ScrapeUrls(urls ...string) (status Status, tags TagSet, bo
 	}
 
 	if len(ctx.WorkspaceIds) == 0 {
-		Log.Debugf("%s: No active workspaces found", s.Name())
+		LogFor(s.Name()).Debugf("No active workspaces found")
 		status = StatusNo
 		return
 	} else {
-		Log.Debugf("%s: Fetching availability for %d workspace(s) found", s.Name(), len(ctx.WorkspaceIds))
+		LogFor(s.Name()).Debugf("Fetching availability for %d workspace(s) found", len(ctx.WorkspaceIds))
 	}
 
 	body, err = s.GetApptPrefs(ctx)
@@ -265,7 +265,7 @@ func (s *ScraperZoho) GetArguments(urls ...string) (ctx *ZohoScrapeContext, body
 		},
 	}
 
-	Log.Debugf("%s: domain: %s, args-id: %s, owner: %s, csrfName: %s, csrfValue: %s", s.Name(), ctx.Domain, ctx.ArgsId, ctx.Owner, ctx.CsrfName, ctx.CsrfValue)
+	LogFor(s.Name()).Debugf("domain: %s, args-id: %s, owner: %s, csrfName: %s, csrfValue: %s", ctx.Domain, ctx.ArgsId, ctx.Owner, ctx.CsrfName, ctx.CsrfValue)
 	return
 }
 
@@ -343,7 +343,7 @@ func (s *ScraperZoho) GetIds(ctx *ZohoScrapeContext) (body []byte, err error) {
 
 	for _, record := range svcResp.Data {
 		if record.ServiceId.LinkRecId != record.ServiceId.Value {
-			Log.Warnf("%s: service id: linkrecid != value: %s != %s", s.Name(), record.ServiceId.LinkRecId, record.ServiceId.Value)
+			LogFor(s.Name()).Warnf("service id: linkrecid != value: %s != %s", record.ServiceId.LinkRecId, record.ServiceId.Value)
 		}
 
 		if record.ServiceStatus != "ACTIVE" {
@@ -357,23 +357,23 @@ func (s *ScraperZoho) GetIds(ctx *ZohoScrapeContext) (body []byte, err error) {
 		if len(record.WorkspaceId) > 0 {
 			ctx.WorkspaceIds[record.WorkspaceId] = record.ServiceId.Value
 		} else {
-			Log.Warnf("%s: workspace id for record %s is blank", s.Name(), record.Id)
+			LogFor(s.Name()).Warnf("workspace id for record %s is blank", record.Id)
 			continue
 		}
 
 		if record.StaffId.LinkRecId != record.StaffId.Value {
-			Log.Warnf("%s: staff id: linkrecid != value: %s != %s", s.Name(), record.StaffId.LinkRecId, record.StaffId.Value)
+			LogFor(s.Name()).Warnf("staff id: linkrecid != value: %s != %s", record.StaffId.LinkRecId, record.StaffId.Value)
 		}
 
 		ctx.ScheduleIds[record.StaffId.Value] = record.WorkspaceId
 	}
 
 	if len(badServiceIds) > 0 {
-		Log.Warnf("%s: service ids hidden or inactive: %v", s.Name(), badServiceIds)
+		LogFor(s.Name()).Warnf("service ids hidden or inactive: %v", badServiceIds)
 	}
 
-	Log.Debugf("%s: Business Id: %s, Service Ids: %v, Workspace Ids: [%s], Schedule Ids: [%s]",
-		s.Name(), ctx.BusinessId, ctx.ServiceIds, mapKeysToStringList(ctx.WorkspaceIds, " "), mapKeysToStringList(ctx.ScheduleIds, " "))
+	LogFor(s.Name()).Debugf("Business Id: %s, Service Ids: %v, Workspace Ids: [%s], Schedule Ids: [%s]",
+		ctx.BusinessId, ctx.ServiceIds, mapKeysToStringList(ctx.WorkspaceIds, " "), mapKeysToStringList(ctx.ScheduleIds, " "))
 
 	return
 }
@@ -441,17 +441,17 @@ func (s *ScraperZoho) GetApptPrefs(ctx *ZohoScrapeContext) (body []byte, err err
 
 			apptPrefs = ctx.WorkspaceApptPrefs[record.SettingId]
 		} else {
-			Log.Warnf("%s: unknown setting model type: %s", s.Name(), record.ModelType)
+			LogFor(s.Name()).Warnf("unknown setting model type: %s", record.ModelType)
 			continue
 		}
 
 		if apptPrefs == nil {
-			Log.Warnf("%s: could not resolve %s id: %s", s.Name(), record.ModelType, record.SettingId)
+			LogFor(s.Name()).Warnf("could not resolve %s id: %s", record.ModelType, record.SettingId)
 			continue
 		}
 
 		if record.SettingType == "BOOKING_PREFERENCE" {
-			Log.Debugf("%s: Found booking preference for %s id: %s", s.Name(), record.ModelType, record.SettingId)
+			LogFor(s.Name()).Debugf("Found booking preference for %s id: %s", record.ModelType, record.SettingId)
 			bookingPref := new(ZohoBookingPref)
 			err = json.Unmarshal([]byte(record.Value), bookingPref)
 			if err != nil {
@@ -460,7 +460,7 @@ func (s *ScraperZoho) GetApptPrefs(ctx *ZohoScrapeContext) (body []byte, err err
 				apptPrefs.Duration = bookingPref.BookingInterval
 			}
 		} else if record.SettingType == "SCHEDULING_POLICY" {
-			Log.Debugf("%s: Found scheduling policy for %s id: %s", s.Name(), record.ModelType, record.SettingId)
+			LogFor(s.Name()).Debugf("Found scheduling policy for %s id: %s", record.ModelType, record.SettingId)
 			schedulingPolicy := new(ZohoSchedulePolicy)
 			err = json.Unmarshal([]byte(record.Value), schedulingPolicy)
 			if err != nil {
@@ -532,7 +532,7 @@ func (s *ScraperZoho) GetAvailability(ctx *ZohoScrapeContext) (available bool, b
 		apptPrefs := ctx.GetApptPrefs(workspaceId)
 
 		if apptPrefs == nil {
-			Log.Warnf("%s: Invalid appointment preferences: %s", s.Name(), workspaceId)
+			LogFor(s.Name()).Warnf("Invalid appointment preferences: %s", workspaceId)
 			continue
 		}
 
@@ -568,12 +568,12 @@ func (s *ScraperZoho) GetAvailability(ctx *ZohoScrapeContext) (available bool, b
 
 			start, parseErr := time.ParseInLocation(ZohoTimeFormat, blackoutRecord.From, s.TimeZone)
 			if parseErr != nil {
-				Log.Errorf("%s: %v", s.Name(), parseErr)
+				LogFor(s.Name()).Errorf("%v", parseErr)
 			}
 
 			end, parseErr := time.ParseInLocation(ZohoTimeFormat, blackoutRecord.To, s.TimeZone)
 			if parseErr != nil {
-				Log.Errorf("%s: %v", s.Name(), parseErr)
+				LogFor(s.Name()).Errorf("%v", parseErr)
 			}
 
 			blackoutPeriods[blackoutRecord.ScheduleId] = append(blackoutPeriods[blackoutRecord.ScheduleId], ZohoPeriod{
@@ -602,7 +602,7 @@ func (s *ScraperZoho) GetAvailability(ctx *ZohoScrapeContext) (available bool, b
 			var slotConf *ZohoSlotConf
 			var apptsList []*ZohoAppointments
 
-			Log.Debugf("%s: checking schedule id %s", s.Name(), scheduleId)
+			LogFor(s.Name()).Debugf("checking schedule id %s", scheduleId)
 
 			for _, record := range scheduleResp.Data {
 				if record.ScheduleId == scheduleId {
@@ -619,28 +619,28 @@ func (s *ScraperZoho) GetAvailability(ctx *ZohoScrapeContext) (available bool, b
 							apptsList = append(apptsList, appts)
 						}
 					} else {
-						Log.Warnf("%s: Unknown record type for schedule id %s: %s", s.Name(), scheduleId, record.ModelType)
+						LogFor(s.Name()).Warnf("Unknown record type for schedule id %s: %s", scheduleId, record.ModelType)
 					}
 				}
 			}
 
 			if len(apptsList) == 0 {
-				Log.Debugf("%s: No appointment data for schedule id %s", s.Name(), scheduleId)
+				LogFor(s.Name()).Debugf("No appointment data for schedule id %s", scheduleId)
 				continue
 			}
 
 			if slotConf == nil {
-				Log.Errorf("%s: schedule id %s has appointments but no hours configuration", s.Name(), scheduleId)
+				LogFor(s.Name()).Errorf("schedule id %s has appointments but no hours configuration", scheduleId)
 				continue
 			}
 
 			if firstAvail := GetFirstAvailability(slotConf, apptsList, apptPrefs.Duration, startTime, blackoutPeriods[scheduleId]); !firstAvail.IsZero() && firstAvail.Before(endTime) {
 				available = true
-				Log.Debugf("%s: Availability found in schedule id %s: %v", s.Name(), scheduleId, firstAvail)
+				LogFor(s.Name()).Debugf("Availability found in schedule id %s: %v", scheduleId, firstAvail)
 				return
 			}
 
-			Log.Debugf("%s: No availability found in schedule id %s", s.Name(), scheduleId)
+			LogFor(s.Name()).Debugf("No availability found in schedule id %s", scheduleId)
 		}
 	}
 
@@ -0,0 +1,219 @@
+package csg
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+//read-only http api for the continuous runner, served on status_addr:
+//  /healthz          scheduler liveness and stale scrapers
+//  /scrapers         last known state of every scraper
+//  /scrapers/{name}  last known state of one scraper, including the last dump and fetch trace
+//  /scrapers/{name}/traces  recent fetch traces of one scraper, newest first
+
+const StatusApiHealthzPath = "/healthz"
+const StatusApiScrapersPath = "/scrapers"
+const StatusApiTracesSuffix = "/traces"
+const StatusApiMaxLoopAge = 60 //seconds since the last scheduler loop before /healthz reports unhealthy
+
+var lastLoopTime int64
+
+type ScraperStatusReport struct {
+	Name           string       `json:"name"`
+	Type           string       `json:"type"`
+	ApiKey         string       `json:"api_key"`
+	ScrapeId       string       `json:"scrape_id,omitempty"` //id of the last scrape, matches log lines, traces and dumps
+	Status         Status       `json:"status"`              //result of the last scrape
+	ReportedStatus Status       `json:"reported_status"`     //last status sent to the api
+	Tags           []string     `json:"tags"`
+	LastScrapeTime string       `json:"last_scrape_time,omitempty"`
+	LastChangeTime string       `json:"last_change_time,omitempty"`
+	LastSuccess    string       `json:"last_success_time,omitempty"`
+	Stale          bool         `json:"stale"` //overdue for a successful scrape, see Watchdog
+	NextDueTime    string       `json:"next_due_time,omitempty"`
+	ErrorStreak    int          `json:"error_streak"`
+	LastError      string       `json:"last_error,omitempty"`
+	LastErrorClass string       `json:"last_error_class,omitempty"`
+	LastDumpPath   string       `json:"last_dump_path,omitempty"`
+	LastDumpUrl    string       `json:"last_dump_url,omitempty"`
+	LastTrace      *ScrapeTrace `json:"last_trace,omitempty"`
+}
+
+type HealthReport struct {
+	Healthy       bool     `json:"healthy"`
+	LastLoopTime  string   `json:"last_loop_time,omitempty"`
+	LastLoopAge   int64    `json:"last_loop_age"`
+	Scrapers      int      `json:"scrapers"`
+	StaleScrapers []string `json:"stale_scrapers"`
+}
+
+type StatusApi struct {
+	tracker  *ChangeTracker
+	contexts map[string]*ScrapeAndSendContext
+	names    []string
+	watchdog *Watchdog
+}
+
+func NewStatusApi(tracker *ChangeTracker, scrapeContexts []*ScrapeAndSendContext) *StatusApi {
+	api := new(StatusApi)
+	api.tracker = tracker
+	api.contexts = make(map[string]*ScrapeAndSendContext)
+	api.names = make([]string, 0, len(scrapeContexts))
+
+	for _, ctx := range scrapeContexts {
+		api.contexts[ctx.Name] = ctx
+		api.names = append(api.names, ctx.Name)
+	}
+	sort.Strings(api.names)
+
+	return api
+}
+
+// called by the scheduler on every loop
+func markSchedulerLoop() {
+	atomic.StoreInt64(&lastLoopTime, time.Now().Unix())
+}
+
+func formatStatusTime(timestamp int64) string {
+	if timestamp <= 0 {
+		return ""
+	}
+	return time.Unix(timestamp, 0).Format(time.RFC3339)
+}
+
+// reports stale scrapers from the watchdog, if set
+func (api *StatusApi) SetWatchdog(watchdog *Watchdog) {
+	api.watchdog = watchdog
+}
+
+func (api *StatusApi) Register(addr string) {
+	handleHttp(addr, StatusApiHealthzPath, http.HandlerFunc(api.handleHealthz))
+	handleHttp(addr, StatusApiScrapersPath, http.HandlerFunc(api.handleScrapers))
+	handleHttp(addr, StatusApiScrapersPath+"/", http.HandlerFunc(api.handleScraper))
+}
+
+func (api *StatusApi) Report(name string, detailed bool) (ScraperStatusReport, bool) {
+	var report ScraperStatusReport
+
+	ctx, exists := api.contexts[name]
+	if !exists {
+		return report, false
+	}
+
+	snapshot := ctx.Snapshot()
+	report.Name = snapshot.Name
+	report.Type = snapshot.Scraper.Type()
+	report.ApiKey = snapshot.Config.ApiKey
+	report.Status = snapshot.Status
+	report.ScrapeId = snapshot.ScrapeId
+	report.Tags = snapshot.Tags
+	if report.Tags == nil {
+		report.Tags = []string{}
+	}
+
+	if state, exists := api.tracker.State(name); exists {
+		report.ReportedStatus = state.Status
+		report.LastScrapeTime = formatStatusTime(state.LastScrapeTime)
+		report.LastChangeTime = formatStatusTime(state.LastChangeTime)
+		report.LastSuccess = formatStatusTime(state.LastSuccess)
+		report.ErrorStreak = state.ErrorCount
+		if state.LastError != nil {
+			report.LastError = state.LastError.Error()
+			report.LastErrorClass = ErrorClass(state.LastError)
+		}
+
+		nextDue := state.LastScrapeTime + snapshot.MinInterval()
+		if state.LastScrapeTime == 0 || nextDue < time.Now().Unix() {
+			nextDue = time.Now().Unix()
+		}
+		report.NextDueTime = formatStatusTime(nextDue)
+	}
+
+	if api.watchdog != nil {
+		report.Stale = api.watchdog.IsStale(name)
+	}
+
+	if detailed {
+		report.LastDumpPath = snapshot.LastDumpPath
+		report.LastDumpUrl = snapshot.LastDumpUrl
+		report.LastTrace = snapshot.LastTrace
+	}
+
+	return report, true
+}
+
+func writeJson(w http.ResponseWriter, statusCode int, v interface{}) {
+	jsonBytes, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	_, _ = w.Write(jsonBytes)
+}
+
+func (api *StatusApi) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	var report HealthReport
+	report.Scrapers = len(api.names)
+	report.StaleScrapers = []string{}
+	if api.watchdog != nil {
+		report.StaleScrapers = api.watchdog.Stale()
+	}
+
+	loopTime := atomic.LoadInt64(&lastLoopTime)
+	if loopTime > 0 {
+		report.LastLoopTime = formatStatusTime(loopTime)
+		report.LastLoopAge = time.Now().Unix() - loopTime
+		report.Healthy = report.LastLoopAge <= StatusApiMaxLoopAge
+	}
+
+	statusCode := http.StatusOK
+	if !report.Healthy {
+		statusCode = http.StatusServiceUnavailable
+	}
+
+	writeJson(w, statusCode, report)
+}
+
+func (api *StatusApi) handleScrapers(w http.ResponseWriter, r *http.Request) {
+	reports := make([]ScraperStatusReport, 0, len(api.names))
+	for _, name := range api.names {
+		if report, exists := api.Report(name, false); exists {
+			reports = append(reports, report)
+		}
+	}
+
+	writeJson(w, http.StatusOK, reports)
+}
+
+func (api *StatusApi) handleScraper(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, StatusApiScrapersPath+"/")
+
+	if strings.HasSuffix(name, StatusApiTracesSuffix) {
+		api.handleTraces(w, strings.TrimSuffix(name, StatusApiTracesSuffix))
+		return
+	}
+
+	report, exists := api.Report(name, true)
+	if !exists {
+		writeJson(w, http.StatusNotFound, map[string]string{"error": "scraper not found: " + name})
+		return
+	}
+
+	writeJson(w, http.StatusOK, report)
+}
+
+func (api *StatusApi) handleTraces(w http.ResponseWriter, name string) {
+	if _, exists := api.contexts[name]; !exists {
+		writeJson(w, http.StatusNotFound, map[string]string{"error": "scraper not found: " + name})
+		return
+	}
+
+	writeJson(w, http.StatusOK, TraceHistory(name))
+}
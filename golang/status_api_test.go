@@ -0,0 +1,85 @@
+package csg
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type statusApiTestScraper struct{}
+
+func (s *statusApiTestScraper) Type() string {
+	return "test"
+}
+
+func (s *statusApiTestScraper) Name() string {
+	return "status_api_test"
+}
+
+func (s *statusApiTestScraper) Configure(params map[string]interface{}) error {
+	return nil
+}
+
+func (s *statusApiTestScraper) Scrape() (status Status, tags TagSet, body []byte, err error) {
+	return StatusYes, TagSet{}.Add(TagModerna), nil, nil
+}
+
+// swaps in testConfig with what doScrapeAndSend needs to send in test mode, defer the returned func to restore it
+func useStatusApiTestConfig(testConfig *Config) func() {
+	testConfig.ApiInterval = 180
+	testConfig.ErrorWarningThreshold = 1
+	testConfig.TestMode = true
+
+	prevConfig := config
+	config = testConfig
+	return func() { config = prevConfig }
+}
+
+// a context for the status_api_test scraper and a tracker that knows it
+func newStatusApiTestContext(scraperConfig *ScraperConfig) (*ScrapeAndSendContext, *ChangeTracker) {
+	scraper := new(statusApiTestScraper)
+	scraperConfig.Type = scraper.Type()
+	ctx := NewScrapeAndSendContext(scraper, scraperConfig)
+	return ctx, NewChangeTracker([]string{ctx.Name})
+}
+
+func TestStatusApi(t *testing.T) {
+	defer useStatusApiTestConfig(&Config{PollInterval: 30})()
+
+	ctx, tracker := newStatusApiTestContext(&ScraperConfig{ApiKey: "key"})
+	doScrapeAndSend(tracker, ctx, true, nil)
+
+	api := NewStatusApi(tracker, []*ScrapeAndSendContext{ctx})
+
+	recorder := httptest.NewRecorder()
+	api.handleScraper(recorder, httptest.NewRequest("GET", "/scrapers/status_api_test", nil))
+
+	var report ScraperStatusReport
+	if err := json.Unmarshal(recorder.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Status != StatusYes || report.ReportedStatus != StatusYes || len(report.Tags) != 1 || report.Tags[0] != string(TagModerna) {
+		t.Errorf("Unexpected report: %+v", report)
+	}
+	if len(report.ScrapeId) == 0 || report.ScrapeId != ScrapeId(ctx.Name) || LogFor(ctx.Name).fields[LogFieldScrapeId] != report.ScrapeId {
+		t.Errorf("Expected scrape id in report and scraper log fields: %+v", report)
+	}
+	if len(report.LastScrapeTime) == 0 || len(report.LastChangeTime) == 0 || len(report.NextDueTime) == 0 {
+		t.Errorf("Expected scrape, change and due times: %+v", report)
+	}
+
+	recorder = httptest.NewRecorder()
+	api.handleScraper(recorder, httptest.NewRequest("GET", "/scrapers/missing", nil))
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for unknown scraper, got %d", recorder.Code)
+	}
+
+	recorder = httptest.NewRecorder()
+	markSchedulerLoop()
+	api.handleHealthz(recorder, httptest.NewRequest("GET", "/healthz", nil))
+	if recorder.Code != http.StatusOK {
+		t.Errorf("Expected healthy scheduler, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+}
@@ -0,0 +1,209 @@
+package csg
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+)
+
+//batch mode for the covidwa api: with api_batch_url set, covidwa updates are collected and posted together,
+//every api_batch_window seconds in continuous mode or at the end of a once pass.  updates the batch endpoint
+//rejects, or all of them if the batch request fails, are sent one by one to api_url
+
+const DefaultApiBatchWindow = 10 //seconds
+const MaxApiBatchSize = 200      //updates per request, larger batches are split
+
+// body posted to api_batch_url, each update as it would be posted to api_url
+type CovidWaBatchRequest struct {
+	Updates []*CovidWaUpdatePayload `json:"updates"`
+}
+
+// expected response, one result per update in the same order
+type CovidWaBatchResponse struct {
+	Results []CovidWaBatchResult `json:"results"`
+}
+
+type CovidWaBatchResult struct {
+	Key   string `json:"key"`
+	Ok    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// collects the updates for a sink and posts them to the batch endpoint, the sink sends the ones that fail
+type BatchStatusSink struct {
+	sink    StatusSink
+	url     string
+	client  *http.Client
+	pending []*StatusUpdate
+	lock    *sync.Mutex
+}
+
+func NewBatchStatusSink(sink StatusSink, url string) *BatchStatusSink {
+	return &BatchStatusSink{
+		sink:    sink,
+		url:     url,
+		client:  &http.Client{Timeout: time.Duration(DefaultStatusSinkTimeout) * time.Second},
+		pending: make([]*StatusUpdate, 0),
+		lock:    new(sync.Mutex),
+	}
+}
+
+func (s *BatchStatusSink) Name() string {
+	return s.sink.Name()
+}
+
+// queues the update for the next batch, replacing an older update for the same key.  failures are only known
+// once the batch is sent, and are logged then, or queued in the outbox if there is one
+func (s *BatchStatusSink) Send(update *StatusUpdate) error {
+	if len(update.Key) == 0 || config.TestMode {
+		err := s.sink.Send(update)
+		metricApiSends.Inc(s.Name(), metricResult(err == nil))
+		return err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for idx, pending := range s.pending {
+		if pending.Key == update.Key {
+			if !pending.Time.After(update.Time) {
+				s.pending[idx] = update
+			}
+			return nil
+		}
+	}
+	s.pending = append(s.pending, update)
+
+	return nil
+}
+
+// sends the collected updates
+func (s *BatchStatusSink) Flush() {
+	s.lock.Lock()
+	pending := s.pending
+	s.pending = make([]*StatusUpdate, 0)
+	s.lock.Unlock()
+
+	for start := 0; start < len(pending); start += MaxApiBatchSize {
+		end := start + MaxApiBatchSize
+		if end > len(pending) {
+			end = len(pending)
+		}
+		s.sendBatch(pending[start:end])
+	}
+}
+
+func (s *BatchStatusSink) sendBatch(updates []*StatusUpdate) {
+	single := make([]*StatusUpdate, 0)
+
+	results, err := s.post(updates)
+	if err != nil {
+		Log.Warnf("Batch of %d update(s) failed: %v, sending them one by one", len(updates), err)
+		single = updates
+	} else {
+		for idx, result := range results {
+			if result.Ok {
+				metricApiSends.Inc(s.Name(), MetricResultSuccess)
+				continue
+			}
+			LogFor(updates[idx].Scraper).Warnf("Update rejected by the batch endpoint: %s, sending it on its own", result.Error)
+			single = append(single, updates[idx])
+		}
+		Log.Infof("Sent a batch of %d update(s), %d rejected", len(updates), len(single))
+	}
+
+	for _, update := range single {
+		err := s.sink.Send(update)
+		metricApiSends.Inc(s.Name(), metricResult(err == nil))
+		if err == nil {
+			continue
+		}
+
+		LogFor(update.Scraper).Errorf("status sink %s: %v", s.Name(), err)
+		recordApiSendFailure(update.Scraper)
+		if outbox := StatusOutbox(); outbox != nil {
+			if err := outbox.Add(s.Name(), update); err != nil {
+				LogFor(update.Scraper).Errorf("Outbox: can't queue %s update: %v", s.Name(), err)
+			}
+		}
+	}
+}
+
+func (s *BatchStatusSink) post(updates []*StatusUpdate) ([]CovidWaBatchResult, error) {
+	request := CovidWaBatchRequest{Updates: make([]*CovidWaUpdatePayload, 0, len(updates))}
+	for _, update := range updates {
+		request.Updates = append(request.Updates, NewCovidWaUpdatePayload(update, config.ApiVersion, config.ApiSecret))
+	}
+
+	data, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", s.url, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBytes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	Log.Debugf("Batch of %d update(s): %s", len(updates), string(respBytes))
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("Batch API status code is %d!", resp.StatusCode)
+	}
+
+	var response CovidWaBatchResponse
+	if err := json.Unmarshal(respBytes, &response); err != nil {
+		return nil, fmt.Errorf("Can't parse batch API response: %v", err)
+	}
+	if len(response.Results) != len(updates) {
+		return nil, fmt.Errorf("Expected %d batch API results, got %d", len(updates), len(response.Results))
+	}
+
+	return response.Results, nil
+}
+
+// the sink a batching sink sends single updates with, for callers that need to know whether an update was accepted
+func unwrapStatusSink(sink StatusSink) StatusSink {
+	if batchSink, ok := sink.(*BatchStatusSink); ok {
+		return batchSink.sink
+	}
+	return sink
+}
+
+// sends what the batching sinks have collected
+func flushStatusBatches() {
+	for _, sink := range StatusSinks() {
+		if batchSink, ok := sink.StatusSink.(*BatchStatusSink); ok {
+			batchSink.Flush()
+		}
+	}
+}
+
+// flushes the batching sinks every window until the process exits
+func startStatusBatches(window time.Duration) {
+	if window <= 0 {
+		window = time.Duration(DefaultApiBatchWindow) * time.Second
+	}
+
+	go func() {
+		for {
+			time.Sleep(window)
+			flushStatusBatches()
+		}
+	}()
+}
@@ -0,0 +1,111 @@
+package csg
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBatchStatusSink(t *testing.T) {
+	lock := new(sync.Mutex)
+	batchFails := false
+	batches := make([][]string, 0)
+	singles := make([]string, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		body, _ := ioutil.ReadAll(r.Body)
+		if r.URL.Path == "/updater" {
+			var payload CovidWaUpdatePayload
+			json.Unmarshal(body, &payload)
+			if payload.Key == "broken" {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			singles = append(singles, payload.Key)
+			return
+		}
+
+		if batchFails {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var request CovidWaBatchRequest
+		if err := json.Unmarshal(body, &request); err != nil {
+			t.Errorf("Invalid batch request: %v", err)
+		}
+		keys := make([]string, 0)
+		response := CovidWaBatchResponse{}
+		for _, update := range request.Updates {
+			keys = append(keys, update.Key)
+			response.Results = append(response.Results, CovidWaBatchResult{Key: update.Key, Ok: update.Key != "rejected" && update.Key != "broken", Error: "unknown key"})
+			if update.Secret != "batchsecret" {
+				t.Errorf("Unexpected secret: %s", update.Secret)
+			}
+		}
+		batches = append(batches, keys)
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer server.Close()
+
+	prevConfig := config
+	config = &Config{ApiUrl: server.URL + "/updater", ApiBatchUrl: server.URL + "/batch", ApiSecret: "batchsecret"}
+	defer func() { config = prevConfig }()
+
+	sinks, err := NewStatusSinks(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prevSinks := StatusSinks()
+	SetStatusSinks(sinks)
+	defer SetStatusSinks(prevSinks)
+
+	prevSuccesses, prevFailures := metricValue(metricApiSends, StatusSinkTypeCovidWa, MetricResultSuccess), metricValue(metricApiSends, StatusSinkTypeCovidWa, MetricResultFailure)
+
+	now := time.Now()
+	send := func(key string, status Status, offset time.Duration) {
+		update := &StatusUpdate{Scraper: key, Key: key, Status: status, Time: now.Add(offset)}
+		if failed := sendStatusUpdate(update, statusSinksFor(update)); len(failed) != 0 {
+			t.Errorf("Unexpected failure sending %s", key)
+		}
+	}
+
+	send("first", StatusNo, 0)
+	send("rejected", StatusNo, 0)
+	send("first", StatusYes, time.Second)
+	send("broken", StatusNo, 0)
+	if len(batches) != 0 || len(singles) != 0 {
+		t.Fatalf("Expected updates to wait for the batch")
+	}
+
+	flushStatusBatches()
+	if len(batches) != 1 || len(batches[0]) != 3 || len(singles) != 1 || singles[0] != "rejected" {
+		t.Fatalf("Expected one batch of three and the rejected update on its own, got %v and %v", batches, singles)
+	}
+
+	batchFails = true
+	send("first", StatusNo, 2*time.Second)
+	send("second", StatusNo, 0)
+	flushStatusBatches()
+	if len(batches) != 1 || len(singles) != 3 {
+		t.Errorf("Expected a failed batch to be sent one by one, got %v", singles)
+	}
+
+	flushStatusBatches()
+	if len(singles) != 3 {
+		t.Errorf("Expected nothing to send after a flush, got %v", singles)
+	}
+
+	//counted once the result is known: first in the batch, rejected on its own, then first and second on their own,
+	//and broken, rejected by the batch endpoint and on its own
+	successes := metricValue(metricApiSends, StatusSinkTypeCovidWa, MetricResultSuccess) - prevSuccesses
+	failures := metricValue(metricApiSends, StatusSinkTypeCovidWa, MetricResultFailure) - prevFailures
+	if successes != 4 || failures != 1 {
+		t.Errorf("Expected 4 successes and 1 failure, got %v and %v", successes, failures)
+	}
+}
@@ -0,0 +1,432 @@
+package csg
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+//destinations of status updates, configured under status_sinks:.  every update goes to each sink whose filter accepts it.
+//without status_sinks: updates go to the covidwa updater api only, as before
+
+const StatusSinkTypeCovidWa = "covidwa"
+const StatusSinkTypeJsonl = "jsonl"
+const StatusSinkTypeStdout = "stdout"
+const StatusSinkTypeWebhook = "webhook"
+
+const DefaultStatusSinkTimeout = 30 //seconds
+
+// version 1 is key, status, secret, content_url, scraperTags and the optional run_id and scrape_id.
+// version 2 adds version, scraper_type, appointments, earliest_slot, data_age and booking_url
+const StatusUpdateVersion = 2
+const DefaultApiVersion = 1
+
+var statusSinks = make([]*FilteredStatusSink, 0)
+var statusSinksLock = new(sync.Mutex)
+
+// sent as is by the json sinks, always the latest version
+type StatusUpdate struct {
+	Version      int        `json:"version"`
+	Scraper      string     `json:"scraper"`
+	Key          string     `json:"key"`
+	Status       Status     `json:"status"`
+	Tags         []string   `json:"tags"`
+	ContentUrl   string     `json:"content_url,omitempty"`
+	RunId        string     `json:"run_id,omitempty"`
+	ScrapeId     string     `json:"scrape_id,omitempty"`
+	Time         time.Time  `json:"time"`
+	ScraperType  string     `json:"type,omitempty"`
+	Appointments *int       `json:"appointments,omitempty"`
+	EarliestSlot *time.Time `json:"earliest_slot,omitempty"`
+	DataAge      *int64     `json:"data_age,omitempty"` //seconds
+	BookingUrl   string     `json:"booking_url,omitempty"`
+}
+
+// body posted to the covidwa updater, in the version configured with api_version
+type CovidWaUpdatePayload struct {
+	Version      int        `json:"version,omitempty"`
+	Key          string     `json:"key"`
+	Status       Status     `json:"status"`
+	Secret       string     `json:"secret"`
+	ContentUrl   string     `json:"content_url,omitempty"`
+	ScraperTags  []string   `json:"scraperTags"`
+	RunId        string     `json:"run_id,omitempty"`
+	ScrapeId     string     `json:"scrape_id,omitempty"`
+	ScraperType  string     `json:"scraper_type,omitempty"`
+	Appointments *int       `json:"appointments,omitempty"`
+	EarliestSlot *time.Time `json:"earliest_slot,omitempty"`
+	DataAge      *int64     `json:"data_age,omitempty"`
+	BookingUrl   string     `json:"booking_url,omitempty"`
+}
+
+type StatusSink interface {
+	Name() string
+	Send(update *StatusUpdate) error
+}
+
+type StatusSinkConfig struct {
+	Type     string            `yaml:"type"`
+	Name     string            `yaml:"name"`
+	Path     string            `yaml:"path"`     //jsonl
+	Url      string            `yaml:"url"`      //webhook
+	Headers  map[string]string `yaml:"headers"`  //webhook
+	Timeout  int64             `yaml:"timeout"`  //webhook, seconds
+	Scrapers string            `yaml:"scrapers"` //filters: scraper name pattern, * matches anything
+	Types    []string          `yaml:"types"`
+	Statuses []Status          `yaml:"statuses"`
+}
+
+// a sink and the updates it accepts
+type FilteredStatusSink struct {
+	StatusSink
+	pattern  *regexp.Regexp
+	types    []string
+	statuses []Status
+}
+
+func NewStatusUpdate(ctx *ScrapeAndSendContext, contentUrl string, scrapeId string) *StatusUpdate {
+	update := &StatusUpdate{
+		Version:    StatusUpdateVersion,
+		Scraper:    ctx.Name,
+		ContentUrl: contentUrl,
+		RunId:      CurrentRunId,
+		ScrapeId:   scrapeId,
+		Time:       time.Now(),
+	}
+
+	ctx.mutex.Lock()
+	update.Status = ctx.Status
+	update.Tags = ctx.Tags
+	details := ctx.Details
+	ctx.mutex.Unlock()
+
+	if update.Tags == nil {
+		update.Tags = []string{}
+	}
+	if details != nil {
+		update.Appointments = details.Appointments
+		update.EarliestSlot = details.EarliestSlot
+		update.BookingUrl = details.BookingUrl
+		if details.DataTime != nil {
+			dataAge := int64(update.Time.Sub(*details.DataTime).Seconds())
+			update.DataAge = &dataAge
+		}
+	}
+	if ctx.Config != nil {
+		update.Key = ctx.Config.ApiKey
+		if len(update.BookingUrl) == 0 {
+			update.BookingUrl = ctx.Config.BookingUrl
+		}
+	}
+	if ctx.Scraper != nil {
+		update.ScraperType = ctx.Scraper.Type()
+	}
+
+	return update
+}
+
+func NewCovidWaUpdatePayload(update *StatusUpdate, version int, secret string) *CovidWaUpdatePayload {
+	payload := &CovidWaUpdatePayload{
+		Key:         update.Key,
+		Status:      update.Status,
+		Secret:      secret,
+		ContentUrl:  update.ContentUrl,
+		ScraperTags: update.Tags,
+		ScrapeId:    update.ScrapeId,
+	}
+	//optional, lets an update be traced back to the scrape that produced it
+	if len(update.ScrapeId) > 0 {
+		payload.RunId = update.RunId
+	}
+
+	if version >= 2 {
+		payload.Version = version
+		payload.ScraperType = update.ScraperType
+		payload.Appointments = update.Appointments
+		payload.EarliestSlot = update.EarliestSlot
+		payload.DataAge = update.DataAge
+		payload.BookingUrl = update.BookingUrl
+	}
+
+	return payload
+}
+
+// creates the sinks configured with status_sinks:, or the covidwa sink
+func NewStatusSinks(config *Config) ([]*FilteredStatusSink, error) {
+	if config.ApiVersion < 0 || config.ApiVersion > StatusUpdateVersion {
+		return nil, fmt.Errorf("Unsupported api_version: %d, expecting %d to %d", config.ApiVersion, DefaultApiVersion, StatusUpdateVersion)
+	}
+
+	sinkConfigs := config.StatusSinks
+	if len(sinkConfigs) == 0 {
+		sinkConfigs = []StatusSinkConfig{{Type: StatusSinkTypeCovidWa}}
+	}
+
+	result := make([]*FilteredStatusSink, 0, len(sinkConfigs))
+	names := make(map[string]bool)
+	for idx, sinkConfig := range sinkConfigs {
+		if len(sinkConfig.Name) == 0 {
+			sinkConfig.Name = sinkConfig.Type
+			if names[sinkConfig.Name] {
+				sinkConfig.Name = fmt.Sprintf("%s-%d", sinkConfig.Type, idx)
+			}
+		}
+		if names[sinkConfig.Name] {
+			return nil, fmt.Errorf("Duplicate status sink name: %s", sinkConfig.Name)
+		}
+		names[sinkConfig.Name] = true
+
+		sink, err := newStatusSink(sinkConfig)
+		if err != nil {
+			return nil, fmt.Errorf("status sink %s: %v", sinkConfig.Name, err)
+		}
+		if sinkConfig.Type == StatusSinkTypeCovidWa && len(config.ApiBatchUrl) > 0 {
+			sink = NewBatchStatusSink(sink, config.ApiBatchUrl)
+		}
+
+		filtered := &FilteredStatusSink{StatusSink: sink, types: sinkConfig.Types, statuses: sinkConfig.Statuses}
+		if len(sinkConfig.Scrapers) > 0 {
+			pattern, err := compileScraperNamePattern(sinkConfig.Scrapers)
+			if err != nil {
+				return nil, fmt.Errorf("status sink %s: %v", sinkConfig.Name, err)
+			}
+			filtered.pattern = pattern
+		}
+		result = append(result, filtered)
+	}
+
+	return result, nil
+}
+
+func newStatusSink(sinkConfig StatusSinkConfig) (StatusSink, error) {
+	switch sinkConfig.Type {
+	case StatusSinkTypeCovidWa:
+		return &CovidWaStatusSink{name: sinkConfig.Name}, nil
+	case StatusSinkTypeJsonl:
+		if len(sinkConfig.Path) == 0 {
+			return nil, fmt.Errorf("path is not configured")
+		}
+		return &JsonlStatusSink{name: sinkConfig.Name, path: sinkConfig.Path, lock: new(sync.Mutex)}, nil
+	case StatusSinkTypeStdout:
+		return &StdoutStatusSink{name: sinkConfig.Name, lock: new(sync.Mutex)}, nil
+	case StatusSinkTypeWebhook:
+		if len(sinkConfig.Url) == 0 {
+			return nil, fmt.Errorf("url is not configured")
+		}
+		timeout := time.Duration(DefaultStatusSinkTimeout) * time.Second
+		if sinkConfig.Timeout > 0 {
+			timeout = time.Duration(sinkConfig.Timeout) * time.Second
+		}
+		return &WebhookStatusSink{name: sinkConfig.Name, url: sinkConfig.Url, headers: sinkConfig.Headers, client: &http.Client{Timeout: timeout}}, nil
+	default:
+		return nil, fmt.Errorf("Unknown status sink type: %s", sinkConfig.Type)
+	}
+}
+
+func SetStatusSinks(sinks []*FilteredStatusSink) {
+	statusSinksLock.Lock()
+	defer statusSinksLock.Unlock()
+
+	statusSinks = sinks
+}
+
+func StatusSinks() []*FilteredStatusSink {
+	statusSinksLock.Lock()
+	defer statusSinksLock.Unlock()
+
+	return statusSinks
+}
+
+func (s *FilteredStatusSink) Accepts(update *StatusUpdate) bool {
+	if s.pattern != nil && !s.pattern.MatchString(update.Scraper) {
+		return false
+	}
+	if len(s.types) > 0 && !matchesAny(update.ScraperType, s.types) {
+		return false
+	}
+	if len(s.statuses) > 0 {
+		for _, status := range s.statuses {
+			if status == update.Status {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// the sinks that accept the update
+func statusSinksFor(update *StatusUpdate) []*FilteredStatusSink {
+	result := make([]*FilteredStatusSink, 0)
+	if update.Status == StatusApiSkip {
+		return result
+	}
+
+	for _, sink := range StatusSinks() {
+		if sink.Accepts(update) {
+			result = append(result, sink)
+		}
+	}
+	return result
+}
+
+// sends the update to each of the sinks, returning the ones that failed
+func sendStatusUpdate(update *StatusUpdate, sinks []*FilteredStatusSink) []*FilteredStatusSink {
+	failed := make([]*FilteredStatusSink, 0)
+	for _, sink := range sinks {
+		err := sink.Send(update)
+		//batching sinks count each update once its result is known
+		if _, batching := sink.StatusSink.(*BatchStatusSink); !batching {
+			metricApiSends.Inc(sink.Name(), metricResult(err == nil))
+		}
+		if err != nil {
+			LogFor(update.Scraper).Errorf("status sink %s: %v", sink.Name(), err)
+			failed = append(failed, sink)
+		}
+	}
+	return failed
+}
+
+func statusSinkNames(sinks []*FilteredStatusSink) string {
+	names := make([]string, 0, len(sinks))
+	for _, sink := range sinks {
+		names = append(names, sink.Name())
+	}
+	return strings.Join(names, ", ")
+}
+
+// the covidwa updater api at api_url
+type CovidWaStatusSink struct {
+	name string
+}
+
+func (s *CovidWaStatusSink) Name() string {
+	return s.name
+}
+
+func (s *CovidWaStatusSink) Send(update *StatusUpdate) error {
+	if len(update.Key) == 0 || config.TestMode {
+		Log.Debugf("(silent) name: %s, key: %s, status: %s, tags: %v, scrape: %s", update.Scraper, update.Key, update.Status, update.Tags, update.ScrapeId)
+		return nil
+	}
+
+	payload := NewCovidWaUpdatePayload(update, config.ApiVersion, config.ApiSecret)
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{}
+	req, _ := http.NewRequest("POST", config.ApiUrl, bytes.NewReader(data))
+	req.Header.Add("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBytes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	payload.Secret = TraceRedacted
+	redacted, _ := json.Marshal(payload)
+	Log.Debug(fmt.Sprintf("%s: %s", string(redacted), string(respBytes)))
+
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("API Status code is %d!", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// appends one json object per update to a file
+type JsonlStatusSink struct {
+	name string
+	path string
+	lock *sync.Mutex
+}
+
+func (s *JsonlStatusSink) Name() string {
+	return s.name
+}
+
+func (s *JsonlStatusSink) Send(update *StatusUpdate) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return appendJsonLine(s.path, update)
+}
+
+// prints one json object per update, for local development
+type StdoutStatusSink struct {
+	name string
+	lock *sync.Mutex
+}
+
+func (s *StdoutStatusSink) Name() string {
+	return s.name
+}
+
+func (s *StdoutStatusSink) Send(update *StatusUpdate) error {
+	jsonBytes, err := json.Marshal(update)
+	if err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	_, err = fmt.Fprintln(os.Stdout, string(jsonBytes))
+	return err
+}
+
+// posts each update as json, e.g. to a partner organization
+type WebhookStatusSink struct {
+	name    string
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+func (s *WebhookStatusSink) Name() string {
+	return s.name
+}
+
+func (s *WebhookStatusSink) Send(update *StatusUpdate) error {
+	jsonBytes, err := json.Marshal(update)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", s.url, bytes.NewReader(jsonBytes))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range s.headers {
+		req.Header.Set(key, value)
+	}
+
+	//like chat webhooks, a partner's webhook url may carry its secret in the path
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return redactTransportError(err, HostPattern.FindString(s.url))
+	}
+	defer resp.Body.Close()
+
+	body, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return NewHttpStatusError(resp.StatusCode, HostPattern.FindString(s.url), body)
+	}
+
+	return nil
+}
@@ -0,0 +1,129 @@
+package csg
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatusSinks(t *testing.T) {
+	var apiBody string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if r.URL.Path == "/partner" {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		apiBody = string(body)
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "sinks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	prevConfig := config
+	config = &Config{ApiUrl: server.URL + "/updater", ApiSecret: "sinksecret", StatusSinks: []StatusSinkConfig{
+		{Type: StatusSinkTypeCovidWa},
+		{Type: StatusSinkTypeJsonl, Path: filepath.Join(dir, "updates.jsonl"), Statuses: []Status{StatusYes}},
+		{Type: StatusSinkTypeWebhook, Name: "partner", Url: server.URL + "/partner", Scrapers: "kroger_*"},
+	}}
+	defer func() { config = prevConfig }()
+
+	sinks, err := NewStatusSinks(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prevSinks := StatusSinks()
+	SetStatusSinks(sinks)
+	defer SetStatusSinks(prevSinks)
+
+	ctx := NewScrapeAndSendContext(new(statusApiTestScraper), &ScraperConfig{ApiKey: "sink_key"})
+	ctx.Status = StatusYes
+	ctx.Tags = []string{string(TagModerna)}
+
+	update := NewStatusUpdate(ctx, "", "scrape1")
+	targets := statusSinksFor(update)
+	if names := statusSinkNames(targets); names != "covidwa, jsonl" {
+		t.Fatalf("Unexpected sinks: %s", names)
+	}
+	if failed := sendStatusUpdate(update, targets); len(failed) != 0 {
+		t.Errorf("Unexpected failures: %s", statusSinkNames(failed))
+	}
+	if !strings.Contains(apiBody, `"key":"sink_key"`) || !strings.Contains(apiBody, `"secret":"sinksecret"`) || !strings.Contains(apiBody, `"scrape_id":"scrape1"`) {
+		t.Errorf("Unexpected api body: %s", apiBody)
+	}
+
+	lines, err := ioutil.ReadFile(filepath.Join(dir, "updates.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var written StatusUpdate
+	if err := json.Unmarshal(lines, &written); err != nil || written.Key != "sink_key" || written.Status != StatusYes || strings.Contains(string(lines), "sinksecret") {
+		t.Errorf("Unexpected jsonl line (%v): %s", err, lines)
+	}
+
+	update.Scraper = "kroger_1"
+	update.Status = StatusNo
+	targets = statusSinksFor(update)
+	if failed := sendStatusUpdate(update, targets); statusSinkNames(failed) != "partner" {
+		t.Errorf("Expected the partner sink to fail alone, got %s", statusSinkNames(failed))
+	}
+
+	update.Status = StatusApiSkip
+	if targets := statusSinksFor(update); len(targets) != 0 {
+		t.Errorf("Expected no sinks for %s, got %s", StatusApiSkip, statusSinkNames(targets))
+	}
+}
+
+func TestCovidWaUpdatePayload(t *testing.T) {
+	ctx := NewScrapeAndSendContext(new(statusApiTestScraper), &ScraperConfig{ApiKey: `quoted "key"`, BookingUrl: "https://example.com/book"})
+	ctx.Status = StatusLimited
+	ctx.Tags = []string{`tag "with" quotes`}
+
+	startScrapeDetails(ctx.Name)
+	dataTime := time.Now().Add(-time.Minute)
+	ReportAppointments(ctx.Name, 3)
+	ReportSlot(ctx.Name, dataTime.Add(2*time.Hour))
+	ReportSlot(ctx.Name, dataTime.Add(time.Hour))
+	ReportDataTime(ctx.Name, dataTime)
+	ctx.Details = finishScrapeDetails(ctx.Name)
+
+	update := NewStatusUpdate(ctx, "https://s3.example.com/dump.out", "scrape1")
+	if update.Appointments == nil || *update.Appointments != 3 || update.EarliestSlot == nil || !update.EarliestSlot.Equal(dataTime.Add(time.Hour)) {
+		t.Errorf("Unexpected details: %+v", update)
+	}
+	if update.DataAge == nil || *update.DataAge < 59 || update.BookingUrl != "https://example.com/book" {
+		t.Errorf("Unexpected data age or booking url: %+v", update)
+	}
+
+	for version, expectedFields := range map[int]int{0: 7, 1: 7, 2: 13} {
+		data, err := json.Marshal(NewCovidWaUpdatePayload(update, version, "secret"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		fields := make(map[string]interface{})
+		if err := json.Unmarshal(data, &fields); err != nil {
+			t.Fatalf("version %d: invalid json %s: %v", version, data, err)
+		}
+		if len(fields) != expectedFields || fields["key"] != `quoted "key"` {
+			t.Errorf("version %d: expected %d fields, got %s", version, expectedFields, data)
+		}
+	}
+
+	if _, err := NewStatusSinks(&Config{ApiVersion: StatusUpdateVersion + 1}); err == nil {
+		t.Errorf("Expected error for unsupported api_version")
+	}
+	if _, err := NewStatusSinks(&Config{StatusSinks: []StatusSinkConfig{{Type: StatusSinkTypeStdout, Scrapers: "kroger_("}}}); err == nil {
+		t.Errorf("Expected error for a malformed scrapers pattern")
+	}
+}
@@ -0,0 +1,235 @@
+package csg
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+//go text/template support for endpoint urls, bodies and headers, e.g.
+//  url: 'https://example.com/slots?date={{ now | addDays 1 | date "2006-01-02" | urlquery }}&id={{ .Vars.location_id }}'
+//
+//available data:
+//  .Vars        per-scraper variables (vars: in the scraper config)
+//  .Captures    named submatches captured by earlier multistage next_url_regexp patterns
+//  .Prev        last submatch of the previous stage (same as ##PREVIOUS##)
+//  .Submatches  all submatches of the previous stage (same as ##PREV_SUBMATCH_n##)
+//
+//the legacy ##...## magic tokens are still replaced after the template is executed, ##PREVIOUS## and ##PREV_SUBMATCH_n##
+//included, so a template is parsed once however many values the previous stage matches
+
+const DefaultTimezone = "America/Los_Angeles"
+
+type TemplateData struct {
+	Vars       map[string]string
+	Captures   map[string]string
+	Prev       string
+	Submatches []string
+
+	name string //scraper, for logging
+}
+
+var templateVars = make(map[string]map[string]string)
+var templateVarsLock = new(sync.RWMutex)
+
+var parsedTemplates = make(map[string]*template.Template)
+var parsedTemplatesLock = new(sync.Mutex)
+
+// registers per-scraper variables, available in templates as .Vars
+func SetTemplateVars(name string, vars map[string]string) {
+	templateVarsLock.Lock()
+	defer templateVarsLock.Unlock()
+
+	if len(vars) == 0 {
+		delete(templateVars, name)
+	} else {
+		templateVars[name] = vars
+	}
+}
+
+func getTemplateVars(name string) map[string]string {
+	templateVarsLock.RLock()
+	defer templateVarsLock.RUnlock()
+
+	return templateVars[name]
+}
+
+// location used for date math in templates and magic tokens, configured with timezone:
+func templateLocation() *time.Location {
+	tz := DefaultTimezone
+	if config != nil && len(config.Timezone) > 0 {
+		tz = config.Timezone
+	}
+
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		Log.Warnf("Could not load timezone %s, using UTC: %v", tz, err)
+		return time.UTC
+	}
+
+	return loc
+}
+
+var templateFuncs = template.FuncMap{
+	"now": func() time.Time {
+		return time.Now().In(templateLocation())
+	},
+	"in": func(tz string, t time.Time) (time.Time, error) {
+		loc, err := time.LoadLocation(tz)
+		if err != nil {
+			return t, err
+		}
+		return t.In(loc), nil
+	},
+	"date": func(layout string, t time.Time) string {
+		return t.Format(layout)
+	},
+	"unix": func(t time.Time) int64 {
+		return t.Unix()
+	},
+	"unixMilli": func(t time.Time) int64 {
+		return t.UnixNano() / int64(time.Millisecond)
+	},
+	"addDate": func(years int, months int, days int, t time.Time) time.Time {
+		return t.AddDate(years, months, days)
+	},
+	"addDays": func(days int, t time.Time) time.Time {
+		return t.AddDate(0, 0, days)
+	},
+	"addMonths": func(months int, t time.Time) time.Time {
+		return t.AddDate(0, months, 0)
+	},
+	"addHours": func(hours int, t time.Time) time.Time {
+		return t.Add(time.Duration(hours) * time.Hour)
+	},
+	"json": func(v interface{}) (string, error) {
+		jsonBytes, err := json.Marshal(v)
+		return string(jsonBytes), err
+	},
+	//json string contents without the surrounding quotes, for use inside a quoted json string
+	"jsonstr": func(v interface{}) (string, error) {
+		jsonBytes, err := json.Marshal(fmt.Sprint(v))
+		if err != nil {
+			return "", err
+		}
+		return string(jsonBytes[1 : len(jsonBytes)-1]), nil
+	},
+	"default": func(defaultValue string, value interface{}) string {
+		str := ""
+		if value != nil {
+			str = fmt.Sprint(value)
+		}
+		if len(str) == 0 {
+			return defaultValue
+		}
+		return str
+	},
+}
+
+func isTemplate(input string) bool {
+	return strings.Contains(input, "{{")
+}
+
+func parseTemplate(source string) (*template.Template, error) {
+	parsedTemplatesLock.Lock()
+	defer parsedTemplatesLock.Unlock()
+
+	if tmpl, exists := parsedTemplates[source]; exists {
+		return tmpl, nil
+	}
+
+	tmpl, err := template.New("endpoint").Funcs(templateFuncs).Option("missingkey=zero").Parse(source)
+	if err != nil {
+		return nil, err
+	}
+
+	parsedTemplates[source] = tmpl
+	return tmpl, nil
+}
+
+// executes go template actions (if any) and then replaces legacy magic tokens
+func RenderTemplate(input string, data *TemplateData) (string, error) {
+	if data == nil {
+		data = new(TemplateData)
+	}
+
+	if !isTemplate(input) {
+		return replaceMagic(replacePrevMagic(data.name, input, data.Submatches)), nil
+	}
+
+	tmpl, err := parseTemplate(input)
+	if err != nil {
+		return "", fmt.Errorf("Invalid template: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err = tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("Error executing template: %v", err)
+	}
+
+	return replaceMagic(replacePrevMagic(data.name, buf.String(), data.Submatches)), nil
+}
+
+func (endpoint *Endpoint) templateData(name string) *TemplateData {
+	data := new(TemplateData)
+	data.name = name
+	data.Vars = getTemplateVars(name)
+	data.Captures = endpoint.Captures
+	data.Submatches = endpoint.PrevMatch
+	if len(endpoint.PrevMatch) > 0 {
+		data.Prev = endpoint.PrevMatch[len(endpoint.PrevMatch)-1]
+	}
+
+	return data
+}
+
+func (endpoint *Endpoint) usesTemplates() bool {
+	templated := func(source string) bool {
+		return isTemplate(source) || hasPrevMagic(source)
+	}
+
+	if templated(endpoint.Url) || templated(endpoint.bodyCacheSource()) {
+		return true
+	}
+
+	for _, header := range endpoint.Headers {
+		if templated(header.Value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// distinguishes cache entries for the same templated endpoint rendered with different data
+func (endpoint *Endpoint) templateCacheKey(name string) string {
+	if !endpoint.usesTemplates() {
+		return ""
+	}
+
+	data := endpoint.templateData(name)
+
+	parts := make([]string, 0)
+	for _, m := range []map[string]string{data.Vars, data.Captures} {
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			parts = append(parts, fmt.Sprintf("%s=%s", k, m[k]))
+		}
+		parts = append(parts, "")
+	}
+	parts = append(parts, data.Submatches...)
+
+	hash := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(hash[:8])
+}
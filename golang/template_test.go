@@ -0,0 +1,80 @@
+package csg
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRenderTemplate(t *testing.T) {
+	data := &TemplateData{
+		Vars:       map[string]string{"location": "a b"},
+		Captures:   map[string]string{"id": `x"y`},
+		Prev:       "prev",
+		Submatches: []string{"whole", "prev"},
+	}
+
+	cases := map[string]string{
+		"no template":                                  "no template",
+		"q={{ .Vars.location | urlquery }}":            "q=a+b",
+		`{"id":{{ json .Captures.id }}}`:               `{"id":"x\"y"}`,
+		`{"id":"{{ jsonstr .Captures.id }}"}`:          `{"id":"x\"y"}`,
+		"{{ .Prev }}/{{ index .Submatches 0 }}":        "prev/whole",
+		"{{ .Vars.missing | default \"none\" }}":       "none",
+		"{{ now | addDays 1 | date \"2006\" | len }}":  "4",
+		"{{ .Vars.location | html }}&##CURRENT_DATE##": "a b&" + time.Now().In(templateLocation()).Format("2006-01-02"),
+	}
+
+	for input, expected := range cases {
+		processed, err := RenderTemplate(input, data)
+		if err != nil {
+			t.Errorf("Unexpected error for %s: %v", input, err)
+			continue
+		}
+		if processed != expected {
+			t.Errorf("Expecting %s, got %s", expected, processed)
+		}
+	}
+
+	if _, err := RenderTemplate("{{ .Vars.location", data); err == nil {
+		t.Errorf("Expected error for malformed template, got nil")
+	}
+}
+
+func TestReplacePrevMagic(t *testing.T) {
+	processed := replacePrevMagic("TestReplacePrevMagic", "##PREVIOUS##/##PREV_SUBMATCH_1##/##PREV_SUBMATCH_2##", []string{"whole", "one", "two"})
+	expected := "two/one/two"
+
+	if processed != expected {
+		t.Errorf("Expecting %s, got %s", expected, processed)
+		return
+	}
+}
+
+func TestDecorateTemplatedEndpoint(t *testing.T) {
+	endpoint := &Endpoint{Url: "https://example.com/{{ .Vars.missing | default \"slots\" }}/##PREVIOUS##?id=##PREV_SUBMATCH_1##"}
+	originalUrl := endpoint.Url
+
+	parsedTemplatesLock.Lock()
+	parsedCount := len(parsedTemplates)
+	parsedTemplatesLock.Unlock()
+
+	//matched text is substituted as is, never parsed as template code, and the template is parsed once
+	for _, prev := range []string{"a", "b", "{{ .Vars"} {
+		decorateEndpoint("TestDecorateTemplatedEndpoint", endpoint, []string{"whole", prev}, nil)
+		url, err := RenderTemplate(endpoint.Url, endpoint.templateData("TestDecorateTemplatedEndpoint"))
+		expected := "https://example.com/slots/" + prev + "?id=" + prev
+		if err != nil || url != expected {
+			t.Errorf("Expecting %s, got %s (%v)", expected, url, err)
+		}
+		if logged := endpoint.RenderedUrl("TestDecorateTemplatedEndpoint"); logged != expected {
+			t.Errorf("Expecting the rendered url %s to be logged, got %s", expected, logged)
+		}
+		endpoint.Url = originalUrl
+	}
+
+	parsedTemplatesLock.Lock()
+	defer parsedTemplatesLock.Unlock()
+	if len(parsedTemplates) != parsedCount+1 {
+		t.Errorf("Expected one parsed template, got %d", len(parsedTemplates)-parsedCount)
+	}
+}
@@ -0,0 +1,270 @@
+package csg
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+//per-scrape record of every fetch a scraper makes, started and finished by doScrapeAndSend.
+//fetches are attributed by scraper name, the same way Endpoint.Fetch attributes metrics and logs
+
+const DefaultTraceHistorySize = 10
+const TraceRedacted = "<snip>"
+const TraceFileSuffix = ".trace.json"
+
+// query parameters whose values are never written to traces
+var TraceRedactedParamPattern = regexp.MustCompile(`(?i)(key|token|secret|pass|auth|sig|session|code)`)
+
+var activeTraces = make(map[string]*ScrapeTrace)
+var traceHistory = make(map[string][]*ScrapeTrace)
+var tracesLock = new(sync.Mutex)
+
+type TraceFetch struct {
+	Url         string `json:"url"`
+	Method      string `json:"method"`
+	StatusCode  int    `json:"status_code,omitempty"`
+	Bytes       int    `json:"bytes"`
+	DurationMs  int64  `json:"duration_ms"`
+	CacheHit    bool   `json:"cache_hit,omitempty"`
+	NotModified bool   `json:"not_modified,omitempty"`
+	Proxy       string `json:"proxy,omitempty"`
+	Retry       int    `json:"retry"` //number of earlier fetches of the same url in this scrape
+	Error       string `json:"error,omitempty"`
+	rawUrl      string
+}
+
+type ScrapeTrace struct {
+	Scraper    string       `json:"scraper"`
+	RunId      string       `json:"run_id"`
+	ScrapeId   string       `json:"scrape_id"`
+	StartTime  string       `json:"start_time"`
+	DurationMs int64        `json:"duration_ms"`
+	Status     Status       `json:"status"`
+	Error      string       `json:"error,omitempty"`
+	Fetches    []TraceFetch `json:"fetches"`
+	start      time.Time
+	lock       *sync.Mutex
+}
+
+func traceHistorySize() int {
+	if config != nil && config.TraceHistorySize > 0 {
+		return config.TraceHistorySize
+	}
+	return DefaultTraceHistorySize
+}
+
+// starts collecting fetches made under this scraper name
+func startTrace(name string, scrapeId string) *ScrapeTrace {
+	trace := &ScrapeTrace{
+		Scraper:  name,
+		RunId:    CurrentRunId,
+		ScrapeId: scrapeId,
+		Fetches:  make([]TraceFetch, 0),
+		start:    time.Now(),
+		lock:     new(sync.Mutex),
+	}
+	trace.StartTime = trace.start.Format(time.RFC3339)
+
+	tracesLock.Lock()
+	defer tracesLock.Unlock()
+	activeTraces[name] = trace
+
+	return trace
+}
+
+// stops collecting fetches for the scraper and adds the trace to its history
+func finishTrace(name string, status Status, err error) *ScrapeTrace {
+	tracesLock.Lock()
+	defer tracesLock.Unlock()
+
+	trace, exists := activeTraces[name]
+	if !exists {
+		return nil
+	}
+	delete(activeTraces, name)
+
+	trace.lock.Lock()
+	trace.DurationMs = time.Since(trace.start).Milliseconds()
+	trace.Status = status
+	if err != nil {
+		trace.Error = err.Error()
+	}
+	trace.lock.Unlock()
+
+	history := append(traceHistory[name], trace)
+	if len(history) > traceHistorySize() {
+		history = history[len(history)-traceHistorySize():]
+	}
+	traceHistory[name] = history
+
+	return trace
+}
+
+// no-op unless a scrape is in progress for the scraper
+func recordTraceFetch(name string, fetch TraceFetch) {
+	tracesLock.Lock()
+	trace, exists := activeTraces[name]
+	tracesLock.Unlock()
+
+	if !exists {
+		return
+	}
+
+	trace.lock.Lock()
+	defer trace.lock.Unlock()
+
+	if !fetch.CacheHit {
+		for _, prev := range trace.Fetches {
+			if !prev.CacheHit && prev.Method == fetch.Method && prev.Url == fetch.Url {
+				fetch.Retry++
+			}
+		}
+	}
+
+	trace.Fetches = append(trace.Fetches, fetch)
+}
+
+// returns the finished traces of a scraper, newest first
+func TraceHistory(name string) []*ScrapeTrace {
+	tracesLock.Lock()
+	defer tracesLock.Unlock()
+
+	history := traceHistory[name]
+	traces := make([]*ScrapeTrace, 0, len(history))
+	for idx := len(history) - 1; idx >= 0; idx-- {
+		traces = append(traces, history[idx])
+	}
+
+	return traces
+}
+
+// removes credentials from a url: the userinfo password, sensitive query parameter values and the api secret
+func RedactUrl(rawUrl string) string {
+	rawUrl = redactApiSecret(rawUrl)
+
+	parsedUrl, err := url.Parse(rawUrl)
+	if err != nil {
+		return rawUrl
+	}
+
+	var userInfo string
+	if parsedUrl.User != nil {
+		if _, hasPassword := parsedUrl.User.Password(); hasPassword {
+			userInfo = parsedUrl.User.Username() + ":" + TraceRedacted + "@"
+			parsedUrl.User = nil
+		}
+	}
+
+	if len(parsedUrl.RawQuery) > 0 {
+		params := strings.Split(parsedUrl.RawQuery, "&")
+		for idx, param := range params {
+			parts := strings.SplitN(param, "=", 2)
+			if len(parts) == 2 && TraceRedactedParamPattern.MatchString(parts[0]) {
+				params[idx] = parts[0] + "=" + TraceRedacted
+			}
+		}
+		parsedUrl.RawQuery = strings.Join(params, "&")
+	}
+
+	redactedUrl := parsedUrl.String()
+	if len(userInfo) > 0 {
+		redactedUrl = strings.Replace(redactedUrl, "://", "://"+userInfo, 1)
+	}
+
+	return redactedUrl
+}
+
+func redactApiSecret(s string) string {
+	if config != nil && len(config.ApiSecret) > 0 {
+		return strings.ReplaceAll(s, config.ApiSecret, TraceRedacted)
+	}
+	return s
+}
+
+// transport errors from an http.Client quote the full url, safeUrl replaces it for urls that carry a secret
+func redactTransportError(err error, safeUrl string) error {
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) {
+		return err
+	}
+
+	redacted := *urlErr
+	redacted.URL = safeUrl
+	return &redacted
+}
+
+// fetch errors usually quote the url they failed on
+func redactTraceError(err error, fetch *TraceFetch) string {
+	message := err.Error()
+	if len(fetch.rawUrl) > 0 {
+		message = strings.ReplaceAll(message, fetch.rawUrl, fetch.Url)
+	}
+	return redactApiSecret(message)
+}
+
+// the proxy the client would use for the request, if any
+func traceProxy(client *http.Client, req *http.Request) string {
+	transport, ok := client.Transport.(*http.Transport)
+	if !ok || transport.Proxy == nil {
+		return ""
+	}
+
+	proxyUrl, err := transport.Proxy(req)
+	if err != nil || proxyUrl == nil {
+		return ""
+	}
+
+	return censorUrl(proxyUrl)
+}
+
+// trace file written next to a dumped body
+func traceFilePath(dumpPath string) string {
+	return strings.TrimSuffix(dumpPath, ".out") + TraceFileSuffix
+}
+
+func writeTraceFile(filePath string, trace *ScrapeTrace) error {
+	trace.lock.Lock()
+	jsonBytes, err := json.MarshalIndent(trace, "", "  ")
+	trace.lock.Unlock()
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filePath, jsonBytes, 0644)
+}
+
+// prints the fetches of a trace, for test runs
+func logTrace(trace *ScrapeTrace) {
+	if trace == nil {
+		return
+	}
+
+	for _, fetch := range trace.Fetches {
+		var notes string
+		if fetch.CacheHit {
+			notes += " (cached)"
+		}
+		if fetch.NotModified {
+			notes += " (not modified)"
+		}
+		if len(fetch.Proxy) > 0 {
+			notes += " via " + fetch.Proxy
+		}
+		if fetch.Retry > 0 {
+			notes += fmt.Sprintf(" retry %d", fetch.Retry)
+		}
+		if len(fetch.Error) > 0 {
+			notes += ": " + fetch.Error
+		}
+
+		Log.Infof("  %s %s -> %d, %d bytes, %dms%s", fetch.Method, fetch.Url, fetch.StatusCode, fetch.Bytes, fetch.DurationMs, notes)
+	}
+}
@@ -0,0 +1,227 @@
+package csg
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"mime"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+//body normalization pipeline, applied to fetched content before pattern matching and hashing
+//configured as a list of transform names on an endpoint or on a scraper, e.g.
+//  transform: ["charset", "strip_scripts", "html_to_text", "html_unescape", "collapse_whitespace"]
+
+const ParamKeyTransform = "transform"
+
+const TransformCharset = "charset"
+const TransformHtmlUnescape = "html_unescape"
+const TransformJsonUnescape = "json_unescape"
+const TransformStripScripts = "strip_scripts"
+const TransformHtmlToText = "html_to_text"
+const TransformCollapseWhitespace = "collapse_whitespace"
+
+type BodyTransform func(body []byte, headers map[string][]string) []byte
+
+var BodyTransforms = map[string]BodyTransform{
+	TransformCharset:            transformCharset,
+	TransformHtmlUnescape:       transformHtmlUnescape,
+	TransformJsonUnescape:       transformJsonUnescape,
+	TransformStripScripts:       transformStripScripts,
+	TransformHtmlToText:         transformHtmlToText,
+	TransformCollapseWhitespace: transformCollapseWhitespace,
+}
+
+var MetaCharsetPattern = regexp.MustCompile(`(?i)<meta[^>]+charset\s*=\s*["']?([a-z0-9_-]+)`)
+var ScriptStylePattern = regexp.MustCompile(`(?is)<script\b.*?</script\s*>|<style\b.*?</style\s*>`)
+var HtmlCommentPattern = regexp.MustCompile(`(?s)<!--.*?-->`)
+var HtmlTagPattern = regexp.MustCompile(`(?s)<[^>]*>`)
+var WhitespacePattern = regexp.MustCompile(`\s+`)
+var JsonUnicodeEscapePattern = regexp.MustCompile(`\\u[dD][89abAB][0-9a-fA-F]{2}\\u[dD][c-fC-F][0-9a-fA-F]{2}|\\u[0-9a-fA-F]{4}`)
+
+// parses the transform list from params, accepts a single name or a list of names
+func getTransformsOptional(params map[string]interface{}) ([]string, error) {
+	rawTransforms, exists := params[ParamKeyTransform]
+	if !exists {
+		return nil, nil
+	}
+
+	var rawList []interface{}
+	switch typed := rawTransforms.(type) {
+	case string:
+		rawList = []interface{}{typed}
+	case []interface{}:
+		rawList = typed
+	default:
+		return nil, fmt.Errorf("Expecting a string or list value for key %s, got '%T' instead", ParamKeyTransform, rawTransforms)
+	}
+
+	transforms := make([]string, 0, len(rawList))
+	for _, rawName := range rawList {
+		name, ok := rawName.(string)
+		if !ok {
+			return nil, fmt.Errorf("Expecting string values in %s, got '%T' instead", ParamKeyTransform, rawName)
+		}
+		if _, exists := BodyTransforms[name]; !exists {
+			return nil, fmt.Errorf("Unknown transform: %s", name)
+		}
+		transforms = append(transforms, name)
+	}
+
+	return transforms, nil
+}
+
+// sets the scraper level transform list on any endpoints that don't have their own
+func applyScraperTransforms(params map[string]interface{}, endpoints ...*Endpoint) error {
+	transforms, err := getTransformsOptional(params)
+	if err != nil || transforms == nil {
+		return err
+	}
+
+	for _, endpoint := range endpoints {
+		if endpoint != nil && endpoint.Transforms == nil {
+			endpoint.Transforms = transforms
+		}
+	}
+
+	return nil
+}
+
+func ApplyTransforms(transforms []string, body []byte, headers map[string][]string) []byte {
+	for _, name := range transforms {
+		if transform, exists := BodyTransforms[name]; exists {
+			body = transform(body, headers)
+		}
+	}
+
+	return body
+}
+
+func transformCharset(body []byte, headers map[string][]string) []byte {
+	//byte order marks take precedence
+	switch {
+	case bytes.HasPrefix(body, []byte{0xEF, 0xBB, 0xBF}):
+		return body[3:]
+	case bytes.HasPrefix(body, []byte{0xFF, 0xFE}):
+		return decodeUtf16(body[2:], false)
+	case bytes.HasPrefix(body, []byte{0xFE, 0xFF}):
+		return decodeUtf16(body[2:], true)
+	}
+
+	charset := ""
+	for headerName, headerVals := range headers {
+		if strings.EqualFold(headerName, "Content-Type") && len(headerVals) > 0 {
+			if _, mediaParams, err := mime.ParseMediaType(headerVals[0]); err == nil {
+				charset = mediaParams["charset"]
+			}
+		}
+	}
+
+	if len(charset) == 0 {
+		if match := MetaCharsetPattern.FindSubmatch(body); match != nil {
+			charset = string(match[1])
+		}
+	}
+
+	switch strings.ToLower(charset) {
+	case "", "utf-8", "utf8", "us-ascii", "ascii":
+		return body
+	case "iso-8859-1", "latin1", "latin-1", "iso8859-1":
+		return decodeSingleByte(body, nil)
+	case "windows-1252", "cp1252":
+		return decodeSingleByte(body, &windows1252)
+	case "utf-16le":
+		return decodeUtf16(body, false)
+	case "utf-16be", "utf-16":
+		return decodeUtf16(body, true)
+	default:
+		Log.Debugf("Unsupported charset, leaving body as is: %s", charset)
+		return body
+	}
+}
+
+// windows-1252 code points for bytes 0x80-0x9F, everything else matches iso-8859-1
+var windows1252 = [32]rune{
+	'€', 0x81, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0x8D, 'Ž', 0x8F,
+	0x90, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0x9D, 'ž', 'Ÿ',
+}
+
+func decodeSingleByte(body []byte, highTable *[32]rune) []byte {
+	if utf8.Valid(body) {
+		//mislabeled, or plain ascii
+		return body
+	}
+
+	var buf bytes.Buffer
+	buf.Grow(len(body) + len(body)/4)
+	for _, b := range body {
+		r := rune(b)
+		if highTable != nil && b >= 0x80 && b <= 0x9F {
+			r = highTable[b-0x80]
+		}
+		buf.WriteRune(r)
+	}
+
+	return buf.Bytes()
+}
+
+func decodeUtf16(body []byte, bigEndian bool) []byte {
+	units := make([]uint16, 0, len(body)/2)
+	for i := 0; i+1 < len(body); i += 2 {
+		if bigEndian {
+			units = append(units, uint16(body[i])<<8|uint16(body[i+1]))
+		} else {
+			units = append(units, uint16(body[i+1])<<8|uint16(body[i]))
+		}
+	}
+
+	return []byte(string(utf16.Decode(units)))
+}
+
+func transformHtmlUnescape(body []byte, _ map[string][]string) []byte {
+	return []byte(html.UnescapeString(string(body)))
+}
+
+// decodes \uXXXX escapes (including surrogate pairs) and \/, other json escapes are left alone
+// so the result is still valid json in most cases
+func transformJsonUnescape(body []byte, _ map[string][]string) []byte {
+	body = JsonUnicodeEscapePattern.ReplaceAllFunc(body, func(escaped []byte) []byte {
+		units := make([]uint16, 0, 2)
+		for i := 0; i+6 <= len(escaped); i += 6 {
+			unit, err := strconv.ParseUint(string(escaped[i+2:i+6]), 16, 16)
+			if err != nil {
+				return escaped
+			}
+			units = append(units, uint16(unit))
+		}
+
+		decoded := utf16.Decode(units)
+		for _, r := range decoded {
+			if r == '"' || r == '\\' || r < 0x20 {
+				//would change the meaning of the surrounding json
+				return escaped
+			}
+		}
+
+		return []byte(string(decoded))
+	})
+
+	return bytes.ReplaceAll(body, []byte(`\/`), []byte(`/`))
+}
+
+func transformStripScripts(body []byte, _ map[string][]string) []byte {
+	return ScriptStylePattern.ReplaceAll(body, []byte(" "))
+}
+
+func transformHtmlToText(body []byte, _ map[string][]string) []byte {
+	body = HtmlCommentPattern.ReplaceAll(body, []byte(" "))
+	return HtmlTagPattern.ReplaceAll(body, []byte(" "))
+}
+
+func transformCollapseWhitespace(body []byte, _ map[string][]string) []byte {
+	return bytes.TrimSpace(WhitespacePattern.ReplaceAll(body, []byte(" ")))
+}
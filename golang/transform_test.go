@@ -0,0 +1,83 @@
+package csg
+
+import (
+	"gopkg.in/yaml.v2"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestApplyTransforms(t *testing.T) {
+	latin1Headers := map[string][]string{"Content-Type": {"text/html; charset=ISO-8859-1"}}
+
+	cases := []struct {
+		transforms []string
+		headers    map[string][]string
+		input      string
+		expected   string
+	}{
+		{[]string{TransformCharset}, latin1Headers, "caf\xe9", "café"},
+		{[]string{TransformCharset}, nil, `<meta charset="windows-1252"><p>don` + "\x92t", `<meta charset="windows-1252"><p>don’t`},
+		{[]string{TransformCharset}, nil, "\xff\xfeh\x00i\x00", "hi"},
+		{[]string{TransformHtmlUnescape}, nil, "a=1&#038;b=2&amp;c=&lt;3", "a=1&b=2&c=<3"},
+		{[]string{TransformJsonUnescape}, nil, `"https:\u002F\u002Fexample.com\/x?q=\u0022\ud83d\ude00"`, `"https://example.com/x?q=\u0022😀"`},
+		{[]string{TransformStripScripts, TransformHtmlToText, TransformCollapseWhitespace}, nil,
+			"<html><script>var x = '<b>';</script>\n<style>p {}</style><p>No   appointments</p>\n<!-- <b>hidden</b> --></html>",
+			"No appointments"},
+	}
+
+	for _, c := range cases {
+		output := string(ApplyTransforms(c.transforms, []byte(c.input), c.headers))
+		if output != c.expected {
+			t.Errorf("%v: expected '%s', got '%s'", c.transforms, c.expected, output)
+		}
+	}
+
+	if _, err := getTransformsOptional(map[string]interface{}{ParamKeyTransform: []interface{}{"nope"}}); err == nil {
+		t.Errorf("Expected error for unknown transform")
+	}
+}
+
+const TestTransformScraperYAML = `endpoint:
+  url: "%s"
+  method: "GET"
+available_regexp: "appointments available"
+unavailable_regexp: "No appointments"`
+
+func TestScrapeWithTransforms(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("<p>No   appointments</p><script>var later = 'appointments available';</script>"))
+	}))
+	defer server.Close()
+
+	prevConfig := config
+	config = &Config{}
+	defer func() { config = prevConfig }()
+
+	for _, transforms := range [][]string{nil, {TransformStripScripts, TransformHtmlToText, TransformCollapseWhitespace}} {
+		params := make(map[string]interface{})
+		if err := yaml.Unmarshal([]byte(strings.ReplaceAll(TestTransformScraperYAML, "%s", server.URL)), params); err != nil {
+			panic(err)
+		}
+		expected := StatusYes
+		if transforms != nil {
+			params[ParamKeyTransform] = []interface{}{transforms[0], transforms[1], transforms[2]}
+			expected = StatusNo
+		}
+
+		scraper := &ScraperStandardRegexp{ScraperName: "TestScrapeWithTransforms", AvailableStatus: StatusYes}
+		if err := scraper.Configure(params); err != nil {
+			t.Fatal(err)
+		}
+
+		status, _, body, err := scraper.Scrape()
+		Cache.Destroy()
+		if err != nil || status != expected {
+			t.Errorf("%v: expected status %s, got %s (%v): %s", transforms, expected, status, err, body)
+		}
+		if transforms != nil && string(body) != "No appointments" {
+			t.Errorf("Expected the transformed body, got %s", body)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package csg
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+//notices scrapers that stop completing, e.g. a hung scrape holding its lock or a broken schedule, which never return an error.
+//a scraper is stale once its last successful scrape is older than stale_factor times its interval.  only the continuous
+//runner has a watchdog: a once pass scrapes everything a single time and keeps nothing between runs to compare against
+
+const DefaultStaleFactor = 5.0
+
+type Watchdog struct {
+	tracker   *ChangeTracker
+	contexts  []*ScrapeAndSendContext
+	factor    float64
+	startTime int64
+	stale     map[string]bool
+	lock      *sync.Mutex
+}
+
+func NewWatchdog(tracker *ChangeTracker, scrapeContexts []*ScrapeAndSendContext, factor float64) *Watchdog {
+	if factor <= 0 {
+		factor = DefaultStaleFactor
+	}
+
+	watchdog := new(Watchdog)
+	watchdog.tracker = tracker
+	watchdog.contexts = scrapeContexts
+	watchdog.factor = factor
+	watchdog.startTime = time.Now().Unix()
+	watchdog.stale = make(map[string]bool)
+	watchdog.lock = new(sync.Mutex)
+
+	return watchdog
+}
+
+// seconds without a successful scrape before the scraper is stale
+func (w *Watchdog) MaxAge(ctx *ScrapeAndSendContext) int64 {
+	return int64(w.factor * float64(ctx.MinInterval()))
+}
+
+// checks every scraper, sending an alert when one becomes stale and a notice when it recovers.
+// returns the names of scrapers that became stale and that recovered
+func (w *Watchdog) Check(now int64) (newlyStale []string, recovered []string) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	//sent in the background so a slow notification channel doesn't hold up the scheduler
+	notifications := make([]func() error, 0)
+	defer func() {
+		if len(notifications) > 0 && config.NotifyOnError {
+			go func() {
+				for _, notify := range notifications {
+					if err := notify(); err != nil {
+						Log.Errorf("%+v", err)
+					}
+				}
+			}()
+		}
+	}()
+
+	for _, ctx := range w.contexts {
+		state, exists := w.tracker.State(ctx.Name)
+		if !exists {
+			continue
+		}
+
+		lastSuccess := state.LastSuccess
+		since := lastSuccess
+		if since == 0 {
+			since = w.startTime
+		}
+
+		wasStale := w.stale[ctx.Name]
+		isStale := now-since > w.MaxAge(ctx)
+
+		if isStale && !wasStale {
+			w.stale[ctx.Name] = true
+			newlyStale = append(newlyStale, ctx.Name)
+			metricScraperStale.Set(1, ctx.Name)
+
+			LogFor(ctx.Name).Warnf("no successful scrape for %ds (expected every %ds)", now-since, ctx.MinInterval())
+			staleCtx := ctx
+			notifications = append(notifications, func() error {
+				return notifyStale(staleCtx, lastSuccess)
+			})
+		} else if !isStale && wasStale {
+			delete(w.stale, ctx.Name)
+			recovered = append(recovered, ctx.Name)
+			metricScraperStale.Set(0, ctx.Name)
+
+			LogFor(ctx.Name).Infof("recovered, scraping again")
+			recoveredCtx, status := ctx, state.Status
+			notifications = append(notifications, func() error {
+				return notifyRecovered(recoveredCtx, status)
+			})
+		}
+	}
+
+	return
+}
+
+func (w *Watchdog) IsStale(name string) bool {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	return w.stale[name]
+}
+
+// names of all stale scrapers, sorted
+func (w *Watchdog) Stale() []string {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	names := make([]string, 0, len(w.stale))
+	for name := range w.stale {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func notifyStale(ctx *ScrapeAndSendContext, lastSuccess int64) error {
+	name, interval := ctx.Name, ctx.MinInterval()
+	subject := DefaultSubject
+	lastSuccessStr := "never"
+	if lastSuccess > 0 {
+		lastSuccessStr = time.Unix(lastSuccess, 0).In(templateLocation()).Format(HistoryTimeFormat)
+	}
+	body := fmt.Sprintf("Scraper stopped reporting: %s, last successful scrape: %s, expected every %ds\r\n%s", name, lastSuccessStr, interval, notificationIds(name))
+
+	notification := notificationFor(NotificationKindStale, ctx, subject, body)
+	return Notifications.Add(notification)
+}
+
+func notifyRecovered(ctx *ScrapeAndSendContext, status Status) error {
+	subject := DefaultSubject
+	body := fmt.Sprintf("Scraper recovered: %s, status: %v\r\n%s", ctx.Name, status, notificationIds(ctx.Name))
+
+	notification := notificationFor(NotificationKindRecovered, ctx, subject, body)
+	notification.Status = status
+	return Notifications.Add(notification)
+}
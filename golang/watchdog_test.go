@@ -0,0 +1,35 @@
+package csg
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWatchdog(t *testing.T) {
+	defer useStatusApiTestConfig(&Config{PollInterval: 30})()
+
+	ctx, tracker := newStatusApiTestContext(&ScraperConfig{ApiKey: "key"})
+	watchdog := NewWatchdog(tracker, []*ScrapeAndSendContext{ctx}, 2)
+
+	//never succeeded, measured from the watchdog's start
+	now := time.Now().Unix()
+	if newlyStale, _ := watchdog.Check(now); len(newlyStale) != 0 {
+		t.Errorf("Expected no stale scrapers right after start, got %v", newlyStale)
+	}
+	newlyStale, _ := watchdog.Check(now + watchdog.MaxAge(ctx) + 1)
+	if len(newlyStale) != 1 || newlyStale[0] != ctx.Name || !watchdog.IsStale(ctx.Name) {
+		t.Errorf("Expected %s to become stale, got %v", ctx.Name, newlyStale)
+	}
+
+	//only reported once
+	if newlyStale, _ := watchdog.Check(now + watchdog.MaxAge(ctx) + 2); len(newlyStale) != 0 {
+		t.Errorf("Expected stale scraper to be reported once, got %v", newlyStale)
+	}
+
+	doScrapeAndSend(tracker, ctx, true, nil)
+
+	_, recovered := watchdog.Check(time.Now().Unix())
+	if len(recovered) != 1 || recovered[0] != ctx.Name || watchdog.IsStale(ctx.Name) || len(watchdog.Stale()) != 0 {
+		t.Errorf("Expected %s to recover, got %v", ctx.Name, recovered)
+	}
+}